@@ -0,0 +1,95 @@
+// This package shows the saved messages with the texts they had before the
+// edits.
+//
+//	messages [-bot NAME] history CHAT_ID MESSAGE_ID
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/setup"
+	tgbotdb "github.com/alienvspredator/simple-tgbot/internal/tgbot/database"
+	"github.com/sethvargo/go-signalcontext"
+	"go.uber.org/multierr"
+)
+
+func main() {
+	ctx, done := signalcontext.OnInterrupt()
+
+	ctx = logging.WithLogger(ctx, logging.NewLogger())
+
+	err := realMain(ctx)
+	done()
+
+	log := logging.FromContext(ctx)
+
+	if syncErr := log.Sync(); syncErr != nil {
+		err = multierr.Append(err, syncErr)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func realMain(ctx context.Context) error {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	bot := fs.String("bot", tgbotdb.DefaultBot, "name of the bot")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("usage: %s [-bot NAME] history CHAT_ID MESSAGE_ID", os.Args[0])
+	}
+
+	var config database.Config
+	ctx, env, err := setup.Setup(ctx, &config)
+	if err != nil {
+		return fmt.Errorf("setup database: %w", err)
+	}
+	defer env.Close(ctx)
+
+	db := tgbotdb.New(env.Database()).ForBot(*bot)
+
+	switch cmd, args := fs.Arg(0), fs.Args()[1:]; cmd {
+	case "history":
+		return history(ctx, db, args)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func history(ctx context.Context, db *tgbotdb.TgBotDB, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: history CHAT_ID MESSAGE_ID")
+	}
+	chatID := args[0]
+	messageID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("parse message ID %q: %w", args[1], err)
+	}
+
+	msg, err := db.GetUserMessage(ctx, chatID, messageID)
+	if err != nil {
+		return fmt.Errorf("get message: %w", err)
+	}
+	revisions, err := db.ListMessageRevisions(ctx, chatID, messageID)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tREPLACED AT\tTEXT")
+	for _, r := range revisions {
+		fmt.Fprintf(w, "%d\t%s\t%q\n", r.Revision, r.ReplacedAt.Format(time.RFC3339), r.Text)
+	}
+	fmt.Fprintf(w, "%d\t%s\t%q\n", msg.Revision, "current", msg.Text)
+	return w.Flush()
+}
@@ -4,16 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
 
 	"github.com/alienvspredator/simple-tgbot/internal/database"
 	"github.com/alienvspredator/simple-tgbot/internal/logging"
 	"github.com/alienvspredator/simple-tgbot/internal/setup"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot"
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source/httpfs"
 	"github.com/markbates/pkger"
 	"github.com/sethvargo/go-signalcontext"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
+	_ "github.com/alienvspredator/simple-tgbot/internal/tgbot/modules"
 	_ "github.com/golang-migrate/migrate/v4/database/cockroachdb"
 	_ "github.com/golang-migrate/migrate/v4/source/pkger"
 	_ "github.com/lib/pq"
@@ -52,6 +56,43 @@ func realMain(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("create migrate: %w", err)
 	}
+	if err := up(ctx, m); err != nil {
+		return err
+	}
+
+	// The module migrations are versioned apart from the core ones and from
+	// each other, so the modules can be added and removed in any order.
+	for _, mod := range tgbot.Modules() {
+		mm, ok := mod.(tgbot.ModuleMigrations)
+		if !ok {
+			continue
+		}
+
+		src, err := httpfs.New(mm.Migrations(), "/")
+		if err != nil {
+			return fmt.Errorf("read module %q migrations: %w", mod.Name(), err)
+		}
+		u, err := moduleURL(config.ConnectionURL(), mod.Name())
+		if err != nil {
+			return err
+		}
+		m, err := migrate.NewWithSourceInstance("httpfs", src, u)
+		if err != nil {
+			return fmt.Errorf("create module %q migrate: %w", mod.Name(), err)
+		}
+		if err := up(ctx, m); err != nil {
+			return fmt.Errorf("module %q: %w", mod.Name(), err)
+		}
+	}
+
+	log.Debugw("finished migrations up")
+	return nil
+}
+
+// up runs the migrations up and closes m.
+func up(ctx context.Context, m *migrate.Migrate) error {
+	log := logging.FromContext(ctx)
+
 	m.Log = newLogger(ctx)
 
 	if err := m.Up(); err != nil {
@@ -69,10 +110,23 @@ func realMain(ctx context.Context) error {
 		return fmt.Errorf("migrate database: %w", dbErr)
 	}
 
-	log.Debugw("finished migrations up")
 	return nil
 }
 
+// moduleURL returns the connection URL that keeps the versions of the module
+// migrations in the schema_migrations_<name> table.
+func moduleURL(connectionURL, name string) (string, error) {
+	u, err := url.Parse(connectionURL)
+	if err != nil {
+		return "", fmt.Errorf("parse connection URL: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("x-migrations-table", "schema_migrations_"+name)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
 type logger struct {
 	zap *zap.SugaredLogger
 }
@@ -0,0 +1,112 @@
+// This package inspects and replays the outgoing messages that could not be
+// delivered.
+//
+//	outbox [-bot NAME] list [-limit 50]
+//	outbox [-bot NAME] replay ID...
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/setup"
+	tgbotdb "github.com/alienvspredator/simple-tgbot/internal/tgbot/database"
+	"github.com/sethvargo/go-signalcontext"
+	"go.uber.org/multierr"
+)
+
+func main() {
+	ctx, done := signalcontext.OnInterrupt()
+
+	ctx = logging.WithLogger(ctx, logging.NewLogger())
+
+	err := realMain(ctx)
+	done()
+
+	log := logging.FromContext(ctx)
+
+	if syncErr := log.Sync(); syncErr != nil {
+		err = multierr.Append(err, syncErr)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func realMain(ctx context.Context) error {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	bot := fs.String("bot", tgbotdb.DefaultBot, "name of the bot")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("usage: %s [-bot NAME] list [-limit N] | replay ID...", os.Args[0])
+	}
+
+	var config database.Config
+	ctx, env, err := setup.Setup(ctx, &config)
+	if err != nil {
+		return fmt.Errorf("setup database: %w", err)
+	}
+	defer env.Close(ctx)
+
+	db := tgbotdb.New(env.Database()).ForBot(*bot)
+
+	switch cmd, args := fs.Arg(0), fs.Args()[1:]; cmd {
+	case "list":
+		return list(ctx, db, args)
+	case "replay":
+		return replay(ctx, db, args)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func list(ctx context.Context, db *tgbotdb.TgBotDB, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	limit := fs.Int("limit", 50, "number of dead letters to show")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	letters, err := db.ListDeadLetters(ctx, *limit)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCHAT\tATTEMPTS\tFAILED AT\tERROR\tTEXT")
+	for _, l := range letters {
+		fmt.Fprintf(
+			w, "%d\t%s\t%d\t%s\t%s\t%q\n",
+			l.ID, l.ChatID, l.Attempts, l.FailedAt.Format(time.RFC3339), l.LastError, l.Text,
+		)
+	}
+	return w.Flush()
+}
+
+func replay(ctx context.Context, db *tgbotdb.TgBotDB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no dead letter IDs given")
+	}
+
+	log := logging.FromContext(ctx)
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("parse dead letter ID %q: %w", arg, err)
+		}
+		if err := db.ReplayDeadLetter(ctx, id); err != nil {
+			return fmt.Errorf("replay dead letter %d: %w", id, err)
+		}
+		log.Infow("dead letter moved to the outbox", "outbox_id", id)
+	}
+	return nil
+}
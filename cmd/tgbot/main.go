@@ -11,9 +11,12 @@ import (
 	"github.com/alienvspredator/simple-tgbot/internal/server"
 	"github.com/alienvspredator/simple-tgbot/internal/setup"
 	"github.com/alienvspredator/simple-tgbot/internal/tgbot"
+	"github.com/sethvargo/go-envconfig"
 	"github.com/sethvargo/go-signalcontext"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
+
+	_ "github.com/alienvspredator/simple-tgbot/internal/tgbot/modules"
 )
 
 func main() {
@@ -50,12 +53,15 @@ func realMain(ctx context.Context) error {
 		}
 	}()
 
-	bot := tgbot.New(env, &config)
+	configs, err := config.BotConfigs(ctx, envconfig.OsLookuper(), env.SecretManager())
+	if err != nil {
+		return fmt.Errorf("config.BotConfigs: %w", err)
+	}
 
-	log.Info("starting bot")
+	log.Infow("starting bots", "bots", len(configs))
 
 	if err := server.ServeMetricsIfPrometheus(ctx); err != nil {
 		return fmt.Errorf("serving metrics: %w", err)
 	}
-	return bot.Serve(ctx)
+	return tgbot.ServeBots(ctx, env, configs)
 }
@@ -0,0 +1,102 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/cron"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+	} {
+		if _, err := cron.Parse(expr); err == nil {
+			t.Errorf("expected an error for %q", expr)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{
+			expr: "* * * * *",
+			from: time.Date(2026, 10, 18, 12, 0, 30, 0, time.UTC),
+			want: time.Date(2026, 10, 18, 12, 1, 0, 0, time.UTC),
+		},
+		{
+			expr: "@daily",
+			from: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+			want: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "*/15 9-17 * * mon-fri",
+			from: time.Date(2026, 10, 16, 17, 50, 0, 0, time.UTC), // Friday
+			want: time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "30 8 * * 7",
+			from: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC), // Sunday
+			want: time.Date(2026, 10, 25, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			// Either the 1st or a Monday.
+			expr: "0 0 1 * 1",
+			from: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "0 12 29 feb *",
+			from: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "0 9 * * *",
+			from: time.Date(2026, 10, 24, 10, 0, 0, 0, berlin),
+			// The clocks go back on October 25.
+			want: time.Date(2026, 10, 25, 9, 0, 0, 0, berlin),
+		},
+		{
+			expr: "0 0 30 2 *",
+			from: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.expr, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := cron.Parse(tc.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(tc.from); !got.Equal(tc.want) {
+				t.Errorf("next time does not match, got = %s, want = %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package i18n
+
+var en = &Locale{
+	Tag:    "en",
+	Plural: PluralEnglish,
+	Messages: map[string]Message{
+		"command.unknown":    {Other: "I don't understand you!"},
+		"command.suggestion": {Other: "Did you mean /{{.Command}}?"},
+		"command.usage":      {Other: "Usage: {{.Usage}}"},
+
+		"command.start.description": {Other: "Start using the bot"},
+
+		"command.help.description": {Other: "List of commands"},
+		"command.help.help":        {Other: "Shows the list of commands or the help for one command."},
+		"help.header": {
+			One:   "{{.Count}} command available:",
+			Other: "{{.Count}} commands available:",
+		},
+		"help.command": {Other: "/{{.Command}} — {{.Description}}"},
+
+		"command.cancel.description": {Other: "Cancel the current action"},
+		"state.cancelled":            {Other: "Cancelled."},
+
+		"command.language.description": {Other: "Choose the language"},
+		"command.language.help":        {Other: "Without an argument shows the current language and the buttons to change it."},
+		"language.name":                {Other: "English"},
+		"language.current":             {Other: "Current language: {{.Language}}. Available: {{.Languages}}."},
+		"language.changed":             {Other: "I speak English now."},
+		"language.unknown":             {Other: "I don't know this language. Available: {{.Languages}}."},
+
+		"command.silent.description": {Other: "Listen to the chat silently"},
+		"command.silent.help":        {Other: "With on I save the messages of the chat but do not answer them, with off I answer again. The commands always work. In groups only the administrators can change the mode."},
+		"silent.on":                  {Other: "I'm listening silently."},
+		"silent.off":                 {Other: "I'm answering the messages."},
+		"silent.forbidden":           {Other: "Only the chat administrators can change the mode."},
+
+		"group.hello":   {Other: "Hi! In groups I answer only mentions, replies to my messages and commands."},
+		"group.welcome": {Other: "Welcome, {{.Names}}!"},
+
+		"callback.unknown": {Other: "This button no longer works."},
+
+		"command.remind.description":    {Other: "Remind you of something"},
+		"command.remind.help":           {Other: "The time goes first: 2h or 3d from now, 9:00, today 18:00, tomorrow 9:00, 2026-12-31 18:00, every weekday 9:00 (or day, weekend, monday…), cron 0 9 * * 1-5. The times are in your time zone, see /timezone."},
+		"remind.text":                   {Other: "⏰ {{.Text}}"},
+		"remind.bad_time":               {Other: "I don't understand the time. Try 2h, 9:00, tomorrow 9:00 or every weekday 9:00."},
+		"remind.past":                   {Other: "This time has passed."},
+		"remind.limit":                  {Other: "You have too many reminders. Delete some with /unremind."},
+		"remind.created":                {Other: "Reminder #{{.ID}} set for {{.At}}."},
+		"remind.not_found":              {Other: "There is no such reminder."},
+		"remind.deleted":                {Other: "Reminder #{{.ID}} deleted."},
+		"command.reminders.description": {Other: "Your reminders"},
+		"reminders.empty":               {Other: "You have no reminders."},
+		"reminders.item":                {Other: "#{{.ID}} {{.At}} — {{.Text}}"},
+		"reminders.recurring":           {Other: "#{{.ID}} {{.At}}, repeats {{.Schedule}} — {{.Text}}"},
+		"command.unremind.description":  {Other: "Delete a reminder"},
+		"command.timezone.description":  {Other: "Show or change your time zone"},
+		"command.timezone.help":         {Other: "Set the time zone by its name, e.g. Europe/Berlin. The reminders use it."},
+		"timezone.current":              {Other: "Your time zone: {{.TimeZone}}."},
+		"timezone.unknown":              {Other: "I don't know this time zone. Use a name like Europe/Berlin."},
+		"timezone.changed":              {Other: "Your time zone is {{.TimeZone}} now."},
+
+		"media.received": {Other: "Got your file."},
+
+		"command.admin.description":         {Other: "Operator commands"},
+		"admin.header":                      {Other: "Your role: {{.Role}}. Commands:"},
+		"command.stats.description":         {Other: "Users, chats and outgoing messages"},
+		"stats.text":                        {Other: "Users: {{.Users}}, blocked the bot: {{.BlockedUsers}}\nChats: {{.Chats}}\nOutbox: {{.OutboxPending}}, undelivered: {{.DeadLetters}}"},
+		"command.failures.description":      {Other: "Recent undelivered messages"},
+		"failures.empty":                    {Other: "No undelivered messages."},
+		"failures.item":                     {Other: "#{{.ID}} to {{.ChatID}} at {{.FailedAt}}: {{.Error}}"},
+		"command.feature.description":       {Other: "Show or switch the features"},
+		"feature.state":                     {Other: "{{.Feature}}: {{.State}}"},
+		"feature.on":                        {Other: "on"},
+		"feature.off":                       {Other: "off"},
+		"feature.unknown":                   {Other: "I don't know this feature. Available: {{.Features}}."},
+		"command.rules.description":         {Other: "Access rules"},
+		"command.allow.description":         {Other: "Allow a user, a chat or usernames by pattern"},
+		"command.deny.description":          {Other: "Ban a user, a chat or usernames by pattern"},
+		"command.unrule.description":        {Other: "Delete an access rule"},
+		"rules.empty":                       {Other: "There are no access rules."},
+		"rules.invalid":                     {Other: "The kind must be user or chat with an ID, or username with a pattern."},
+		"rules.added":                       {Other: "Rule #{{.ID}} added."},
+		"rules.deleted":                     {Other: "Rule #{{.ID}} deleted."},
+		"rules.not_found":                   {Other: "There is no such rule."},
+		"command.broadcast.description":     {Other: "Send the replied message to the users"},
+		"command.broadcast.help":            {Other: "Reply to the message to send. The first line of the options selects the audience: lang=<code>, seen=<period> such as 30d, groups to add the groups. Each next line adds a link button: <text> | <url>."},
+		"broadcast.no_message":              {Other: "Reply with {{.Usage}} to the message to send."},
+		"broadcast.unsupported":             {Other: "I can broadcast only a text, a photo, a document, a voice message or a sticker."},
+		"broadcast.bad_options":             {Other: "Can't read the options: {{.Error}}."},
+		"broadcast.created":                 {Other: "Broadcast #{{.ID}} started. See /broadcasts for the progress."},
+		"broadcast.not_running":             {Other: "There is no such running broadcast."},
+		"broadcast.cancelled":               {Other: "Broadcast #{{.ID}} stopped."},
+		"command.broadcasts.description":    {Other: "Progress of the recent broadcasts"},
+		"broadcasts.empty":                  {Other: "There were no broadcasts."},
+		"broadcasts.item":                   {Other: "#{{.ID}} {{.Status}} since {{.CreatedAt}}: sent {{.Sent}}, pending {{.Pending}}, failed {{.Failed}}, blocked {{.Blocked}}"},
+		"command.stopbroadcast.description": {Other: "Stop a running broadcast"},
+		"command.schedule.description":      {Other: "Schedule a post to a chat"},
+		"command.schedule.help":             {Other: "Sends the text to the chat at the time, in the formats of /remind."},
+		"schedule.bad_chat":                 {Other: "The chat ID must be a number."},
+		"schedule.created":                  {Other: "Post #{{.ID}} scheduled for {{.At}}. Delete it with /unremind."},
+		"command.roles.description":         {Other: "Users with a role"},
+		"roles.empty":                       {Other: "Nobody has a role."},
+		"roles.item":                        {Other: "{{.UserID}} — {{.Role}}"},
+		"command.grant.description":         {Other: "Give a role to the user"},
+		"command.revoke.description":        {Other: "Take the role away from the user"},
+		"role.granted":                      {Other: "User {{.UserID}} is {{.Role}} now."},
+		"role.revoked":                      {Other: "User {{.UserID}} has no role now."},
+		"role.unknown":                      {Other: "I don't know this role. Available: moderator, admin."},
+		"role.bad_user":                     {Other: "The user ID must be a number."},
+		"role.forbidden":                    {Other: "You can't change the role of this user."},
+
+		"flood.warning": {Other: "You're sending messages too fast. I'll ignore them for a while."},
+		"flood.quota":   {Other: "You've reached today's message limit. I'll answer again tomorrow."},
+		"flood.muted":   {Other: "Too many messages. I'll ignore them for {{.Duration}}."},
+
+		"error.try_again": {Other: "Something went wrong, please try again."},
+	},
+}
@@ -0,0 +1,252 @@
+// Package i18n provides the message catalog for the user-facing texts.
+//
+// Every locale is defined in its own file as a set of messages with the
+// plural forms and the text/template parameters. A message missing in the
+// requested locale falls back to the default one.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// contextKey is a private string type to prevent collisions in the context map.
+type contextKey string
+
+// localizerKey points to the value in the context where the localizer is stored.
+const localizerKey = contextKey("localizer")
+
+// Form is a CLDR plural category.
+type Form string
+
+const (
+	One   Form = "one"
+	Few   Form = "few"
+	Many  Form = "many"
+	Other Form = "other"
+)
+
+// PluralRule returns the plural form for the number.
+type PluralRule func(n int) Form
+
+// Message is a translated text. Messages without the count use Other only.
+// The texts are text/template templates executed with Params.
+type Message struct {
+	One   string
+	Few   string
+	Many  string
+	Other string
+}
+
+// text returns the text for the form, falling back to Other.
+func (m Message) text(form Form) string {
+	var s string
+	switch form {
+	case One:
+		s = m.One
+	case Few:
+		s = m.Few
+	case Many:
+		s = m.Many
+	}
+	if s == "" {
+		s = m.Other
+	}
+	return s
+}
+
+// Locale is the set of messages of one language.
+type Locale struct {
+	// Tag is the language code, e.g. "ru".
+	Tag      string
+	Plural   PluralRule
+	Messages map[string]Message
+}
+
+// Params are the template parameters of a message.
+type Params map[string]interface{}
+
+// Catalog holds the translations of all locales.
+type Catalog struct {
+	defaultLocale string
+
+	mu        sync.RWMutex
+	locales   map[string]*Locale
+	templates map[string]*template.Template
+}
+
+// NewCatalog creates a catalog with the built-in locales. The default locale
+// is used for the unknown languages and the missing messages.
+func NewCatalog(defaultLocale string) (*Catalog, error) {
+	c := &Catalog{
+		defaultLocale: defaultLocale,
+		locales:       make(map[string]*Locale),
+		templates:     make(map[string]*template.Template),
+	}
+
+	for _, l := range builtin {
+		if err := c.Add(l); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := c.locales[defaultLocale]; !ok {
+		return nil, fmt.Errorf("unknown default locale %q", defaultLocale)
+	}
+
+	return c, nil
+}
+
+// Add registers the messages of the locale. The messages are merged into the
+// already registered ones with the same tag.
+func (c *Catalog) Add(l *Locale) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tag := strings.ToLower(l.Tag)
+	existing, ok := c.locales[tag]
+	if !ok {
+		existing = &Locale{Tag: tag, Plural: l.Plural, Messages: make(map[string]Message)}
+		c.locales[tag] = existing
+	}
+	if existing.Plural == nil {
+		existing.Plural = l.Plural
+	}
+
+	for key, msg := range l.Messages {
+		for _, form := range []Form{One, Few, Many, Other} {
+			s := msg.text(form)
+			tmpl, err := template.New(key).Option("missingkey=zero").Parse(s)
+			if err != nil {
+				return fmt.Errorf("locale %s: parse message %q: %w", tag, key, err)
+			}
+			c.templates[templateKey(tag, key, form)] = tmpl
+		}
+		existing.Messages[key] = msg
+	}
+
+	return nil
+}
+
+// Tags returns the registered language codes.
+func (c *Catalog) Tags() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tags := make([]string, 0, len(c.locales))
+	for tag := range c.locales {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Match returns the registered locale for the language code, e.g. "en" for
+// "en-US". It reports false if there is no such locale.
+func (c *Catalog) Match(languageCode string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tag := strings.ToLower(strings.ReplaceAll(languageCode, "_", "-"))
+	if _, ok := c.locales[tag]; ok {
+		return tag, true
+	}
+	if i := strings.Index(tag, "-"); i > 0 {
+		if _, ok := c.locales[tag[:i]]; ok {
+			return tag[:i], true
+		}
+	}
+	return "", false
+}
+
+// Localizer returns the localizer for the language code. Unknown languages
+// get the default locale.
+func (c *Catalog) Localizer(languageCode string) *Localizer {
+	tag, ok := c.Match(languageCode)
+	if !ok {
+		tag = c.defaultLocale
+	}
+	return &Localizer{catalog: c, tag: tag}
+}
+
+func (c *Catalog) render(tag, key string, n int, params Params) (string, bool) {
+	c.mu.RLock()
+	l, ok := c.locales[tag]
+	if !ok {
+		c.mu.RUnlock()
+		return "", false
+	}
+	if _, ok := l.Messages[key]; !ok {
+		c.mu.RUnlock()
+		return "", false
+	}
+	form := Other
+	if l.Plural != nil {
+		form = l.Plural(n)
+	}
+	tmpl := c.templates[templateKey(tag, key, form)]
+	c.mu.RUnlock()
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, params); err != nil {
+		return "", false
+	}
+	return sb.String(), true
+}
+
+func templateKey(tag, key string, form Form) string {
+	return tag + "\x00" + key + "\x00" + string(form)
+}
+
+// Localizer renders the messages in one locale.
+type Localizer struct {
+	catalog *Catalog
+	tag     string
+}
+
+// Tag returns the language code of the localizer.
+func (l *Localizer) Tag() string {
+	return l.tag
+}
+
+// Get renders the message with the params.
+func (l *Localizer) Get(key string, params Params) string {
+	return l.Plural(key, 0, params)
+}
+
+// Plural renders the plural form of the message for the count. The count is
+// available in the template as {{.Count}}. Messages missing in the locale are
+// taken from the default one; the key itself is returned if the message is
+// missing in both.
+func (l *Localizer) Plural(key string, count int, params Params) string {
+	p := Params{"Count": count}
+	for k, v := range params {
+		p[k] = v
+	}
+
+	if s, ok := l.catalog.render(l.tag, key, count, p); ok {
+		return s
+	}
+	if s, ok := l.catalog.render(l.catalog.defaultLocale, key, count, p); ok {
+		return s
+	}
+	return key
+}
+
+// WithLocalizer creates a new context with the provided localizer attached.
+func WithLocalizer(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, localizerKey, l)
+}
+
+// FromContext returns the localizer stored in the context. It returns nil if
+// there is no localizer.
+func FromContext(ctx context.Context) *Localizer {
+	if l, ok := ctx.Value(localizerKey).(*Localizer); ok {
+		return l
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package i18n_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alienvspredator/simple-tgbot/internal/i18n"
+)
+
+func TestPluralEastSlavic(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		n    int
+		want i18n.Form
+	}{
+		{n: 0, want: i18n.Many},
+		{n: 1, want: i18n.One},
+		{n: 2, want: i18n.Few},
+		{n: 4, want: i18n.Few},
+		{n: 5, want: i18n.Many},
+		{n: 11, want: i18n.Many},
+		{n: 12, want: i18n.Many},
+		{n: 21, want: i18n.One},
+		{n: 22, want: i18n.Few},
+		{n: 111, want: i18n.Many},
+		{n: 114, want: i18n.Many},
+	}
+
+	for _, tc := range tests {
+		if got := i18n.PluralEastSlavic(tc.n); got != tc.want {
+			t.Errorf("form for %d does not match, got = %s, want = %s", tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestLocalizer(t *testing.T) {
+	t.Parallel()
+
+	catalog, err := i18n.NewCatalog("ru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := catalog.Add(&i18n.Locale{
+		Tag:      "ru",
+		Messages: map[string]i18n.Message{"test.only_ru": {Other: "только {{.Name}}"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		languageCode string
+		key          string
+		count        int
+		params       i18n.Params
+		want         string
+	}{
+		{
+			name:         "params",
+			languageCode: "en",
+			key:          "command.suggestion",
+			params:       i18n.Params{"Command": "help"},
+			want:         "Did you mean /help?",
+		},
+		{
+			name:         "plural one",
+			languageCode: "en",
+			key:          "help.header",
+			count:        1,
+			want:         "1 command available:",
+		},
+		{
+			name:         "plural few",
+			languageCode: "ru",
+			key:          "help.header",
+			count:        3,
+			want:         "Доступно 3 команды:",
+		},
+		{
+			name:         "region",
+			languageCode: "en-US",
+			key:          "command.unknown",
+			want:         "I don't understand you!",
+		},
+		{
+			name:         "unknown language",
+			languageCode: "de",
+			key:          "command.unknown",
+			want:         "Я тебя не понимаю!",
+		},
+		{
+			name:         "missing message",
+			languageCode: "en",
+			key:          "test.only_ru",
+			params:       i18n.Params{"Name": "ru"},
+			want:         "только ru",
+		},
+		{
+			name:         "unknown key",
+			languageCode: "en",
+			key:          "test.unknown",
+			want:         "test.unknown",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				l := catalog.Localizer(tc.languageCode)
+				if got := l.Plural(tc.key, tc.count, tc.params); got != tc.want {
+					t.Errorf("message does not match, got = %q, want = %q", got, tc.want)
+				}
+			},
+		)
+	}
+}
+
+func TestNewCatalog_UnknownDefault(t *testing.T) {
+	t.Parallel()
+
+	if _, err := i18n.NewCatalog("xx"); err == nil {
+		t.Error("expected error for unknown default locale")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	if l := i18n.FromContext(ctx); l != nil {
+		t.Errorf("expected no localizer, got = %v", l)
+	}
+
+	catalog, err := i18n.NewCatalog("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = i18n.WithLocalizer(ctx, catalog.Localizer("ru"))
+	if l := i18n.FromContext(ctx); l == nil || l.Tag() != "ru" {
+		t.Errorf("localizer does not match, got = %v, want = ru", l)
+	}
+}
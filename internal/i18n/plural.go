@@ -0,0 +1,29 @@
+package i18n
+
+// builtin are the locales shipped with the bot.
+var builtin = []*Locale{ru, en}
+
+// PluralEnglish is the plural rule of English and other languages with the
+// one/other forms.
+func PluralEnglish(n int) Form {
+	if n == 1 {
+		return One
+	}
+	return Other
+}
+
+// PluralEastSlavic is the plural rule of Russian and Ukrainian.
+func PluralEastSlavic(n int) Form {
+	if n < 0 {
+		n = -n
+	}
+
+	switch mod10, mod100 := n%10, n%100; {
+	case mod10 == 1 && mod100 != 11:
+		return One
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return Few
+	default:
+		return Many
+	}
+}
@@ -0,0 +1,119 @@
+package i18n
+
+var ru = &Locale{
+	Tag:    "ru",
+	Plural: PluralEastSlavic,
+	Messages: map[string]Message{
+		"command.unknown":    {Other: "Я тебя не понимаю!"},
+		"command.suggestion": {Other: "Может быть, /{{.Command}}?"},
+		"command.usage":      {Other: "Использование: {{.Usage}}"},
+
+		"command.start.description": {Other: "Начать работу с ботом"},
+
+		"command.help.description": {Other: "Список команд"},
+		"command.help.help":        {Other: "Показывает список команд или справку по одной команде."},
+		"help.header": {
+			One:  "Доступна {{.Count}} команда:",
+			Few:  "Доступно {{.Count}} команды:",
+			Many: "Доступно {{.Count}} команд:",
+		},
+		"help.command": {Other: "/{{.Command}} — {{.Description}}"},
+
+		"command.cancel.description": {Other: "Отменить текущее действие"},
+		"state.cancelled":            {Other: "Отменено."},
+
+		"command.language.description": {Other: "Выбрать язык"},
+		"command.language.help":        {Other: "Без аргумента показывает текущий язык и кнопки для его смены."},
+		"language.name":                {Other: "Русский"},
+		"language.current":             {Other: "Текущий язык: {{.Language}}. Доступные: {{.Languages}}."},
+		"language.changed":             {Other: "Теперь я говорю по-русски."},
+		"language.unknown":             {Other: "Я не знаю такого языка. Доступные: {{.Languages}}."},
+
+		"command.silent.description": {Other: "Молча слушать чат"},
+		"command.silent.help":        {Other: "С on я сохраняю сообщения чата, но не отвечаю на них, с off снова отвечаю. Команды работают всегда. В группах режим могут менять только администраторы."},
+		"silent.on":                  {Other: "Я слушаю молча."},
+		"silent.off":                 {Other: "Я отвечаю на сообщения."},
+		"silent.forbidden":           {Other: "Менять режим могут только администраторы чата."},
+
+		"group.hello":   {Other: "Привет! В группах я отвечаю только на упоминания, ответы на мои сообщения и команды."},
+		"group.welcome": {Other: "Добро пожаловать, {{.Names}}!"},
+
+		"callback.unknown": {Other: "Эта кнопка больше не работает."},
+
+		"command.remind.description":    {Other: "Напомнить о чём-нибудь"},
+		"command.remind.help":           {Other: "Сначала время: 2h или 3d от текущего момента, 9:00, today 18:00, tomorrow 9:00, 2026-12-31 18:00, every weekday 9:00 (или day, weekend, monday…), cron 0 9 * * 1-5. Время — в вашем часовом поясе, см. /timezone."},
+		"remind.text":                   {Other: "⏰ {{.Text}}"},
+		"remind.bad_time":               {Other: "Не понимаю время. Попробуйте 2h, 9:00, tomorrow 9:00 или every weekday 9:00."},
+		"remind.past":                   {Other: "Это время уже прошло."},
+		"remind.limit":                  {Other: "У вас слишком много напоминаний. Удалите лишние через /unremind."},
+		"remind.created":                {Other: "Напоминание #{{.ID}} на {{.At}}."},
+		"remind.not_found":              {Other: "Такого напоминания нет."},
+		"remind.deleted":                {Other: "Напоминание #{{.ID}} удалено."},
+		"command.reminders.description": {Other: "Ваши напоминания"},
+		"reminders.empty":               {Other: "У вас нет напоминаний."},
+		"reminders.item":                {Other: "#{{.ID}} {{.At}} — {{.Text}}"},
+		"reminders.recurring":           {Other: "#{{.ID}} {{.At}}, повтор {{.Schedule}} — {{.Text}}"},
+		"command.unremind.description":  {Other: "Удалить напоминание"},
+		"command.timezone.description":  {Other: "Показать или сменить часовой пояс"},
+		"command.timezone.help":         {Other: "Укажите часовой пояс по имени, например Europe/Moscow. По нему работают напоминания."},
+		"timezone.current":              {Other: "Ваш часовой пояс: {{.TimeZone}}."},
+		"timezone.unknown":              {Other: "Не знаю такого часового пояса. Укажите имя вроде Europe/Moscow."},
+		"timezone.changed":              {Other: "Теперь ваш часовой пояс — {{.TimeZone}}."},
+
+		"media.received": {Other: "Файл получен."},
+
+		"command.admin.description":         {Other: "Команды операторов"},
+		"admin.header":                      {Other: "Твоя роль: {{.Role}}. Команды:"},
+		"command.stats.description":         {Other: "Пользователи, чаты и исходящие сообщения"},
+		"stats.text":                        {Other: "Пользователи: {{.Users}}, заблокировали бота: {{.BlockedUsers}}\nЧаты: {{.Chats}}\nВ очереди: {{.OutboxPending}}, не доставлено: {{.DeadLetters}}"},
+		"command.failures.description":      {Other: "Последние недоставленные сообщения"},
+		"failures.empty":                    {Other: "Недоставленных сообщений нет."},
+		"failures.item":                     {Other: "#{{.ID}} в {{.ChatID}}, {{.FailedAt}}: {{.Error}}"},
+		"command.feature.description":       {Other: "Показать или переключить функции"},
+		"feature.state":                     {Other: "{{.Feature}}: {{.State}}"},
+		"feature.on":                        {Other: "включено"},
+		"feature.off":                       {Other: "выключено"},
+		"feature.unknown":                   {Other: "Я не знаю такой функции. Доступные: {{.Features}}."},
+		"command.rules.description":         {Other: "Правила доступа"},
+		"command.allow.description":         {Other: "Разрешить пользователя, чат или имена по шаблону"},
+		"command.deny.description":          {Other: "Забанить пользователя, чат или имена по шаблону"},
+		"command.unrule.description":        {Other: "Удалить правило доступа"},
+		"rules.empty":                       {Other: "Правил доступа нет."},
+		"rules.invalid":                     {Other: "Вид правила: user или chat с ID, или username с шаблоном."},
+		"rules.added":                       {Other: "Правило #{{.ID}} добавлено."},
+		"rules.deleted":                     {Other: "Правило #{{.ID}} удалено."},
+		"rules.not_found":                   {Other: "Такого правила нет."},
+		"command.broadcast.description":     {Other: "Разослать сообщение пользователям"},
+		"command.broadcast.help":            {Other: "Ответьте на сообщение для рассылки. Первая строка параметров выбирает получателей: lang=<код>, seen=<период>, например 30d, groups, чтобы добавить группы. Каждая следующая строка добавляет кнопку-ссылку: <текст> | <url>."},
+		"broadcast.no_message":              {Other: "Ответьте {{.Usage}} на сообщение для рассылки."},
+		"broadcast.unsupported":             {Other: "Разослать можно только текст, фото, документ, голосовое сообщение или стикер."},
+		"broadcast.bad_options":             {Other: "Не могу разобрать параметры: {{.Error}}."},
+		"broadcast.created":                 {Other: "Рассылка #{{.ID}} запущена. Ход рассылки — в /broadcasts."},
+		"broadcast.not_running":             {Other: "Такой идущей рассылки нет."},
+		"broadcast.cancelled":               {Other: "Рассылка #{{.ID}} остановлена."},
+		"command.broadcasts.description":    {Other: "Ход последних рассылок"},
+		"broadcasts.empty":                  {Other: "Рассылок ещё не было."},
+		"broadcasts.item":                   {Other: "#{{.ID}} {{.Status}} с {{.CreatedAt}}: отправлено {{.Sent}}, ждут {{.Pending}}, ошибки {{.Failed}}, заблокировали {{.Blocked}}"},
+		"command.stopbroadcast.description": {Other: "Остановить рассылку"},
+		"command.schedule.description":      {Other: "Запланировать сообщение в чат"},
+		"command.schedule.help":             {Other: "Отправит текст в чат в указанное время, форматы как у /remind."},
+		"schedule.bad_chat":                 {Other: "ID чата должен быть числом."},
+		"schedule.created":                  {Other: "Сообщение #{{.ID}} запланировано на {{.At}}. Удалить — /unremind."},
+		"command.roles.description":         {Other: "Пользователи с ролями"},
+		"roles.empty":                       {Other: "Ролей ни у кого нет."},
+		"roles.item":                        {Other: "{{.UserID}} — {{.Role}}"},
+		"command.grant.description":         {Other: "Дать роль пользователю"},
+		"command.revoke.description":        {Other: "Забрать роль у пользователя"},
+		"role.granted":                      {Other: "Пользователь {{.UserID}} теперь {{.Role}}."},
+		"role.revoked":                      {Other: "У пользователя {{.UserID}} больше нет роли."},
+		"role.unknown":                      {Other: "Я не знаю такой роли. Доступные: moderator, admin."},
+		"role.bad_user":                     {Other: "ID пользователя должен быть числом."},
+		"role.forbidden":                    {Other: "Ты не можешь менять роль этого пользователя."},
+
+		"flood.warning": {Other: "Слишком много сообщений. Какое-то время я буду их пропускать."},
+		"flood.quota":   {Other: "Дневной лимит сообщений исчерпан. Отвечу снова завтра."},
+		"flood.muted":   {Other: "Слишком много сообщений. Я не буду отвечать {{.Duration}}."},
+
+		"error.try_again": {Other: "Не получилось, попробуй ещё раз."},
+	},
+}
@@ -1,8 +1,66 @@
+// Package metricsware records the bot metrics around the update handlers.
 package metricsware
 
+import (
+	"context"
+
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/tgbot"
+	"go.opencensus.io/tag"
+)
+
+// The update kinds returned by Update.Kind.
+const (
+	KindMessage       = "message"
+	KindEditedMessage = "edited_message"
+	KindCallbackQuery = "callback_query"
+	KindInlineQuery   = "inline_query"
+	KindMyChatMember  = "my_chat_member"
+)
+
+// Update is the incoming update as seen by the Middleware. It is implemented
+// by the updates of the bot client, which this package can not import.
+type Update interface {
+	// Kind returns the kind of the update, e.g. KindMessage, or "" if the
+	// kind is unknown.
+	Kind() string
+	// ChatType returns the type of the chat the update came from, e.g.
+	// "private" or "supergroup", or "" if the update has no chat.
+	ChatType() string
+}
+
+// Handler is the update handler wrapped by the Middleware.
+type Handler = func(ctx context.Context, u Update)
+
 type Middleware struct {
 }
 
 func NewMiddleware() Middleware {
 	return Middleware{}
 }
+
+// Handle wraps the handler to record the incoming updates by their kind. The
+// measures are tagged with the chat type.
+func (m Middleware) Handle(next Handler) Handler {
+	return func(ctx context.Context, u Update) {
+		recordCtx := ctx
+		if chatType := u.ChatType(); chatType != "" {
+			if tagged, err := tag.New(ctx, tag.Upsert(tgbot.ChatTypeKey, chatType)); err == nil {
+				recordCtx = tagged
+			}
+		}
+
+		switch u.Kind() {
+		case KindMessage:
+			m.RecordIncomingMessage(recordCtx)
+		case KindEditedMessage:
+			m.RecordIncomingEditedMessage(recordCtx)
+		case KindCallbackQuery:
+			m.RecordIncomingCallbackQuery(recordCtx)
+		case KindInlineQuery:
+			m.RecordIncomingInlineQuery(recordCtx)
+		case KindMyChatMember:
+			m.RecordIncomingChatMemberUpdate(recordCtx)
+		}
+		next(ctx, u)
+	}
+}
@@ -2,11 +2,19 @@ package metricsware
 
 import (
 	"context"
+	"time"
 
 	"github.com/alienvspredator/simple-tgbot/internal/metrics/tgbot"
 	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
 )
 
+// WithBot tags the measures recorded with the returned context with the name
+// of the bot.
+func WithBot(ctx context.Context, name string) (context.Context, error) {
+	return tag.New(ctx, tag.Upsert(tgbot.BotKey, name))
+}
+
 func (m Middleware) RecordFailedReply(ctx context.Context) {
 	stats.Record(ctx, tgbot.FailedReply.M(1))
 }
@@ -23,6 +31,132 @@ func (m Middleware) RecordIncomingMessage(ctx context.Context) {
 	stats.Record(ctx, tgbot.IncomingMessage.M(1))
 }
 
+func (m Middleware) RecordIncomingEditedMessage(ctx context.Context) {
+	stats.Record(ctx, tgbot.IncomingEditedMessage.M(1))
+}
+
+func (m Middleware) RecordIncomingCallbackQuery(ctx context.Context) {
+	stats.Record(ctx, tgbot.IncomingCallbackQuery.M(1))
+}
+
+func (m Middleware) RecordIncomingInlineQuery(ctx context.Context) {
+	stats.Record(ctx, tgbot.IncomingInlineQuery.M(1))
+}
+
+func (m Middleware) RecordIncomingChatMemberUpdate(ctx context.Context) {
+	stats.Record(ctx, tgbot.IncomingChatMemberUpdate.M(1))
+}
+
+func (m Middleware) RecordInlineQueryLatency(ctx context.Context, d time.Duration) {
+	stats.Record(ctx, tgbot.InlineQueryLatency.M(float64(d)/float64(time.Millisecond)))
+}
+
 func (m Middleware) RecordOutgoingMessage(ctx context.Context) {
 	stats.Record(ctx, tgbot.OutgoingMessage.M(1))
 }
+
+func (m Middleware) RecordHandlerPanic(ctx context.Context) {
+	stats.Record(ctx, tgbot.HandlerPanic.M(1))
+}
+
+func (m Middleware) RecordUnauthorizedUpdate(ctx context.Context) {
+	stats.Record(ctx, tgbot.UnauthorizedUpdate.M(1))
+}
+
+func (m Middleware) RecordUnauthorizedCommand(ctx context.Context) {
+	stats.Record(ctx, tgbot.UnauthorizedCommand.M(1))
+}
+
+func (m Middleware) RecordSendQueueDepth(ctx context.Context, depth int64) {
+	stats.Record(ctx, tgbot.SendQueueDepth.M(depth))
+}
+
+func (m Middleware) RecordSendThrottleDelay(ctx context.Context, d time.Duration) {
+	stats.Record(ctx, tgbot.SendThrottleDelay.M(float64(d)/float64(time.Millisecond)))
+}
+
+func (m Middleware) RecordSendRateLimited(ctx context.Context) {
+	stats.Record(ctx, tgbot.SendRateLimited.M(1))
+}
+
+func (m Middleware) RecordOutboxDeadLetter(ctx context.Context) {
+	stats.Record(ctx, tgbot.OutboxDeadLetter.M(1))
+}
+
+func (m Middleware) RecordMediaStored(ctx context.Context) {
+	stats.Record(ctx, tgbot.MediaStored.M(1))
+}
+
+func (m Middleware) RecordMediaDeduplicated(ctx context.Context) {
+	stats.Record(ctx, tgbot.MediaDeduplicated.M(1))
+}
+
+func (m Middleware) RecordMediaTooLarge(ctx context.Context) {
+	stats.Record(ctx, tgbot.MediaTooLarge.M(1))
+}
+
+func (m Middleware) RecordMediaDownloadFailed(ctx context.Context) {
+	stats.Record(ctx, tgbot.MediaDownloadFailed.M(1))
+}
+
+func (m Middleware) RecordChatMembersJoined(ctx context.Context, n int64) {
+	stats.Record(ctx, tgbot.ChatMembersJoined.M(n))
+}
+
+func (m Middleware) RecordChatMembersLeft(ctx context.Context, n int64) {
+	stats.Record(ctx, tgbot.ChatMembersLeft.M(n))
+}
+
+func (m Middleware) RecordUpdateQueueLength(ctx context.Context, length int64) {
+	stats.Record(ctx, tgbot.UpdateQueueLength.M(length))
+}
+
+func (m Middleware) RecordUpdateLatency(ctx context.Context, d time.Duration) {
+	stats.Record(ctx, tgbot.UpdateLatency.M(float64(d)/float64(time.Millisecond)))
+}
+
+func (m Middleware) RecordAbandonedUpdates(ctx context.Context, n int64) {
+	stats.Record(ctx, tgbot.AbandonedUpdates.M(n))
+}
+
+func (m Middleware) RecordUsersSaved(ctx context.Context, n int64) {
+	stats.Record(ctx, tgbot.UsersSaved.M(n))
+}
+
+func (m Middleware) RecordUserSaveFailure(ctx context.Context) {
+	stats.Record(ctx, tgbot.UserSaveFailed.M(1))
+}
+
+func (m Middleware) RecordUserBlocked(ctx context.Context) {
+	stats.Record(ctx, tgbot.UsersBlocked.M(1))
+}
+
+func (m Middleware) RecordBroadcastSent(ctx context.Context) {
+	stats.Record(ctx, tgbot.BroadcastSent.M(1))
+}
+
+func (m Middleware) RecordBroadcastFailed(ctx context.Context) {
+	stats.Record(ctx, tgbot.BroadcastFailed.M(1))
+}
+
+func (m Middleware) RecordBroadcastFinished(ctx context.Context) {
+	stats.Record(ctx, tgbot.BroadcastsFinished.M(1))
+}
+
+func (m Middleware) RecordDuplicateUpdate(ctx context.Context) {
+	stats.Record(ctx, tgbot.DuplicateUpdates.M(1))
+}
+
+func (m Middleware) RecordScheduledJobRun(ctx context.Context) {
+	stats.Record(ctx, tgbot.ScheduledJobsRun.M(1))
+}
+
+// RecordFloodLimited records the update over an anti-flood limit with the
+// applied action and the exceeded limit.
+func (m Middleware) RecordFloodLimited(ctx context.Context, action, limit string) {
+	_ = stats.RecordWithTags(
+		ctx,
+		[]tag.Mutator{tag.Upsert(tgbot.ActionKey, action), tag.Upsert(tgbot.LimitKey, limit)},
+		tgbot.FloodLimited.M(1),
+	)
+}
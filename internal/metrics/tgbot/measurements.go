@@ -3,6 +3,21 @@ package tgbot
 import (
 	"github.com/alienvspredator/simple-tgbot/internal/metrics"
 	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+var (
+	// BotKey tags all the bot measures with the name of the bot.
+	BotKey = tag.MustNewKey("bot")
+	// ActionKey tags the anti-flood measures with the applied action: drop,
+	// warn or mute.
+	ActionKey = tag.MustNewKey("action")
+	// LimitKey tags the anti-flood measures with the exceeded limit: rate,
+	// quota or muted.
+	LimitKey = tag.MustNewKey("limit")
+	// ChatTypeKey tags the incoming update measures with the type of the
+	// chat: private, group, supergroup or channel.
+	ChatTypeKey = tag.MustNewKey("chat_type")
 )
 
 var (
@@ -28,8 +43,158 @@ var (
 		"Incoming messages", stats.UnitDimensionless,
 	)
 
+	IncomingEditedMessage = stats.Int64(
+		tgbotMetricsPrefix+"incoming_edited_message",
+		"Incoming message edits", stats.UnitDimensionless,
+	)
+
+	IncomingCallbackQuery = stats.Int64(
+		tgbotMetricsPrefix+"incoming_callback_query",
+		"Incoming callback queries", stats.UnitDimensionless,
+	)
+
+	IncomingInlineQuery = stats.Int64(
+		tgbotMetricsPrefix+"incoming_inline_query",
+		"Incoming inline queries", stats.UnitDimensionless,
+	)
+
+	IncomingChatMemberUpdate = stats.Int64(
+		tgbotMetricsPrefix+"incoming_chat_member_update",
+		"Incoming changes of the bot status in the chats", stats.UnitDimensionless,
+	)
+
+	InlineQueryLatency = stats.Float64(
+		tgbotMetricsPrefix+"inline_query_latency",
+		"Time to answer an inline query", stats.UnitMilliseconds,
+	)
+
 	OutgoingMessage = stats.Int64(
 		tgbotMetricsPrefix+"outgoing_message",
 		"Outgoing messages", stats.UnitDimensionless,
 	)
+
+	HandlerPanic = stats.Int64(
+		tgbotMetricsPrefix+"handler_panic",
+		"Panics recovered in update handlers", stats.UnitDimensionless,
+	)
+
+	UnauthorizedUpdate = stats.Int64(
+		tgbotMetricsPrefix+"unauthorized_update",
+		"Updates rejected by authorization", stats.UnitDimensionless,
+	)
+
+	UnauthorizedCommand = stats.Int64(
+		tgbotMetricsPrefix+"unauthorized_command",
+		"Commands rejected because the user lacks the operator role", stats.UnitDimensionless,
+	)
+
+	SendQueueDepth = stats.Int64(
+		tgbotMetricsPrefix+"send_queue_depth",
+		"Messages waiting for the outbound rate limiter", stats.UnitDimensionless,
+	)
+
+	SendThrottleDelay = stats.Float64(
+		tgbotMetricsPrefix+"send_throttle_delay",
+		"Time messages waited for the outbound rate limiter", stats.UnitMilliseconds,
+	)
+
+	SendRateLimited = stats.Int64(
+		tgbotMetricsPrefix+"send_rate_limited",
+		"Sends rejected by Telegram with 429 Too Many Requests", stats.UnitDimensionless,
+	)
+
+	OutboxDeadLetter = stats.Int64(
+		tgbotMetricsPrefix+"outbox_dead_letter",
+		"Outgoing messages moved to the dead letters", stats.UnitDimensionless,
+	)
+
+	MediaStored = stats.Int64(
+		tgbotMetricsPrefix+"media_stored",
+		"Received files written to the blob store", stats.UnitDimensionless,
+	)
+
+	MediaDeduplicated = stats.Int64(
+		tgbotMetricsPrefix+"media_deduplicated",
+		"Received files already in the blob store", stats.UnitDimensionless,
+	)
+
+	MediaTooLarge = stats.Int64(
+		tgbotMetricsPrefix+"media_too_large",
+		"Received files not downloaded because of the size limit", stats.UnitDimensionless,
+	)
+
+	MediaDownloadFailed = stats.Int64(
+		tgbotMetricsPrefix+"media_download_failed",
+		"Errors when downloading received files", stats.UnitDimensionless,
+	)
+
+	ChatMembersJoined = stats.Int64(
+		tgbotMetricsPrefix+"chat_members_joined",
+		"Users joined the group chats", stats.UnitDimensionless,
+	)
+
+	ChatMembersLeft = stats.Int64(
+		tgbotMetricsPrefix+"chat_members_left",
+		"Users left the group chats", stats.UnitDimensionless,
+	)
+
+	UpdateQueueLength = stats.Int64(
+		tgbotMetricsPrefix+"update_queue_length",
+		"Updates waiting for a worker", stats.UnitDimensionless,
+	)
+
+	UpdateLatency = stats.Float64(
+		tgbotMetricsPrefix+"update_latency",
+		"Time from receiving an update to the end of its handling", stats.UnitMilliseconds,
+	)
+
+	AbandonedUpdates = stats.Int64(
+		tgbotMetricsPrefix+"abandoned_updates",
+		"Updates left unhandled when the shutdown timed out", stats.UnitDimensionless,
+	)
+
+	UsersSaved = stats.Int64(
+		tgbotMetricsPrefix+"users_saved",
+		"User profiles saved to the users registry", stats.UnitDimensionless,
+	)
+
+	UserSaveFailed = stats.Int64(
+		tgbotMetricsPrefix+"user_save_failed",
+		"Errors when saving a batch of user profiles", stats.UnitDimensionless,
+	)
+
+	UsersBlocked = stats.Int64(
+		tgbotMetricsPrefix+"users_blocked",
+		"Messages not sent because the user blocked the bot", stats.UnitDimensionless,
+	)
+
+	BroadcastSent = stats.Int64(
+		tgbotMetricsPrefix+"broadcast_sent",
+		"Broadcast messages delivered", stats.UnitDimensionless,
+	)
+
+	BroadcastFailed = stats.Int64(
+		tgbotMetricsPrefix+"broadcast_failed",
+		"Broadcast messages that could not be delivered", stats.UnitDimensionless,
+	)
+
+	BroadcastsFinished = stats.Int64(
+		tgbotMetricsPrefix+"broadcasts_finished",
+		"Broadcasts sent to all their recipients", stats.UnitDimensionless,
+	)
+
+	ScheduledJobsRun = stats.Int64(
+		tgbotMetricsPrefix+"scheduled_jobs_run",
+		"Scheduled jobs, e.g. reminders, that came due and were sent", stats.UnitDimensionless,
+	)
+
+	FloodLimited = stats.Int64(
+		tgbotMetricsPrefix+"flood_limited",
+		"Updates over the anti-flood limits", stats.UnitDimensionless,
+	)
+
+	DuplicateUpdates = stats.Int64(
+		tgbotMetricsPrefix+"duplicate_updates",
+		"Redelivered updates skipped without handling", stats.UnitDimensionless,
+	)
 )
@@ -3,6 +3,7 @@ package tgbot
 import (
 	"github.com/alienvspredator/simple-tgbot/internal/metrics"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 )
 
 var (
@@ -11,30 +12,245 @@ var (
 			Name:        metrics.MetricRoot + "failed_reply_count",
 			Description: "Total number of replies with errors",
 			Measure:     FailedReply,
+			TagKeys:     []tag.Key{BotKey},
 			Aggregation: view.Sum(),
 		},
 		{
 			Name:        metrics.MetricRoot + "send_failed_count",
 			Description: "Total number of message sending errors",
 			Measure:     SendFailed,
+			TagKeys:     []tag.Key{BotKey},
 			Aggregation: view.Sum(),
 		},
 		{
 			Name:        metrics.MetricRoot + "message_save_failed_count",
 			Description: "Total number of message saving errors",
 			Measure:     MessageSaveFailed,
+			TagKeys:     []tag.Key{BotKey},
 			Aggregation: view.Sum(),
 		},
 		{
 			Name:        metrics.MetricRoot + "incoming_messages_count",
 			Description: "Total number of incoming messages",
 			Measure:     IncomingMessage,
+			TagKeys:     []tag.Key{BotKey, ChatTypeKey},
 			Aggregation: view.Sum(),
 		},
+		{
+			Name:        metrics.MetricRoot + "incoming_edited_messages_count",
+			Description: "Total number of incoming message edits",
+			Measure:     IncomingEditedMessage,
+			TagKeys:     []tag.Key{BotKey, ChatTypeKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "incoming_callback_queries_count",
+			Description: "Total number of incoming callback queries",
+			Measure:     IncomingCallbackQuery,
+			TagKeys:     []tag.Key{BotKey, ChatTypeKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "incoming_inline_queries_count",
+			Description: "Total number of incoming inline queries",
+			Measure:     IncomingInlineQuery,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "incoming_chat_member_updates_count",
+			Description: "Total number of incoming changes of the bot status in the chats",
+			Measure:     IncomingChatMemberUpdate,
+			TagKeys:     []tag.Key{BotKey, ChatTypeKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "inline_query_latency",
+			Description: "Distribution of the time to answer an inline query",
+			Measure:     InlineQueryLatency,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Distribution(0, 10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000),
+		},
 		{
 			Name:        metrics.MetricRoot + "outgoing_message",
 			Description: "Total number of outgoing messages",
 			Measure:     OutgoingMessage,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "handler_panic_count",
+			Description: "Total number of panics recovered in update handlers",
+			Measure:     HandlerPanic,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "unauthorized_update_count",
+			Description: "Total number of updates rejected by authorization",
+			Measure:     UnauthorizedUpdate,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "unauthorized_command_count",
+			Description: "Total number of commands rejected because the user lacks the operator role",
+			Measure:     UnauthorizedCommand,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "send_queue_depth",
+			Description: "Number of messages waiting for the outbound rate limiter",
+			Measure:     SendQueueDepth,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        metrics.MetricRoot + "send_throttle_delay",
+			Description: "Distribution of the time messages waited for the outbound rate limiter",
+			Measure:     SendThrottleDelay,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Distribution(0, 10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000),
+		},
+		{
+			Name:        metrics.MetricRoot + "send_rate_limited_count",
+			Description: "Total number of sends rejected by Telegram with 429",
+			Measure:     SendRateLimited,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "outbox_dead_letter_count",
+			Description: "Total number of outgoing messages moved to the dead letters",
+			Measure:     OutboxDeadLetter,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "media_stored_count",
+			Description: "Total number of received files written to the blob store",
+			Measure:     MediaStored,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "media_deduplicated_count",
+			Description: "Total number of received files already in the blob store",
+			Measure:     MediaDeduplicated,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "media_too_large_count",
+			Description: "Total number of received files over the size limit",
+			Measure:     MediaTooLarge,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "media_download_failed_count",
+			Description: "Total number of received file download errors",
+			Measure:     MediaDownloadFailed,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "chat_members_joined_count",
+			Description: "Total number of users joined the group chats",
+			Measure:     ChatMembersJoined,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "chat_members_left_count",
+			Description: "Total number of users left the group chats",
+			Measure:     ChatMembersLeft,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "update_queue_length",
+			Description: "Number of updates waiting for a worker",
+			Measure:     UpdateQueueLength,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        metrics.MetricRoot + "update_latency",
+			Description: "Distribution of the time from receiving an update to the end of its handling",
+			Measure:     UpdateLatency,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Distribution(0, 10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000),
+		},
+		{
+			Name:        metrics.MetricRoot + "abandoned_updates_count",
+			Description: "Total number of updates left unhandled when the shutdown timed out",
+			Measure:     AbandonedUpdates,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "users_saved_count",
+			Description: "Total number of user profiles saved to the users registry",
+			Measure:     UsersSaved,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "user_save_failed_count",
+			Description: "Total number of errors when saving a batch of user profiles",
+			Measure:     UserSaveFailed,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "users_blocked_count",
+			Description: "Total number of messages not sent because the user blocked the bot",
+			Measure:     UsersBlocked,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "broadcast_sent_count",
+			Description: "Total number of broadcast messages delivered",
+			Measure:     BroadcastSent,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "broadcast_failed_count",
+			Description: "Total number of broadcast messages that could not be delivered",
+			Measure:     BroadcastFailed,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "broadcasts_finished_count",
+			Description: "Total number of broadcasts sent to all their recipients",
+			Measure:     BroadcastsFinished,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "scheduled_jobs_run_count",
+			Description: "Total number of scheduled jobs, e.g. reminders, that came due and were sent",
+			Measure:     ScheduledJobsRun,
+			TagKeys:     []tag.Key{BotKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "flood_limited_count",
+			Description: "Total number of updates over the anti-flood limits by action and limit",
+			Measure:     FloodLimited,
+			TagKeys:     []tag.Key{BotKey, ActionKey, LimitKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metrics.MetricRoot + "duplicate_updates_count",
+			Description: "Total number of redelivered updates skipped without handling",
+			Measure:     DuplicateUpdates,
+			TagKeys:     []tag.Key{BotKey},
 			Aggregation: view.Sum(),
 		},
 	}
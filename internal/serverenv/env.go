@@ -7,6 +7,7 @@ import (
 	"github.com/alienvspredator/simple-tgbot/internal/database"
 	"github.com/alienvspredator/simple-tgbot/internal/observability"
 	"github.com/alienvspredator/simple-tgbot/internal/secrets"
+	"github.com/alienvspredator/simple-tgbot/internal/storage"
 	"github.com/hashicorp/go-multierror"
 )
 
@@ -14,6 +15,7 @@ import (
 type ServerEnv struct {
 	database      *database.DB
 	secretManager secrets.SecretManager
+	blobstore     storage.Blobstore
 	// oe observability exporter
 	oe observability.Exporter
 }
@@ -29,6 +31,14 @@ func WithSecretManager(sm secrets.SecretManager) Option {
 	}
 }
 
+// WithBlobStorage creates an Option to install a specific blob store to use.
+func WithBlobStorage(store storage.Blobstore) Option {
+	return func(s *ServerEnv) *ServerEnv {
+		s.blobstore = store
+		return s
+	}
+}
+
 func WithObservabilityExporter(oe observability.Exporter) Option {
 	return func(env *ServerEnv) *ServerEnv {
 		env.oe = oe
@@ -59,6 +69,11 @@ func (s *ServerEnv) SecretManager() secrets.SecretManager {
 	return s.secretManager
 }
 
+// Blobstore returns the blob store or nil if none is configured.
+func (s *ServerEnv) Blobstore() storage.Blobstore {
+	return s.blobstore
+}
+
 func (s *ServerEnv) Database() *database.DB {
 	return s.database
 }
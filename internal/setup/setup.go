@@ -11,6 +11,7 @@ import (
 	"github.com/alienvspredator/simple-tgbot/internal/observability/views"
 	"github.com/alienvspredator/simple-tgbot/internal/secrets"
 	"github.com/alienvspredator/simple-tgbot/internal/serverenv"
+	"github.com/alienvspredator/simple-tgbot/internal/storage"
 	"github.com/alienvspredator/simple-tgbot/internal/zapfluentd"
 	"github.com/sethvargo/go-envconfig"
 )
@@ -33,6 +34,12 @@ type SecretManagerConfigProvider interface {
 	SecretManagerConfig() *secrets.Config
 }
 
+// BlobstoreConfigProvider signals that the config knows how to configure a
+// blob store.
+type BlobstoreConfigProvider interface {
+	BlobstoreConfig() *storage.Config
+}
+
 type FluentConfigProvider interface {
 	FluentConfig() *zapfluentd.Config
 }
@@ -141,6 +148,21 @@ func SetupWith(ctx context.Context, config interface{}, l envconfig.Lookuper) (
 		log.Infow("observability", "config", oeConfig)
 	}
 
+	if provider, ok := config.(BlobstoreConfigProvider); ok {
+		bsConfig := provider.BlobstoreConfig()
+		if bsConfig.BlobstoreType != "" {
+			log.Info("configuring blob store")
+
+			store, err := storage.BlobstoreFor(ctx, bsConfig)
+			if err != nil {
+				return ctx, nil, fmt.Errorf("unable to create blob store: %w", err)
+			}
+
+			serverEnvOpts = append(serverEnvOpts, serverenv.WithBlobStorage(store))
+			log.Infow("blob store", "config", bsConfig)
+		}
+	}
+
 	// Setup the database connection
 	if provider, ok := config.(DatabaseConfigProvider); ok {
 		log.Info("configuring database")
@@ -0,0 +1,18 @@
+package storage
+
+// BlobstoreType represents a type of blob store.
+type BlobstoreType string
+
+const (
+	BlobstoreTypeFilesystem BlobstoreType = "FILESYSTEM"
+	BlobstoreTypeMemory     BlobstoreType = "MEMORY"
+)
+
+// Config represents the config for a blob store. The blob store is disabled
+// when the type is empty.
+type Config struct {
+	BlobstoreType BlobstoreType `env:"BLOBSTORE"`
+	// FilesystemRoot is the directory the FILESYSTEM blob store keeps the
+	// objects in.
+	FilesystemRoot string `env:"BLOBSTORE_FILESYSTEM_ROOT, default=/var/lib/tgbot/blobs"`
+}
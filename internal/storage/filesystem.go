@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Compile-time check to verify implements interface.
+var _ Blobstore = (*FilesystemStorage)(nil)
+
+// FilesystemStorage keeps the objects as files under the root directory, one
+// subdirectory per folder.
+type FilesystemStorage struct {
+	root string
+}
+
+// NewFilesystemStorage creates a blob store in the root directory. The
+// directory is created if it does not exist.
+func NewFilesystemStorage(_ context.Context, root string) (Blobstore, error) {
+	if root == "" {
+		return nil, errors.New("filesystem blob store root is empty")
+	}
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("create blob store root: %w", err)
+	}
+
+	return &FilesystemStorage{root: root}, nil
+}
+
+// CreateObject writes the object to a temporary file and renames it, so the
+// readers never see a partially written object.
+func (s *FilesystemStorage) CreateObject(_ context.Context, folder, name string, contents []byte) error {
+	pth, err := s.path(folder, name)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(pth)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("create folder %s: %w", folder, err)
+	}
+
+	f, err := ioutil.TempFile(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(contents); err != nil {
+		f.Close()
+		return fmt.Errorf("write object %s/%s: %w", folder, name, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write object %s/%s: %w", folder, name, err)
+	}
+
+	if err := os.Rename(f.Name(), pth); err != nil {
+		return fmt.Errorf("write object %s/%s: %w", folder, name, err)
+	}
+	return nil
+}
+
+// GetObject reads the object.
+func (s *FilesystemStorage) GetObject(_ context.Context, folder, name string) ([]byte, error) {
+	pth, err := s.path(folder, name)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadFile(pth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("read object %s/%s: %w", folder, name, err)
+	}
+	return b, nil
+}
+
+// DeleteObject deletes the object.
+func (s *FilesystemStorage) DeleteObject(_ context.Context, folder, name string) error {
+	pth, err := s.path(folder, name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(pth); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete object %s/%s: %w", folder, name, err)
+	}
+	return nil
+}
+
+// path returns the file of the object. The folder and the name must not
+// escape the root.
+func (s *FilesystemStorage) path(folder, name string) (string, error) {
+	for _, part := range []string{folder, name} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", fmt.Errorf("invalid object path %q/%q", folder, name)
+		}
+	}
+	return filepath.Join(s.root, folder, name), nil
+}
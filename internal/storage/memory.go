@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"sync"
+)
+
+// Compile-time check to verify implements interface.
+var _ Blobstore = (*Memory)(nil)
+
+// Memory is an in-memory blob store, primarily used for testing.
+type Memory struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// NewMemory creates a new in-memory blob store.
+func NewMemory(_ context.Context) (Blobstore, error) {
+	return &Memory{
+		objects: make(map[string][]byte),
+	}, nil
+}
+
+// CreateObject saves a copy of the contents.
+func (m *Memory) CreateObject(_ context.Context, folder, name string, contents []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.objects[folder+"/"+name] = append([]byte(nil), contents...)
+	return nil
+}
+
+// GetObject returns a copy of the object contents.
+func (m *Memory) GetObject(_ context.Context, folder, name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.objects[folder+"/"+name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), b...), nil
+}
+
+// DeleteObject deletes the object.
+func (m *Memory) DeleteObject(_ context.Context, folder, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.objects, folder+"/"+name)
+	return nil
+}
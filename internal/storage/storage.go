@@ -0,0 +1,39 @@
+// Package storage defines a minimum abstract interface for a blob store.
+// Allows for a different implementation to be bound within the ServeEnv.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned when the object does not exist.
+var ErrNotFound = errors.New("storage object not found")
+
+// Blobstore defines the minimum interface for a blob storage system. The
+// objects are grouped into folders.
+type Blobstore interface {
+	// CreateObject creates or overwrites the object.
+	CreateObject(ctx context.Context, folder, name string, contents []byte) error
+
+	// GetObject returns the contents of the object or ErrNotFound.
+	GetObject(ctx context.Context, folder, name string) ([]byte, error)
+
+	// DeleteObject deletes the object. It is not an error if the object does
+	// not exist.
+	DeleteObject(ctx context.Context, folder, name string) error
+}
+
+// BlobstoreFor returns the blob store for the given config, or an error if
+// the type does not exist.
+func BlobstoreFor(ctx context.Context, config *Config) (Blobstore, error) {
+	switch config.BlobstoreType {
+	case BlobstoreTypeFilesystem:
+		return NewFilesystemStorage(ctx, config.FilesystemRoot)
+	case BlobstoreTypeMemory:
+		return NewMemory(ctx)
+	}
+
+	return nil, fmt.Errorf("unknown blob store type: %v", config.BlobstoreType)
+}
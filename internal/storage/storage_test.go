@@ -0,0 +1,112 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/alienvspredator/simple-tgbot/internal/storage"
+)
+
+func TestBlobstore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "blobstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	tests := []struct {
+		name   string
+		config *storage.Config
+	}{
+		{
+			name:   "filesystem",
+			config: &storage.Config{BlobstoreType: storage.BlobstoreTypeFilesystem, FilesystemRoot: dir},
+		},
+		{
+			name:   "memory",
+			config: &storage.Config{BlobstoreType: storage.BlobstoreTypeMemory},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				store, err := storage.BlobstoreFor(ctx, tc.config)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := store.GetObject(ctx, "media", "a"); !errors.Is(err, storage.ErrNotFound) {
+					t.Errorf("error does not match, got = %v, want = %v", err, storage.ErrNotFound)
+				}
+
+				for _, contents := range []string{"first", "second"} {
+					if err := store.CreateObject(ctx, "media", "a", []byte(contents)); err != nil {
+						t.Fatal(err)
+					}
+					got, err := store.GetObject(ctx, "media", "a")
+					if err != nil {
+						t.Fatal(err)
+					}
+					if string(got) != contents {
+						t.Errorf("contents do not match, got = %q, want = %q", got, contents)
+					}
+				}
+
+				if err := store.DeleteObject(ctx, "media", "a"); err != nil {
+					t.Fatal(err)
+				}
+				if err := store.DeleteObject(ctx, "media", "a"); err != nil {
+					t.Errorf("delete of a missing object: %v", err)
+				}
+				if _, err := store.GetObject(ctx, "media", "a"); !errors.Is(err, storage.ErrNotFound) {
+					t.Errorf("error does not match, got = %v, want = %v", err, storage.ErrNotFound)
+				}
+			},
+		)
+	}
+
+	t.Run(
+		"unknown type", func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := storage.BlobstoreFor(ctx, &storage.Config{BlobstoreType: "S3"}); err == nil {
+				t.Error("expected error for unknown blob store type")
+			}
+		},
+	)
+}
+
+func TestFilesystemStorage_InvalidPath(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "blobstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	store, err := storage.NewFilesystemStorage(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"", "..", "a/b", `a\b`} {
+		if err := store.CreateObject(ctx, "media", name, []byte("x")); err == nil {
+			t.Errorf("expected error for object name %q", name)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/i18n"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// The access rule actions and kinds.
+const (
+	accessAllow = "allow"
+	accessDeny  = "deny"
+
+	accessUser     = "user"
+	accessChat     = "chat"
+	accessUsername = "username"
+)
+
+// accessRulesKey is the access cache key of the rule list.
+const accessRulesKey = "rules"
+
+// accessSubject is the sender and the chat of an update checked against the
+// access rules. The fields are empty when the update has no sender or chat.
+type accessSubject struct {
+	userID   string
+	username string
+	chatID   string
+}
+
+func updateSubject(u *telegram.Update) accessSubject {
+	var s accessSubject
+	if from := updateSender(u); from != nil {
+		s.userID = strconv.Itoa(from.ID)
+		s.username = from.Username
+	}
+	if m := updateMessage(u); m != nil {
+		s.chatID = m.Chat.ID
+	} else if cm := u.MyChatMember; cm != nil {
+		s.chatID = cm.Chat.ID
+	}
+	return s
+}
+
+// ruleMatches reports whether the rule applies to the subject. The username
+// patterns are matched case-insensitively with path.Match.
+func ruleMatches(r *model.AccessRule, s accessSubject) bool {
+	switch r.Kind {
+	case accessUser:
+		return s.userID != "" && r.Value == s.userID
+	case accessChat:
+		return s.chatID != "" && r.Value == s.chatID
+	case accessUsername:
+		if s.username == "" {
+			return false
+		}
+		ok, _ := path.Match(strings.ToLower(r.Value), strings.ToLower(s.username))
+		return ok
+	default:
+		return false
+	}
+}
+
+// checkAccess returns an error wrapping ErrUnauthorized if the subject is
+// denied. A matching deny rule wins over the allow rules. In the allowlist
+// mode the subject must match an allow rule.
+func checkAccess(rules []*model.AccessRule, s accessSubject, allowlistOnly bool) error {
+	allowed := !allowlistOnly
+	for _, r := range rules {
+		if !ruleMatches(r, s) {
+			continue
+		}
+		switch r.Action {
+		case accessDeny:
+			return fmt.Errorf("denied by access rule %d: %w", r.ID, ErrUnauthorized)
+		case accessAllow:
+			allowed = true
+		}
+	}
+
+	if !allowed {
+		return fmt.Errorf("not in the allowlist: %w", ErrUnauthorized)
+	}
+	return nil
+}
+
+// accessPolicy rejects the updates denied by the access rules. The operators
+// are never rejected, so they can not lock themselves out. The rejected
+// messages are saved if the configuration asks so.
+func (b *Bot) accessPolicy(ctx context.Context, u *telegram.Update) error {
+	if b.userRole(ctx, updateSender(u)) > RoleNone {
+		return nil
+	}
+
+	rules, err := b.accessRules(ctx)
+	if err != nil {
+		if b.config.AllowlistOnly {
+			return err
+		}
+		// Without the rules the public bot keeps answering.
+		logging.FromContext(ctx).Errorw("get access rules", zap.Error(err))
+		return nil
+	}
+
+	if err := checkAccess(rules, updateSubject(u), b.config.AllowlistOnly); err != nil {
+		if b.config.StoreDeniedMessages && u.Message != nil && u.Message.From != nil && u.Message.Text != "" {
+			b.saveMessage(ctx, u.Message)
+		}
+		return err
+	}
+	return nil
+}
+
+// accessRules returns the access rules. There are none without the database.
+func (b *Bot) accessRules(ctx context.Context) ([]*model.AccessRule, error) {
+	if b.db == nil {
+		return nil, nil
+	}
+
+	v, err := b.accessCache.WriteThruLookup(
+		accessRulesKey, func() (interface{}, error) {
+			return b.db.ListAccessRules(ctx)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return v.([]*model.AccessRule), nil
+}
+
+// refreshAccessRules reloads the cached rules after a change.
+func (b *Bot) refreshAccessRules(ctx context.Context) {
+	rules, err := b.db.ListAccessRules(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("get access rules", zap.Error(err))
+		return
+	}
+	if err := b.accessCache.Set(accessRulesKey, rules); err != nil {
+		logging.FromContext(ctx).Errorw("cache access rules", zap.Error(err))
+	}
+}
+
+// validAccessRule reports whether the rule value fits its kind: a number
+// for the rules by ID and a valid pattern for the rules by username.
+func validAccessRule(kind, value string) bool {
+	switch kind {
+	case accessUser, accessChat:
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	case accessUsername:
+		_, err := path.Match(value, "")
+		return err == nil && value != ""
+	default:
+		return false
+	}
+}
+
+// Rules lists the access rules.
+func (b *Bot) Rules(ctx context.Context, m *tbot.Message, _ Args) {
+	rules, err := b.accessRules(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("get access rules", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	if len(rules) == 0 {
+		b.reply(ctx, m, b.t(ctx, "rules.empty", nil))
+		return
+	}
+
+	lines := make([]string, 0, len(rules))
+	for _, r := range rules {
+		line := fmt.Sprintf("#%d %s %s %s", r.ID, r.Action, r.Kind, r.Value)
+		if r.Note != "" {
+			line += " — " + r.Note
+		}
+		lines = append(lines, line)
+	}
+	b.reply(ctx, m, strings.Join(lines, "\n"))
+}
+
+// Allow adds an allow rule.
+func (b *Bot) Allow(ctx context.Context, m *tbot.Message, args Args) {
+	b.addAccessRule(ctx, m, accessAllow, args)
+}
+
+// Deny adds a deny rule.
+func (b *Bot) Deny(ctx context.Context, m *tbot.Message, args Args) {
+	b.addAccessRule(ctx, m, accessDeny, args)
+}
+
+func (b *Bot) addAccessRule(ctx context.Context, m *tbot.Message, action string, args Args) {
+	log := logging.FromContext(ctx)
+
+	r := &model.AccessRule{
+		Action: action,
+		Kind:   strings.ToLower(args.Get("kind")),
+		Value:  args.Get("value"),
+		Note:   args.Get("note"),
+	}
+	if r.Kind == accessUsername {
+		r.Value = strings.ToLower(strings.TrimPrefix(r.Value, "@"))
+	}
+	if !validAccessRule(r.Kind, r.Value) {
+		b.reply(ctx, m, b.t(ctx, "rules.invalid", nil))
+		return
+	}
+	if m.From != nil {
+		r.CreatedBy = int64(m.From.ID)
+	}
+
+	if b.db == nil {
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	if err := b.db.AddAccessRule(ctx, r); err != nil {
+		log.Errorw("add access rule", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	b.refreshAccessRules(ctx)
+	log.Infow("access rule added", "rule_id", r.ID, "action", r.Action, "kind", r.Kind, "value", r.Value)
+
+	b.reply(ctx, m, b.t(ctx, "rules.added", i18n.Params{"ID": r.ID}))
+}
+
+// Unrule deletes the access rule.
+func (b *Bot) Unrule(ctx context.Context, m *tbot.Message, args Args) {
+	log := logging.FromContext(ctx)
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(args.Get("id"), "#"), 10, 64)
+	if err != nil {
+		cmd, _ := b.commands.lookup("unrule")
+		b.reply(ctx, m, b.t(ctx, "command.usage", i18n.Params{"Usage": cmd.Usage()}))
+		return
+	}
+
+	if b.db == nil {
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	switch err := b.db.DeleteAccessRule(ctx, id); {
+	case errors.Is(err, database.ErrNotFound):
+		b.reply(ctx, m, b.t(ctx, "rules.not_found", nil))
+		return
+	case err != nil:
+		log.Errorw("delete access rule", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	b.refreshAccessRules(ctx)
+	log.Infow("access rule deleted", "rule_id", id)
+
+	b.reply(ctx, m, b.t(ctx, "rules.deleted", i18n.Params{"ID": id}))
+}
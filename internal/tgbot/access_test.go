@@ -0,0 +1,77 @@
+package tgbot
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+)
+
+func TestCheckAccess(t *testing.T) {
+	t.Parallel()
+
+	rules := []*model.AccessRule{
+		{ID: 1, Action: accessAllow, Kind: accessUser, Value: "7"},
+		{ID: 2, Action: accessAllow, Kind: accessChat, Value: "-100"},
+		{ID: 3, Action: accessDeny, Kind: accessUser, Value: "8"},
+		{ID: 4, Action: accessDeny, Kind: accessUsername, Value: "*_spam_bot"},
+	}
+
+	tests := []struct {
+		name          string
+		subject       accessSubject
+		allowlistOnly bool
+		wantDenied    bool
+	}{
+		{
+			name:    "no rule",
+			subject: accessSubject{userID: "9", chatID: "9"},
+		},
+		{
+			name:       "denied user",
+			subject:    accessSubject{userID: "8", chatID: "8"},
+			wantDenied: true,
+		},
+		{
+			name:       "deny wins over allowed chat",
+			subject:    accessSubject{userID: "8", chatID: "-100"},
+			wantDenied: true,
+		},
+		{
+			name:       "username pattern",
+			subject:    accessSubject{userID: "9", username: "Best_Spam_Bot", chatID: "9"},
+			wantDenied: true,
+		},
+		{
+			name:          "allowlist user",
+			subject:       accessSubject{userID: "7", chatID: "7"},
+			allowlistOnly: true,
+		},
+		{
+			name:          "allowlist chat",
+			subject:       accessSubject{userID: "9", chatID: "-100"},
+			allowlistOnly: true,
+		},
+		{
+			name:          "not in allowlist",
+			subject:       accessSubject{userID: "9", chatID: "9"},
+			allowlistOnly: true,
+			wantDenied:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				err := checkAccess(rules, tc.subject, tc.allowlistOnly)
+				if denied := errors.Is(err, ErrUnauthorized); denied != tc.wantDenied {
+					t.Errorf("denied does not match, got = %t (%v), want = %t", denied, err, tc.wantDenied)
+				}
+			},
+		)
+	}
+}
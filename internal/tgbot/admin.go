@@ -0,0 +1,288 @@
+package tgbot
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alienvspredator/simple-tgbot/internal/i18n"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultFailures and maxFailures limit the dead letters shown by
+	// /failures.
+	defaultFailures = 5
+	maxFailures     = 20
+)
+
+// adminModule handles the operator commands. They are hidden from the bot
+// menu and listed by /admin.
+type adminModule struct{}
+
+func (adminModule) Name() string {
+	return moduleAdmin
+}
+
+func (adminModule) Attach(b *Bot, _ interface{}) error {
+	b.AddCommand(&Command{
+		Name:        "admin",
+		Description: "command.admin.description",
+		Hidden:      true,
+		Role:        RoleModerator,
+		Run:         b.Admin,
+	})
+	b.AddCommand(&Command{
+		Name:        "stats",
+		Description: "command.stats.description",
+		Hidden:      true,
+		Role:        RoleModerator,
+		Run:         b.Stats,
+	})
+	b.AddCommand(&Command{
+		Name:        "failures",
+		Description: "command.failures.description",
+		Args:        []Arg{{Name: "count", Optional: true}},
+		Hidden:      true,
+		Role:        RoleAdmin,
+		Run:         b.Failures,
+	})
+	b.AddCommand(&Command{
+		Name:        "feature",
+		Description: "command.feature.description",
+		Args:        []Arg{{Name: "feature", Optional: true}, {Name: "mode", Optional: true}},
+		Hidden:      true,
+		Role:        RoleAdmin,
+		Run:         b.Feature,
+	})
+	b.AddCommand(&Command{
+		Name:        "rules",
+		Description: "command.rules.description",
+		Hidden:      true,
+		Role:        RoleAdmin,
+		Run:         b.Rules,
+	})
+	b.AddCommand(&Command{
+		Name:        "allow",
+		Description: "command.allow.description",
+		Args:        []Arg{{Name: "kind"}, {Name: "value"}, {Name: "note", Optional: true, Rest: true}},
+		Hidden:      true,
+		Role:        RoleAdmin,
+		Run:         b.Allow,
+	})
+	b.AddCommand(&Command{
+		Name:        "deny",
+		Description: "command.deny.description",
+		Args:        []Arg{{Name: "kind"}, {Name: "value"}, {Name: "note", Optional: true, Rest: true}},
+		Hidden:      true,
+		Role:        RoleModerator,
+		Run:         b.Deny,
+	})
+	b.AddCommand(&Command{
+		Name:        "unrule",
+		Description: "command.unrule.description",
+		Args:        []Arg{{Name: "id"}},
+		Hidden:      true,
+		Role:        RoleAdmin,
+		Run:         b.Unrule,
+	})
+	b.AddCommand(&Command{
+		Name:        "roles",
+		Description: "command.roles.description",
+		Hidden:      true,
+		Role:        RoleAdmin,
+		Run:         b.Roles,
+	})
+	b.AddCommand(&Command{
+		Name:        "grant",
+		Description: "command.grant.description",
+		Args:        []Arg{{Name: "user_id"}, {Name: "role"}},
+		Hidden:      true,
+		Role:        RoleAdmin,
+		Run:         b.Grant,
+	})
+	b.AddCommand(&Command{
+		Name:        "revoke",
+		Description: "command.revoke.description",
+		Args:        []Arg{{Name: "user_id"}},
+		Hidden:      true,
+		Role:        RoleAdmin,
+		Run:         b.Revoke,
+	})
+
+	return nil
+}
+
+// Admin lists the operator commands available to the user.
+func (b *Bot) Admin(ctx context.Context, m *tbot.Message, _ Args) {
+	role := b.userRole(ctx, m.From)
+
+	var sb strings.Builder
+	sb.WriteString(b.t(ctx, "admin.header", i18n.Params{"Role": role.String()}))
+	sb.WriteString("\n")
+	for _, cmd := range b.commands.commands {
+		if cmd.Role == RoleNone || cmd.Role > role {
+			continue
+		}
+		sb.WriteString("\n")
+		sb.WriteString(cmd.Usage() + " — " + b.t(ctx, cmd.Description, nil))
+	}
+	b.reply(ctx, m, sb.String())
+}
+
+// Stats shows the totals of the users, the chats and the outgoing messages.
+func (b *Bot) Stats(ctx context.Context, m *tbot.Message, _ Args) {
+	if b.db == nil {
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+
+	s, err := b.db.GetStats(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("get stats", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+
+	types := make([]string, 0, len(s.Chats))
+	for t := range s.Chats {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	chats := make([]string, 0, len(types))
+	for _, t := range types {
+		chats = append(chats, t+": "+strconv.FormatInt(s.Chats[t], 10))
+	}
+
+	b.reply(ctx, m, b.t(ctx, "stats.text", i18n.Params{
+		"Users":         s.Users,
+		"BlockedUsers":  s.BlockedUsers,
+		"Chats":         strings.Join(chats, ", "),
+		"OutboxPending": s.OutboxPending,
+		"DeadLetters":   s.DeadLetters,
+	}))
+}
+
+// Failures shows the most recent messages that could not be delivered.
+func (b *Bot) Failures(ctx context.Context, m *tbot.Message, args Args) {
+	limit := defaultFailures
+	if v := args.Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			cmd, _ := b.commands.lookup("failures")
+			b.reply(ctx, m, b.t(ctx, "command.usage", i18n.Params{"Usage": cmd.Usage()}))
+			return
+		}
+		limit = n
+	}
+	if limit > maxFailures {
+		limit = maxFailures
+	}
+
+	if b.db == nil {
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	letters, err := b.db.ListDeadLetters(ctx, limit)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("list dead letters", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	if len(letters) == 0 {
+		b.reply(ctx, m, b.t(ctx, "failures.empty", nil))
+		return
+	}
+
+	lines := make([]string, 0, len(letters))
+	for _, l := range letters {
+		lines = append(lines, b.t(ctx, "failures.item", i18n.Params{
+			"ID":       l.ID,
+			"ChatID":   l.ChatID,
+			"FailedAt": l.FailedAt.UTC().Format("2006-01-02 15:04"),
+			"Error":    l.LastError,
+		}))
+	}
+	b.reply(ctx, m, strings.Join(lines, "\n"))
+}
+
+// Roles lists the owners from the configuration and the users with a role.
+func (b *Bot) Roles(ctx context.Context, m *tbot.Message, _ Args) {
+	if b.db == nil {
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+
+	roles, err := b.db.ListUserRoles(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("list user roles", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+
+	lines := make([]string, 0, len(b.config.OwnerIDs)+len(roles))
+	for _, id := range b.config.OwnerIDs {
+		lines = append(lines, b.t(ctx, "roles.item", i18n.Params{"UserID": id, "Role": RoleOwner.String()}))
+	}
+	for _, r := range roles {
+		if role, _ := parseRole(r.Role); role == RoleOwner {
+			continue
+		}
+		lines = append(lines, b.t(ctx, "roles.item", i18n.Params{"UserID": r.UserID, "Role": r.Role}))
+	}
+	if len(lines) == 0 {
+		b.reply(ctx, m, b.t(ctx, "roles.empty", nil))
+		return
+	}
+	b.reply(ctx, m, strings.Join(lines, "\n"))
+}
+
+// Grant gives the role to the user. The operators grant only the roles
+// below their own, the owners grant the admin role too.
+func (b *Bot) Grant(ctx context.Context, m *tbot.Message, args Args) {
+	role, ok := parseRole(strings.ToLower(args.Get("role")))
+	if !ok {
+		b.reply(ctx, m, b.t(ctx, "role.unknown", nil))
+		return
+	}
+	b.changeRole(ctx, m, args.Get("user_id"), role)
+}
+
+// Revoke takes the role away from the user.
+func (b *Bot) Revoke(ctx context.Context, m *tbot.Message, args Args) {
+	b.changeRole(ctx, m, args.Get("user_id"), RoleNone)
+}
+
+func (b *Bot) changeRole(ctx context.Context, m *tbot.Message, rawUserID string, role Role) {
+	log := logging.FromContext(ctx)
+
+	userID, err := strconv.ParseInt(rawUserID, 10, 64)
+	if err != nil || userID <= 0 {
+		b.reply(ctx, m, b.t(ctx, "role.bad_user", nil))
+		return
+	}
+
+	actor := b.userRole(ctx, m.From)
+	current := b.roleOf(ctx, userID)
+	if b.isConfigOwner(userID) || !mayManage(actor, current) || !mayManage(actor, role) {
+		log.Warnw("role change forbidden", "target_user_id", userID, "role", role.String())
+		b.reply(ctx, m, b.t(ctx, "role.forbidden", nil))
+		return
+	}
+
+	if err := b.setRole(ctx, userID, role, int64(m.From.ID)); err != nil {
+		log.Errorw("set user role", "target_user_id", userID, zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	log.Infow("user role changed", "target_user_id", userID, "role", role.String())
+
+	if role == RoleNone {
+		b.reply(ctx, m, b.t(ctx, "role.revoked", i18n.Params{"UserID": userID}))
+		return
+	}
+	b.reply(ctx, m, b.t(ctx, "role.granted", i18n.Params{"UserID": userID, "Role": role.String()}))
+}
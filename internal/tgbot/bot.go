@@ -3,129 +3,531 @@ package tgbot
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/alienvspredator/simple-tgbot/internal/i18n"
 	"github.com/alienvspredator/simple-tgbot/internal/logging"
 	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
 	"github.com/alienvspredator/simple-tgbot/internal/serverenv"
+	"github.com/alienvspredator/simple-tgbot/internal/storage"
 	"github.com/alienvspredator/simple-tgbot/internal/tgbot/database"
 	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/alienvspredator/simple-tgbot/pkg/cache"
 	"github.com/yanzay/tbot/v2"
 	"go.uber.org/zap"
 )
 
 type Bot struct {
-	env    *serverenv.ServerEnv
-	api    *tbot.Server
-	db     *database.TgBotDB
+	env *serverenv.ServerEnv
+	api telegram.API
+	// db is nil when the environment has no database. The features that
+	// store data are disabled then.
+	db *database.TgBotDB
+	// blobs is nil when the environment has no blob store. The received
+	// files are not downloaded then.
+	blobs  storage.Blobstore
 	config *Config
+
+	catalog *i18n.Catalog
+	// localeCache holds the locales chosen by the users, keyed by user ID.
+	localeCache *cache.Cache
+	// timeZoneCache holds the time zones chosen by the users, keyed by user
+	// ID.
+	timeZoneCache *cache.Cache
+	// chatSettingsCache holds the chat configurations, keyed by chat ID.
+	chatSettingsCache *cache.Cache
+	// chatCache holds the last saved chat profiles, keyed by chat ID.
+	chatCache *cache.Cache
+	// roleCache and featureCache hold the user roles, keyed by user ID, and
+	// the feature switches, keyed by feature name.
+	roleCache    *cache.Cache
+	featureCache *cache.Cache
+	// accessCache holds the access rules.
+	accessCache *cache.Cache
+	// botID and username identify the bot's Telegram user, used to
+	// recognize the commands, mentions and replies addressed to it.
+	botID    int
+	username string
+	// webhookSecret is the secret token of the webhook requests, see
+	// Config.webhookSecret.
+	webhookSecret string
+	// webhookServer is the server shared with the other bots of the
+	// process, see ServeBots. The bot listens on its own when it is nil.
+	webhookServer *webhookServer
+
+	commands        *commandRegistry
+	messageHandlers []messageHandler
+	stateHandlers   map[string]StateFunc
+	workers         *workerPool
+	updateLog       *updateLog
+	users           *userRecorder
+	flood           *floodGuard
+	// moduleWorkers are the background workers of the modules.
+	moduleWorkers []Worker
+
+	callbackHandlers map[string]CallbackFunc
+	// callbackHandler is HandleCallbackQuery wrapped with the middlewares.
+	callbackHandler Handler
+
+	inlineProviders []InlineProvider
+	// inlineCache holds the answers to the inline queries, keyed by user,
+	// query and offset.
+	inlineCache *cache.Cache
+	// inlineHandler is HandleInlineQuery wrapped with the middlewares.
+	inlineHandler Handler
+	// editedHandler is HandleEditedMessage wrapped with the middlewares.
+	editedHandler Handler
+	// mediaHandler is HandleMedia wrapped with the middlewares.
+	mediaHandler Handler
+	// listenHandler and chatEventHandler are Listen and HandleChatEvent
+	// wrapped with the middlewares.
+	listenHandler    Handler
+	chatEventHandler Handler
+	// chatMemberHandler is HandleMyChatMember wrapped with the middlewares.
+	chatMemberHandler Handler
 }
 
 // New builds a new bot application.
-func New(env *serverenv.ServerEnv, config *Config) *Bot {
-	return &Bot{
-		env:    env,
-		api:    tbot.New(config.TelegramToken),
-		db:     database.New(env.Database()),
-		config: config,
+func New(env *serverenv.ServerEnv, config *Config) (*Bot, error) {
+	if err := config.validateModules(); err != nil {
+		return nil, err
+	}
+
+	catalog, err := i18n.NewCatalog(config.DefaultLocale)
+	if err != nil {
+		return nil, fmt.Errorf("i18n.NewCatalog: %w", err)
+	}
+
+	localeCache, err := cache.New(config.UserSettingsCacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("cache.New: %w", err)
+	}
+
+	timeZoneCache, err := cache.New(config.UserSettingsCacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("cache.New: %w", err)
+	}
+
+	chatSettingsCache, err := cache.New(config.ChatSettingsCacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("cache.New: %w", err)
+	}
+
+	chatCache, err := cache.New(config.ChatCacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("cache.New: %w", err)
+	}
+
+	roleCache, err := cache.New(config.AdminCacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("cache.New: %w", err)
+	}
+
+	featureCache, err := cache.New(config.AdminCacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("cache.New: %w", err)
+	}
+
+	accessCache, err := cache.New(config.AdminCacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("cache.New: %w", err)
+	}
+
+	inlineCache, err := cache.New(config.InlineCacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("cache.New: %w", err)
+	}
+
+	webhookSecret, err := config.webhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bot{
+		env:   env,
+		blobs: env.Blobstore(),
+		api: telegram.NewLimiter(
+			telegram.New(config.TelegramToken, telegram.WithBaseURL(config.TelegramAPIURL)),
+			config.sendLimits(),
+		),
+		config:            config,
+		catalog:           catalog,
+		localeCache:       localeCache,
+		timeZoneCache:     timeZoneCache,
+		chatSettingsCache: chatSettingsCache,
+		chatCache:         chatCache,
+		roleCache:         roleCache,
+		featureCache:      featureCache,
+		accessCache:       accessCache,
+		inlineCache:       inlineCache,
+		commands:          newCommandRegistry(),
+		flood:             newFloodGuard(config),
+		updateLog:         newUpdateLog(config),
+		webhookSecret:     webhookSecret,
+
+		stateHandlers:    make(map[string]StateFunc),
+		callbackHandlers: make(map[string]CallbackFunc),
+	}
+	if env.Database() != nil {
+		b.db = database.New(env.Database()).ForBot(config.botName())
+		b.users = newUserRecorder(
+			b.db.UpsertUsers, b.db.SetUserBlocked, config.UserBatchSize, config.UserFlushInterval,
+		)
+		b.updateLog.db = b.db
+		if config.FloodPersist {
+			b.flood.save = b.db.SaveFloodCounters
+		}
 	}
+
+	return b, nil
 }
 
-// Serve runs the application loop. It stops on context done
+// Serve runs the application loop. Updates are received with a webhook when
+// the public URL and the port are configured, and with long polling
+// otherwise. On context done it stops receiving updates and waits for the
+// received ones to be handled, see shutdown.
 func (b *Bot) Serve(ctx context.Context) error {
-	log := logging.FromContext(ctx)
+	log := logging.FromContext(ctx).With("bot", b.config.botName())
+	ctx = logging.WithLogger(ctx, log)
+
+	ctx, err := metricsware.WithBot(ctx, b.config.botName())
+	if err != nil {
+		return fmt.Errorf("tag bot metrics: %w", err)
+	}
+
+	me, err := b.api.GetMe(ctx)
+	if err != nil {
+		return fmt.Errorf("get bot user: %w", err)
+	}
+	b.botID, b.username = me.ID, me.Username
+	b.updateLog.botID = int64(me.ID)
+
+	if err := b.attachHandlers(ctx); err != nil {
+		return err
+	}
+
+	// The handlers outlive ctx to finish the received updates. They are
+	// canceled only if the shutdown takes too long.
+	workCtx, cancelWork := context.WithCancel(detach(ctx))
+	defer cancelWork()
+
+	b.workers = newWorkerPool(workCtx, b.config.Workers, b.config.WorkerQueueSize, b.handleUpdateOnce)
+
+	b.setCommandMenus(ctx)
+
+	var background sync.WaitGroup
+	if b.db != nil {
+		if err := b.updateLog.load(ctx); err != nil {
+			log.Errorw("load update offset", zap.Error(err))
+		}
+
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			b.updateLog.run(workCtx)
+		}()
+
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			b.dispatchOutbox(workCtx, ctx.Done())
+		}()
+	}
+	for _, w := range b.moduleWorkers {
+		w := w
+
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			w(workCtx, ctx.Done())
+		}()
+	}
+	if b.flood.save != nil {
+		counters, err := b.db.ListFloodCounters(ctx, time.Now().UTC().Truncate(24*time.Hour))
+		if err != nil {
+			log.Errorw("load flood counters", zap.Error(err))
+		}
+		b.flood.load(counters)
+
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			b.flood.run(workCtx)
+		}()
+	}
+	if b.users != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			b.users.run(workCtx)
+		}()
+	}
 
-	go func() {
-		<-ctx.Done()
-		log.Info("stopping the receiving updates")
-		b.api.Stop()
-		log.Debug("the update receiver stopped")
-	}()
+	var receiveErr error
+	if b.config.webhookEnabled() {
+		log.Infow("receiving updates with webhook", "url", b.config.webhookURL())
+		receiveErr = b.serveWebhook(ctx)
+	} else {
+		log.Info("receiving updates with long polling")
+		receiveErr = b.pollUpdates(ctx)
+	}
+	log.Debug("the update receiver stopped")
+
+	b.shutdown(workCtx, cancelWork, &background)
+
+	return receiveErr
+}
 
-	b.attachHandlers(ctx)
+// attachHandlers registers the core commands and handlers and attaches the
+// enabled modules.
+func (b *Bot) attachHandlers(ctx context.Context) error {
+	b.commands.register(&Command{
+		Name:        "start",
+		Description: "command.start.description",
+		Hidden:      true,
+		Run:         b.Help,
+	})
+	b.commands.register(&Command{
+		Name:        "help",
+		Description: "command.help.description",
+		Help:        "command.help.help",
+		Args:        []Arg{{Name: "command", Optional: true}},
+		Run:         b.Help,
+	})
+	b.commands.register(&Command{
+		Name:        "language",
+		Description: "command.language.description",
+		Help:        "command.language.help",
+		Args:        []Arg{{Name: "language", Optional: true}},
+		Run:         b.Language,
+	})
+	b.commands.register(&Command{
+		Name:        "silent",
+		Description: "command.silent.description",
+		Help:        "command.silent.help",
+		Args:        []Arg{{Name: "mode", Optional: true}},
+		Run:         b.Silent,
+	})
 
-	if err := b.api.Start(); err != nil {
-		return fmt.Errorf("start listening updates: %w", err)
+	b.HandleCallback(languageRoute, b.LanguageCallback)
+	b.callbackHandler = Chain(b.HandleCallbackQuery, b.middlewares()...)
+	b.inlineHandler = Chain(b.HandleInlineQuery, b.middlewares()...)
+	b.editedHandler = Chain(b.HandleEditedMessage, b.middlewares()...)
+	b.listenHandler = Chain(b.Listen, b.listenMiddlewares()...)
+	b.mediaHandler = b.listenHandler
+	b.chatEventHandler = Chain(b.HandleChatEvent, b.listenMiddlewares()...)
+	b.chatMemberHandler = Chain(b.HandleMyChatMember, b.middlewares()...)
+
+	b.handleMessage("^/.*", b.HandleCommand)
+	if err := b.attachModules(ctx); err != nil {
+		return err
+	}
+	if len(b.stateHandlers) > 0 {
+		b.commands.register(&Command{
+			Name:        "cancel",
+			Description: "command.cancel.description",
+			Run:         b.Cancel,
+		})
 	}
+	// The messages no module handles are only saved.
+	b.handleMessage(".*", b.withState(b.Listen))
 
 	return nil
 }
 
-func (b *Bot) attachHandlers(ctx context.Context) {
-	b.api.HandleMessage("^/.*", b.EchoError(ctx))
-	b.api.HandleMessage(".*", b.Echo(ctx))
+// middlewares are applied to every handler on registration. The denied
+// updates are counted, but they do not reach the registries or the locale
+// lookup.
+func (b *Bot) middlewares() []Middleware {
+	return []Middleware{
+		Recover(),
+		Trace(),
+		Logging(),
+		Metrics(),
+		Authorize(b.accessPolicy),
+		b.Localize(),
+		b.TrackUsers(),
+		b.TrackChats(),
+		b.Throttle(),
+	}
 }
 
-func (b *Bot) EchoError(ctx context.Context) func(m *tbot.Message) {
-	return func(m *tbot.Message) {
-		log := logging.FromContext(ctx).With(
-			"chat_id", m.Chat.ID,
-			"incoming:message_id", m.MessageID,
+// listenMiddlewares are applied to the handlers of the updates the bot does
+// not answer, like the messages in the silent chats or the ones not
+// addressed to it in the groups. The flood limits count them, but never
+// drop them or reply to them.
+func (b *Bot) listenMiddlewares() []Middleware {
+	mws := b.middlewares()
+	mws[len(mws)-1] = b.CountFlood()
+	return mws
+}
+
+// HandleCommand routes the command message to the registered command.
+// Commands addressed to other bots with the /cmd@BotName form are ignored.
+func (b *Bot) HandleCommand(ctx context.Context, u *telegram.Update) {
+	m := u.Message
+
+	call, ok := parseCommand(m.Text)
+	if !ok {
+		b.unknownCommand(ctx, m, call)
+		return
+	}
+	if call.mention != "" && !strings.EqualFold(call.mention, b.username) {
+		return
+	}
+
+	cmd, ok := b.commands.lookup(call.name)
+	if !ok {
+		b.unknownCommand(ctx, m, call)
+		return
+	}
+	if !b.authorized(ctx, m, cmd) {
+		return
+	}
+
+	args, err := cmd.parseArgs(call.rawArgs)
+	if err != nil {
+		logging.FromContext(ctx).Infow(
+			"invalid command arguments", "command", cmd.Name, zap.Error(err),
 		)
-		metricsMW := metricsware.NewMiddleware()
-		metricsMW.RecordIncomingMessage(ctx)
-
-		log.Infow("got message", "text", m.Text)
-		metricsMW.RecordFailedReply(ctx)
-		metricsMW.RecordOutgoingMessage(ctx)
-		if answer, err := b.api.Client().SendMessage(
-			m.Chat.ID, "Я тебя не понимаю!", tbot.OptReplyToMessageID(m.MessageID),
-		); err != nil {
-			metricsMW.RecordSendFailure(ctx)
-			log.Errorw("send answer", zap.Error(err))
-		} else {
-			log.Infow("send answer", "answer:message_id", answer.MessageID, "text", answer.Text)
-		}
+		metricsware.NewMiddleware().RecordFailedReply(ctx)
+		b.reply(ctx, m, b.t(ctx, "command.usage", i18n.Params{"Usage": cmd.Usage()}))
+		return
 	}
+
+	cmd.Run(ctx, m, args)
 }
 
-func (b *Bot) Echo(ctx context.Context) func(m *tbot.Message) {
-	return func(m *tbot.Message) {
-		log := logging.FromContext(ctx).With(
-			"chat_id", m.Chat.ID,
-			"incoming:message_id", m.MessageID,
-		)
-		metricsMW := metricsware.NewMiddleware()
-		metricsMW.RecordIncomingMessage(ctx)
-		log.Infow("got message", "text", m.Text)
-
-		if err := b.api.Client().SendChatAction(m.Chat.ID, tbot.ActionTyping); err != nil {
-			log.Errorw("send typing action", zap.Error(err))
-		} else {
-			// Insert in separate goroutine
-			go func() {
-				if err := b.db.AddUserMessage(
-					ctx, &model.Message{
-						TgMessageID: int64(m.MessageID),
-						UserID:      int64(m.From.ID),
-						ChatID:      m.Chat.ID,
-						Text:        m.Text,
-					},
-				); err != nil {
-					metricsMW.RecordMessageSaveFailure(ctx)
-					log.Errorw(
-						"failed to save user message", "tg_message_id",
-						m.MessageID, "user_id",
-						m.From.ID, "chat_id",
-						m.Chat.ID,
-						"text", m.Text,
-						zap.Error(err),
-					)
-					return
-				}
-			}()
-			time.Sleep(time.Second * 1)
+func (b *Bot) unknownCommand(ctx context.Context, m *tbot.Message, call commandCall) {
+	logging.FromContext(ctx).Infow("got unknown command", "command", call.name)
+	metricsware.NewMiddleware().RecordFailedReply(ctx)
+
+	text := b.t(ctx, "command.unknown", nil)
+	if suggestion, ok := b.commands.suggest(call.name); ok {
+		text += " " + b.t(ctx, "command.suggestion", i18n.Params{"Command": suggestion})
+	}
+	b.reply(ctx, m, text)
+}
+
+// Help lists the visible commands or describes the one given as the
+// argument.
+func (b *Bot) Help(ctx context.Context, m *tbot.Message, args Args) {
+	if name := strings.TrimPrefix(args.Get("command"), "/"); name != "" {
+		cmd, ok := b.commands.lookup(strings.ToLower(name))
+		if !ok || cmd.Hidden {
+			b.unknownCommand(ctx, m, commandCall{name: name})
+			return
 		}
 
-		metricsMW.RecordOutgoingMessage(ctx)
-		if answer, err := b.api.Client().SendMessage(
-			m.Chat.ID, m.Text, tbot.OptReplyToMessageID(m.MessageID),
-		); err != nil {
-			metricsMW.RecordSendFailure(ctx)
-			log.Errorw("send answer", zap.Error(err))
-		} else {
-			log.Infow("send answer", "answer:message_id", answer.MessageID, "text", answer.Text)
+		text := cmd.Usage() + "\n" + b.t(ctx, cmd.Description, nil)
+		if cmd.Help != "" {
+			text += "\n\n" + b.t(ctx, cmd.Help, nil)
 		}
+		b.reply(ctx, m, text)
+		return
+	}
+
+	l := b.localizer(ctx)
+	cmds := b.commands.visible()
+
+	var sb strings.Builder
+	sb.WriteString(l.Plural("help.header", len(cmds), nil))
+	sb.WriteString("\n")
+	for _, cmd := range cmds {
+		sb.WriteString("\n")
+		sb.WriteString(l.Get("help.command", i18n.Params{
+			"Command":     cmd.Name,
+			"Description": l.Get(cmd.Description, nil),
+		}))
+	}
+	b.reply(ctx, m, sb.String())
+}
+
+// reply sends the text as a reply to the message.
+func (b *Bot) reply(ctx context.Context, m *tbot.Message, text string) {
+	b.send(ctx, &model.OutboxMessage{
+		ChatID:           m.Chat.ID,
+		Text:             text,
+		ReplyToMessageID: int64(m.MessageID),
+	})
+}
+
+// replyWithKeyboard sends the text with the keyboard as a reply to the
+// message. The keyboard is one of the markups accepted by
+// telegram.OptReplyMarkup.
+func (b *Bot) replyWithKeyboard(ctx context.Context, m *tbot.Message, text string, keyboard interface{}) {
+	markup, err := json.Marshal(keyboard)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("encode keyboard", zap.Error(err))
+		b.reply(ctx, m, text)
+		return
+	}
+
+	b.send(ctx, &model.OutboxMessage{
+		ChatID:           m.Chat.ID,
+		Text:             text,
+		ReplyToMessageID: int64(m.MessageID),
+		ReplyMarkup:      string(markup),
+	})
+}
+
+// echoModule answers the text messages with their text.
+type echoModule struct{}
+
+func (echoModule) Name() string {
+	return moduleEcho
+}
+
+func (echoModule) Attach(b *Bot, _ interface{}) error {
+	b.HandleMessage(".*", b.Echo)
+	return nil
+}
+
+// Echo saves the message and sends its text back. Messages without text,
+// e.g. locations, are ignored.
+func (b *Bot) Echo(ctx context.Context, u *telegram.Update) {
+	m := u.Message
+	log := logging.FromContext(ctx)
+
+	if m.Text == "" {
+		return
+	}
+
+	if err := b.api.SendChatAction(ctx, m.Chat.ID, telegram.ActionTyping); err != nil {
+		log.Errorw("send typing action", zap.Error(err))
+	}
+
+	b.saveMessage(ctx, m)
+	b.reply(ctx, m, m.Text)
+}
+
+// saveMessage saves the text message.
+func (b *Bot) saveMessage(ctx context.Context, m *tbot.Message) {
+	if b.db == nil {
+		return
+	}
+
+	if err := b.db.AddUserMessage(
+		ctx, &model.Message{
+			TgMessageID: int64(m.MessageID),
+			UserID:      int64(m.From.ID),
+			ChatID:      m.Chat.ID,
+			Text:        m.Text,
+		},
+	); err != nil {
+		metricsware.NewMiddleware().RecordMessageSaveFailure(ctx)
+		logging.FromContext(ctx).Errorw(
+			"failed to save user message", "tg_message_id",
+			m.MessageID, "user_id",
+			m.From.ID, "chat_id",
+			m.Chat.ID,
+			"text", m.Text,
+			zap.Error(err),
+		)
 	}
 }
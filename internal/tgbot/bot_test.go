@@ -2,10 +2,18 @@ package tgbot_test
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/alienvspredator/simple-tgbot/internal/serverenv"
 	"github.com/alienvspredator/simple-tgbot/internal/tgbot"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram/telegramtest"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/tgbottest"
+	"github.com/yanzay/tbot/v2"
 )
 
 func TestNew(t *testing.T) {
@@ -19,12 +27,744 @@ func TestNew(t *testing.T) {
 			config := &tgbot.Config{
 				TelegramToken: "TESTING_TOKEN",
 				Debug:         false,
+				DefaultLocale: "ru",
 			}
 
-			bot := tgbot.New(env, config)
+			bot, err := tgbot.New(env, config)
+			if err != nil {
+				t.Fatal(err)
+			}
 			if bot == nil {
 				t.Fatal("bot was not created")
 			}
 		},
 	)
+
+	t.Run(
+		"unknown default locale", func(t *testing.T) {
+			t.Parallel()
+
+			env := serverenv.New(context.Background())
+			config := &tgbot.Config{
+				TelegramToken: "TESTING_TOKEN",
+				DefaultLocale: "xx",
+			}
+
+			if _, err := tgbot.New(env, config); err == nil {
+				t.Error("expected error for unknown default locale")
+			}
+		},
+	)
+}
+
+// serveBot runs the bot against a fake Bot API until the test ends.
+func serveBot(t *testing.T) *telegramtest.Server {
+	t.Helper()
+	return serveBotWith(t, nil, nil)
+}
+
+// serveBotWith runs the bot like serveBot, see tgbottest.Serve.
+func serveBotWith(t *testing.T, configure func(*tgbot.Config), setup func(*tgbot.Bot)) *telegramtest.Server {
+	t.Helper()
+	return tgbottest.Serve(t, configure, setup)
+}
+
+func TestBot_Commands(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		text     string
+		wantText string
+	}{
+		{
+			name:     "help",
+			text:     "/help",
+			wantText: "/help — Список команд",
+		},
+		{
+			name:     "help addressed to the bot",
+			text:     "/help@" + telegramtest.BotUsername,
+			wantText: "Доступно 7 команд:",
+		},
+		{
+			name:     "help for command",
+			text:     "/help help",
+			wantText: "/help [command]",
+		},
+		{
+			name:     "unknown with suggestion",
+			text:     "/hlep",
+			wantText: "Может быть, /help?",
+		},
+		{
+			name:     "unknown",
+			text:     "/weather",
+			wantText: "Я тебя не понимаю!",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				srv := serveBot(t)
+
+				messageID := srv.AddMessage(42, 7, tc.text)
+
+				sent, err := srv.WaitForSentMessages(1, 5*time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := sent[0]; !strings.Contains(got.Text, tc.wantText) {
+					t.Errorf("reply text %q does not contain %q", got.Text, tc.wantText)
+				}
+				if got := sent[0]; got.ChatID != "42" || got.ReplyToMessageID != messageID {
+					t.Errorf(
+						"reply target does not match, got = %s/%d, want = 42/%d",
+						got.ChatID, got.ReplyToMessageID, messageID,
+					)
+				}
+			},
+		)
+	}
+}
+
+func TestBot_AdminCommands(t *testing.T) {
+	t.Parallel()
+
+	const ownerID = 7
+
+	tests := []struct {
+		name     string
+		userID   int
+		text     string
+		wantText string
+	}{
+		{
+			name:     "admin by the owner",
+			userID:   ownerID,
+			text:     "/admin",
+			wantText: "Твоя роль: owner. Команды:",
+		},
+		{
+			name:     "admin lists the commands",
+			userID:   ownerID,
+			text:     "/admin",
+			wantText: "/grant <user_id> <role> — Дать роль пользователю",
+		},
+		{
+			name:     "admin by a user",
+			userID:   8,
+			text:     "/admin",
+			wantText: "Я тебя не понимаю!",
+		},
+		{
+			name:     "grant by a user",
+			userID:   8,
+			text:     "/grant 8 owner",
+			wantText: "Я тебя не понимаю!",
+		},
+		{
+			name:     "features",
+			userID:   ownerID,
+			text:     "/feature",
+			wantText: "inline: включено\nmedia: включено",
+		},
+		{
+			name:     "unknown feature",
+			userID:   ownerID,
+			text:     "/feature weather",
+			wantText: "Я не знаю такой функции. Доступные: inline, media.",
+		},
+		{
+			name:     "unknown role",
+			userID:   ownerID,
+			text:     "/grant 8 king",
+			wantText: "Я не знаю такой роли.",
+		},
+		{
+			name:     "grant owner",
+			userID:   ownerID,
+			text:     "/grant 8 owner",
+			wantText: "Ты не можешь менять роль этого пользователя.",
+		},
+		{
+			name:     "configured owner",
+			userID:   ownerID,
+			text:     "/revoke 7",
+			wantText: "Ты не можешь менять роль этого пользователя.",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				srv := serveBotWith(
+					t, func(config *tgbot.Config) {
+						config.OwnerIDs = []int64{ownerID}
+					}, nil,
+				)
+
+				srv.AddMessage(42, tc.userID, tc.text)
+
+				sent, err := srv.WaitForSentMessages(1, 5*time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := sent[0].Text; !strings.Contains(got, tc.wantText) {
+					t.Errorf("reply text %q does not contain %q", got, tc.wantText)
+				}
+			},
+		)
+	}
+}
+
+func TestBot_CommandForAnotherBot(t *testing.T) {
+	t.Parallel()
+
+	srv := serveBot(t)
+
+	srv.AddMessage(42, 7, "/help@other_bot")
+	srv.AddMessage(42, 7, "/help")
+
+	sent, err := srv.WaitForSentMessages(1, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Give the bot a chance to answer the first message as well.
+	time.Sleep(100 * time.Millisecond)
+	if sent = srv.SentMessages(); len(sent) != 1 {
+		t.Errorf("expected only one reply, got = %d", len(sent))
+	}
+}
+
+func TestBot_SendFailure(t *testing.T) {
+	t.Parallel()
+
+	srv := serveBot(t)
+
+	srv.FailNext("sendMessage", http.StatusForbidden, "Forbidden: bot was blocked by the user", 0)
+	srv.AddMessage(42, 7, "/help")
+	srv.AddMessage(42, 7, "/help")
+
+	if _, err := srv.WaitForSentMessages(1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if calls := srv.Calls("sendMessage"); len(calls) != 2 {
+		t.Errorf("expected two sendMessage calls, got = %d", len(calls))
+	}
+}
+
+func TestBot_SetMyCommands(t *testing.T) {
+	t.Parallel()
+
+	srv := serveBot(t)
+
+	// The menu is set before the updates are polled.
+	srv.AddMessage(42, 7, "/help")
+	if _, err := srv.WaitForSentMessages(1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := srv.Commands("")
+	if len(commands) != 7 || commands[0].Command != "help" || commands[0].Description != "Список команд" {
+		t.Errorf("unexpected bot menu: %+v", commands)
+	}
+
+	commands = srv.Commands("en")
+	if len(commands) != 7 || commands[0].Description != "List of commands" {
+		t.Errorf("unexpected english bot menu: %+v", commands)
+	}
+}
+
+func TestBot_LanguageCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		languageCode string
+		wantText     string
+	}{
+		{
+			name:         "english",
+			languageCode: "en",
+			wantText:     "I don't understand you!",
+		},
+		{
+			name:         "regional english",
+			languageCode: "en-GB",
+			wantText:     "I don't understand you!",
+		},
+		{
+			name:         "unknown language",
+			languageCode: "de",
+			wantText:     "Я тебя не понимаю!",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				srv := serveBot(t)
+
+				srv.AddUpdate(map[string]interface{}{
+					"message": map[string]interface{}{
+						"message_id": 1,
+						"date":       time.Now().Unix(),
+						"from": map[string]interface{}{
+							"id": 7, "first_name": "User", "language_code": tc.languageCode,
+						},
+						"chat": map[string]interface{}{"id": 42, "type": "private"},
+						"text": "/weather",
+					},
+				})
+
+				sent, err := srv.WaitForSentMessages(1, 5*time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := sent[0].Text; got != tc.wantText {
+					t.Errorf("reply text does not match, got = %q, want = %q", got, tc.wantText)
+				}
+			},
+		)
+	}
+}
+
+func TestBot_EditedMessage(t *testing.T) {
+	t.Parallel()
+
+	srv := serveBot(t)
+
+	id := srv.AddMessage(42, 7, "hello")
+	srv.EditMessage(42, 7, id, "hello, world")
+	srv.AddMessage(42, 7, "bye")
+
+	msgs, err := srv.WaitForSentMessages(2, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The updates of a chat are handled in order, so the edit was handled
+	// before the last message. The edits are saved, not echoed.
+	var texts []string
+	for _, m := range msgs {
+		texts = append(texts, m.Text)
+	}
+	if want := []string{"hello", "bye"}; !reflect.DeepEqual(texts, want) {
+		t.Errorf("sent messages do not match, got = %q, want = %q", texts, want)
+	}
+}
+
+func TestBot_Media(t *testing.T) {
+	t.Parallel()
+
+	srv := serveBot(t)
+
+	fileID := srv.AddFile("unique-1", []byte("%PDF"))
+	id := srv.AddDocument(42, 7, fileID, "application/pdf", "")
+	srv.AddUpdate(map[string]interface{}{
+		"message": map[string]interface{}{
+			"message_id": 1000,
+			"date":       time.Now().Unix(),
+			"from":       map[string]interface{}{"id": 7, "first_name": "User"},
+			"chat":       map[string]interface{}{"id": 42, "type": "private"},
+			"location":   map[string]interface{}{"latitude": 55.75, "longitude": 37.62},
+		},
+	})
+	srv.AddMessage(42, 7, "bye")
+
+	msgs, err := srv.WaitForSentMessages(2, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The message without text and file is not echoed.
+	var texts []string
+	for _, m := range msgs {
+		texts = append(texts, m.Text)
+	}
+	if want := []string{"Файл получен.", "bye"}; !reflect.DeepEqual(texts, want) {
+		t.Errorf("sent messages do not match, got = %q, want = %q", texts, want)
+	}
+	if got := msgs[0].ReplyToMessageID; got != id {
+		t.Errorf("reply to message ID does not match, got = %d, want = %d", got, id)
+	}
+
+	// Without a database and a blob store the file is not downloaded.
+	if calls := srv.Calls("getFile"); len(calls) != 0 {
+		t.Errorf("getFile calls do not match, got = %d, want = 0", len(calls))
+	}
+}
+
+func TestBot_Group(t *testing.T) {
+	t.Parallel()
+
+	bot := map[string]interface{}{"id": 1, "is_bot": true, "first_name": "Test", "username": telegramtest.BotUsername}
+
+	tests := []struct {
+		name     string
+		text     string
+		fields   map[string]interface{}
+		admin    bool
+		wantText string
+	}{
+		{
+			name: "not addressed",
+			text: "hello",
+		},
+		{
+			name: "mention of another user",
+			text: "@someone hello",
+			fields: map[string]interface{}{
+				"entities": []map[string]interface{}{{"type": "mention", "offset": 0, "length": 8}},
+			},
+		},
+		{
+			name: "mention",
+			text: "😀 @Test_Bot hello",
+			fields: map[string]interface{}{
+				"entities": []map[string]interface{}{{"type": "mention", "offset": 3, "length": 9}},
+			},
+			wantText: "😀 @Test_Bot hello",
+		},
+		{
+			name: "reply to the bot",
+			text: "hello",
+			fields: map[string]interface{}{
+				"reply_to_message": map[string]interface{}{
+					"message_id": 1,
+					"date":       time.Now().Unix(),
+					"from":       bot,
+					"chat":       map[string]interface{}{"id": 42, "type": "supergroup"},
+					"text":       "hi",
+				},
+			},
+			wantText: "hello",
+		},
+		{
+			name:     "command",
+			text:     "/silent",
+			wantText: "Я отвечаю на сообщения.",
+		},
+		{
+			name:     "silent mode by a member",
+			text:     "/silent on",
+			wantText: "Менять режим могут только администраторы чата.",
+		},
+		{
+			// The bot runs without a database, so the mode cannot be saved.
+			name:     "silent mode by an administrator",
+			text:     "/silent on",
+			admin:    true,
+			wantText: "Не получилось, попробуй ещё раз.",
+		},
+		{
+			name: "new members",
+			fields: map[string]interface{}{
+				"new_chat_members": []map[string]interface{}{
+					{"id": 8, "first_name": "Ann"},
+					{"id": 9, "first_name": "Bob"},
+					{"id": 10, "is_bot": true, "first_name": "Robot"},
+				},
+			},
+			wantText: "Добро пожаловать, Ann, Bob!",
+		},
+		{
+			name: "bot added",
+			fields: map[string]interface{}{
+				"new_chat_members": []map[string]interface{}{bot},
+			},
+			wantText: "Привет! В группах я отвечаю только на упоминания, ответы на мои сообщения и команды.",
+		},
+		{
+			name: "member left",
+			fields: map[string]interface{}{
+				"left_chat_member": map[string]interface{}{"id": 8, "first_name": "Ann"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				srv := serveBot(t)
+				if tc.admin {
+					srv.SetChatMember(42, 7, "administrator")
+				}
+
+				srv.AddGroupMessage(42, 7, tc.text, tc.fields)
+				// The updates of a chat are handled in order, so the answer
+				// to the message, if any, comes before the one to the ping.
+				srv.AddGroupMessage(42, 7, "/start", nil)
+
+				want := []string{"Доступно 7 команд:"}
+				if tc.wantText != "" {
+					want = append([]string{tc.wantText}, want...)
+				}
+
+				msgs, err := srv.WaitForSentMessages(len(want), 5*time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(msgs) != len(want) {
+					t.Fatalf("sent messages count does not match, got = %d, want = %d", len(msgs), len(want))
+				}
+				for i, m := range msgs {
+					if !strings.HasPrefix(m.Text, want[i]) {
+						t.Errorf("sent message %d does not match, got = %q, want = %q", i, m.Text, want[i])
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestBot_LanguageKeyboard(t *testing.T) {
+	t.Parallel()
+
+	srv := serveBot(t)
+
+	srv.AddMessage(42, 7, "/language")
+	sent, err := srv.WaitForSentMessages(1, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var markup tbot.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(sent[0].Params.Get("reply_markup")), &markup); err != nil {
+		t.Fatalf("decode reply markup: %v", err)
+	}
+	if len(markup.InlineKeyboard) != 1 || len(markup.InlineKeyboard[0]) != 2 {
+		t.Fatalf("unexpected language keyboard: %+v", markup.InlineKeyboard)
+	}
+	if got := markup.InlineKeyboard[0][0]; got.Text != "English" || got.CallbackData != "lang|en" {
+		t.Errorf("unexpected language button: %+v", got)
+	}
+}
+
+func TestBot_CallbackQuery(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		data          string
+		wantText      string
+		wantShowAlert bool
+	}{
+		{
+			name:     "unknown route",
+			data:     "nope|1",
+			wantText: "Эта кнопка больше не работает.",
+		},
+		{
+			name:     "unknown language",
+			data:     "lang|xx",
+			wantText: "Эта кнопка больше не работает.",
+		},
+		{
+			// The bot runs without a database, so the language cannot be
+			// saved.
+			name:          "language without database",
+			data:          "lang|en",
+			wantText:      "Не получилось, попробуй ещё раз.",
+			wantShowAlert: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				srv := serveBot(t)
+
+				id := srv.AddCallbackQuery(42, 7, 100, tc.data)
+
+				calls, err := srv.WaitForCalls("answerCallbackQuery", 1, 5*time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				params := calls[0].Params
+				if got := params.Get("callback_query_id"); got != id {
+					t.Errorf("callback query ID does not match, got = %s, want = %s", got, id)
+				}
+				if got := params.Get("text"); got != tc.wantText {
+					t.Errorf("answer text does not match, got = %q, want = %q", got, tc.wantText)
+				}
+				if got := params.Get("show_alert") == "true"; got != tc.wantShowAlert {
+					t.Errorf("show alert does not match, got = %v, want = %v", got, tc.wantShowAlert)
+				}
+			},
+		)
+	}
+}
+
+func TestBot_MyChatMember(t *testing.T) {
+	t.Parallel()
+
+	srv := serveBot(t)
+
+	// The bot status changes are recorded without an answer.
+	srv.AddMyChatMember(-100, "supergroup", 7, "left", "member")
+	srv.AddMyChatMember(7, "private", 7, "member", "kicked")
+	srv.AddMyChatMember(7, "private", 7, "kicked", "member")
+	srv.AddMessage(7, 7, "hello")
+
+	sent, err := srv.WaitForSentMessages(1, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sent[0].Text; got != "hello" {
+		t.Errorf("answer does not match, got = %q, want = %q", got, "hello")
+	}
+}
+
+func TestBot_AllowlistOnly(t *testing.T) {
+	t.Parallel()
+
+	srv := serveBotWith(
+		t, func(config *tgbot.Config) {
+			config.AllowlistOnly = true
+			config.OwnerIDs = []int64{7}
+		}, nil,
+	)
+
+	srv.AddMessage(8, 8, "denied")
+	srv.AddMessage(7, 7, "allowed")
+
+	if _, err := srv.WaitForSentMessages(1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// The updates are handled in order per chat only, give the denied one
+	// time to be answered by mistake.
+	time.Sleep(100 * time.Millisecond)
+
+	sent := srv.SentMessages()
+	if len(sent) != 1 || sent[0].ChatID != "7" || sent[0].Text != "allowed" {
+		t.Errorf("sent messages do not match, got = %+v, want only the answer to 7", sent)
+	}
+}
+
+func TestBot_Flood(t *testing.T) {
+	t.Parallel()
+
+	srv := serveBotWith(
+		t, func(config *tgbot.Config) {
+			config.FloodUserRate = 2
+			config.FloodUserWindow = time.Hour
+			config.FloodAction = "warn"
+		}, nil,
+	)
+
+	for _, text := range []string{"one", "two", "three", "four"} {
+		srv.AddMessage(7, 7, text)
+	}
+
+	if _, err := srv.WaitForSentMessages(3, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// Give the last message time to be answered by mistake.
+	time.Sleep(100 * time.Millisecond)
+
+	sent := srv.SentMessages()
+	want := []string{"one", "two", "Слишком много сообщений. Какое-то время я буду их пропускать."}
+	if len(sent) != len(want) {
+		t.Fatalf("sent messages do not match, got = %+v, want = %q", sent, want)
+	}
+	for i := range want {
+		if sent[i].Text != want[i] {
+			t.Errorf("message %d does not match, got = %q, want = %q", i, sent[i].Text, want[i])
+		}
+	}
+}
+
+func TestBot_FloodNotAddressed(t *testing.T) {
+	t.Parallel()
+
+	srv := serveBotWith(
+		t, func(config *tgbot.Config) {
+			config.FloodUserRate = 2
+			config.FloodUserWindow = time.Hour
+			config.FloodAction = "warn"
+		}, nil,
+	)
+
+	// The group messages not addressed to the bot are counted, but not
+	// answered even over the limit.
+	for _, text := range []string{"one", "two", "three", "four"} {
+		srv.AddGroupMessage(-100, 7, text, nil)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if sent := srv.SentMessages(); len(sent) != 0 {
+		t.Fatalf("not addressed messages were answered, got = %+v", sent)
+	}
+
+	// The warning goes to the first answered message.
+	srv.AddGroupMessage(-100, 7, "/help", nil)
+	if _, err := srv.WaitForSentMessages(1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	sent := srv.SentMessages()
+	want := "Слишком много сообщений. Какое-то время я буду их пропускать."
+	if len(sent) != 1 || sent[0].Text != want {
+		t.Errorf("sent messages do not match, got = %+v, want only %q", sent, want)
+	}
+}
+
+func TestBot_Modules(t *testing.T) {
+	t.Parallel()
+
+	srv := serveBotWith(t, func(c *tgbot.Config) { c.Modules = []string{"echo"} }, nil)
+
+	srv.AddMessage(42, 7, "/remind 10m tea")
+	sent, err := srv.WaitForSentMessages(1, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sent[0].Text, "Я тебя не понимаю!"; !strings.HasPrefix(got, want) {
+		t.Errorf("reply text does not match, got = %q, want prefix = %q", got, want)
+	}
+
+	srv.AddMessage(42, 7, "hello")
+	sent, err = srv.WaitForSentMessages(2, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sent[1].Text, "hello"; got != want {
+		t.Errorf("echo text does not match, got = %q, want = %q", got, want)
+	}
+}
+
+func TestNew_UnknownModules(t *testing.T) {
+	t.Parallel()
+
+	env := serverenv.New(context.Background())
+	config := &tgbot.Config{
+		TelegramToken: "TESTING_TOKEN",
+		DefaultLocale: "ru",
+		Modules:       []string{"weather"},
+	}
+	if _, err := tgbot.New(env, config); err == nil {
+		t.Error("expected error for unknown module")
+	}
 }
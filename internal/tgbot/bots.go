@@ -0,0 +1,203 @@
+package tgbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/alienvspredator/simple-tgbot/internal/secrets"
+	"github.com/alienvspredator/simple-tgbot/internal/serverenv"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/database"
+	"github.com/sethvargo/go-envconfig"
+	"go.uber.org/multierr"
+)
+
+// botNameRx matches the valid bot names.
+var botNameRx = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// BotDefinition defines one of the bots served by the process.
+type BotDefinition struct {
+	// Name identifies the bot, see Config.BotName.
+	Name string `json:"name"`
+	// Token is the bot token or a secret:// reference to it.
+	Token string `json:"token"`
+	// Modules are the enabled modules, see Config.Modules.
+	Modules []string `json:"modules"`
+	// Settings override the process environment for the bot and its
+	// modules, keyed by the environment variable names, e.g.
+	// {"DEFAULT_LOCALE": "en", "FAQ_ENTRIES": "..."}.
+	Settings map[string]string `json:"settings"`
+}
+
+// BotDefinitions is the JSON list of the bot definitions, e.g.
+//
+//	[{"name": "support", "token": "secret://support-token", "modules": ["admin"]}]
+type BotDefinitions []*BotDefinition
+
+// EnvDecode decodes the definitions from the environment variable.
+func (d *BotDefinitions) EnvDecode(val string) error {
+	if strings.TrimSpace(val) == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(val), d); err != nil {
+		return fmt.Errorf("decoding bot definitions: %w", err)
+	}
+	return nil
+}
+
+// botName returns the name of the bot, DefaultBot if it has none.
+func (c *Config) botName() string {
+	if c.BotName == "" {
+		return database.DefaultBot
+	}
+	return c.BotName
+}
+
+// BotConfigs returns the configs of the bots defined by Bots. The settings of
+// a bot are looked up in its definition first and in l next, so the bots
+// share the process environment and override a part of it. The tokens and
+// the settings can be secret:// references resolved with sm. The shared
+// parts, e.g. the database, are copied from c. Without Bots it returns c.
+// The module configs are processed the same way, see ModuleConfig.
+//
+// The webhook bots share the port and are told apart by the path, which is
+// /webhook/<name> unless the bot settings give WEBHOOK_PATH.
+func (c *Config) BotConfigs(
+	ctx context.Context, l envconfig.Lookuper, sm secrets.SecretManager,
+) ([]*Config, error) {
+	if len(c.Bots) == 0 {
+		if err := c.processModules(ctx, l, secrets.Resolver(sm, &c.SecretManager)); err != nil {
+			return nil, err
+		}
+		return []*Config{c}, nil
+	}
+
+	configs := make([]*Config, 0, len(c.Bots))
+	names := make(map[string]bool, len(c.Bots))
+	paths := make(map[string]string, len(c.Bots))
+	for _, def := range c.Bots {
+		switch {
+		case !botNameRx.MatchString(def.Name):
+			return nil, fmt.Errorf("invalid bot name %q", def.Name)
+		case names[def.Name]:
+			return nil, fmt.Errorf("bot %q is defined twice", def.Name)
+		case def.Token == "":
+			return nil, fmt.Errorf("bot %q has no token", def.Name)
+		}
+		names[def.Name] = true
+
+		settings := make(map[string]string, len(def.Settings)+3)
+		for k, v := range def.Settings {
+			settings[k] = v
+		}
+		settings["BOT_NAME"] = def.Name
+		settings["TG_TOKEN"] = def.Token
+		if _, ok := settings["WEBHOOK_PATH"]; !ok {
+			settings["WEBHOOK_PATH"] = "/webhook/" + def.Name
+		}
+		if len(def.Modules) > 0 {
+			settings["MODULES"] = strings.Join(def.Modules, ",")
+		}
+
+		config := &Config{
+			Database:              c.Database,
+			SecretManager:         c.SecretManager,
+			Fluent:                c.Fluent,
+			Blobstore:             c.Blobstore,
+			ObservabilityExporter: c.ObservabilityExporter,
+		}
+		lookuper := envconfig.MultiLookuper(envconfig.MapLookuper(settings), l)
+		resolver := secrets.Resolver(sm, &c.SecretManager)
+		if err := envconfig.ProcessWith(ctx, config, lookuper, resolver); err != nil {
+			return nil, fmt.Errorf("bot %q: %w", def.Name, err)
+		}
+		config.Bots = nil
+
+		if err := config.processModules(ctx, lookuper, resolver); err != nil {
+			return nil, fmt.Errorf("bot %q: %w", def.Name, err)
+		}
+		if config.webhookEnabled() {
+			addr := config.WebhookPort + config.WebhookPath
+			if other, ok := paths[addr]; ok {
+				return nil, fmt.Errorf(
+					"bots %q and %q listen on port %s with path %s",
+					other, def.Name, config.WebhookPort, config.WebhookPath,
+				)
+			}
+			paths[addr] = def.Name
+		}
+
+		configs = append(configs, config)
+	}
+
+	return configs, nil
+}
+
+// ServeBots serves a bot per config until the context is done. The bots
+// share the environment: the database pool, the secret manager and the
+// observability exporter. The webhook bots listening on the same port share
+// one HTTP server. A bot that fails stops the others.
+func ServeBots(ctx context.Context, env *serverenv.ServerEnv, configs []*Config) error {
+	bots := make([]*Bot, 0, len(configs))
+	servers := make(map[string]*webhookServer)
+	for _, config := range configs {
+		bot, err := New(env, config)
+		if err != nil {
+			return fmt.Errorf("bot %q: %w", config.botName(), err)
+		}
+		if config.webhookEnabled() {
+			srv, ok := servers[config.WebhookPort]
+			if !ok {
+				srv = newWebhookServer(config.WebhookPort)
+				servers[config.WebhookPort] = srv
+			}
+			bot.webhookServer = srv
+		}
+		bots = append(bots, bot)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		result error
+	)
+	for _, srv := range servers {
+		srv := srv
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := srv.serve(ctx); err != nil {
+				mu.Lock()
+				result = multierr.Append(result, err)
+				mu.Unlock()
+				cancel()
+			}
+		}()
+	}
+	for _, bot := range bots {
+		bot := bot
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := bot.Serve(ctx); err != nil {
+				mu.Lock()
+				result = multierr.Append(result, fmt.Errorf("bot %q: %w", bot.config.botName(), err))
+				mu.Unlock()
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return result
+}
@@ -0,0 +1,165 @@
+package tgbot
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sethvargo/go-envconfig"
+)
+
+func TestBotDefinitions_EnvDecode(t *testing.T) {
+	t.Parallel()
+
+	var defs BotDefinitions
+	if err := defs.EnvDecode(`[{"name": "support", "token": "t", "modules": ["admin"]}]`); err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 1 || defs[0].Name != "support" || defs[0].Token != "t" ||
+		!reflect.DeepEqual(defs[0].Modules, []string{"admin"}) {
+		t.Errorf("definitions do not match, got = %+v", defs)
+	}
+
+	if err := defs.EnvDecode("[{"); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestConfig_BotConfigs(t *testing.T) {
+	t.Parallel()
+
+	base := envconfig.MapLookuper(map[string]string{
+		"WORKERS":        "3",
+		"DEFAULT_LOCALE": "ru",
+		"TG_TOKEN":       "PROCESS_TOKEN",
+	})
+
+	t.Run(
+		"single bot", func(t *testing.T) {
+			t.Parallel()
+
+			c := &Config{TelegramToken: "TOKEN"}
+			configs, err := c.BotConfigs(context.Background(), base, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(configs) != 1 || configs[0] != c {
+				t.Errorf("configs do not match, got = %v, want = [%v]", configs, c)
+			}
+		},
+	)
+
+	t.Run(
+		"bots", func(t *testing.T) {
+			t.Parallel()
+
+			c := &Config{
+				Bots: BotDefinitions{
+					{Name: "main", Token: "MAIN_TOKEN"},
+					{
+						Name:     "support",
+						Token:    "SUPPORT_TOKEN",
+						Modules:  []string{moduleAdmin, moduleEcho},
+						Settings: map[string]string{"DEFAULT_LOCALE": "en"},
+					},
+				},
+			}
+			configs, err := c.BotConfigs(context.Background(), base, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(configs) != 2 {
+				t.Fatalf("configs count does not match, got = %d, want = 2", len(configs))
+			}
+
+			main, support := configs[0], configs[1]
+			if main.botName() != "main" || main.TelegramToken != "MAIN_TOKEN" || main.DefaultLocale != "ru" {
+				t.Errorf("main bot config does not match, got = %s/%s/%s", main.BotName, main.TelegramToken, main.DefaultLocale)
+			}
+			if !main.enables(moduleReminders) {
+				t.Error("main bot does not handle reminders")
+			}
+			if support.botName() != "support" || support.TelegramToken != "SUPPORT_TOKEN" || support.DefaultLocale != "en" {
+				t.Errorf(
+					"support bot config does not match, got = %s/%s/%s",
+					support.BotName, support.TelegramToken, support.DefaultLocale,
+				)
+			}
+			if support.enables(moduleReminders) || !support.enables(moduleAdmin) {
+				t.Errorf("support bot modules do not match, got = %v", support.Modules)
+			}
+			for _, config := range configs {
+				if config.Workers != 3 {
+					t.Errorf("workers of %s do not match, got = %d, want = 3", config.BotName, config.Workers)
+				}
+				if config.Bots != nil {
+					t.Errorf("bot %s has bot definitions", config.BotName)
+				}
+			}
+		},
+	)
+
+	t.Run(
+		"shared webhook port", func(t *testing.T) {
+			t.Parallel()
+
+			c := &Config{Bots: BotDefinitions{{Name: "main", Token: "t1"}, {Name: "support", Token: "t2"}}}
+			l := envconfig.MapLookuper(map[string]string{"WEBHOOK_URL": "https://example.com", "PORT": "8443"})
+			configs, err := c.BotConfigs(context.Background(), l, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, config := range configs {
+				if want := "/webhook/" + config.BotName; config.WebhookPath != want {
+					t.Errorf("webhook path does not match, got = %s, want = %s", config.WebhookPath, want)
+				}
+			}
+		},
+	)
+
+	tests := []struct {
+		name string
+		bots BotDefinitions
+		env  map[string]string
+	}{
+		{
+			name: "invalid name",
+			bots: BotDefinitions{{Name: "Main Bot", Token: "t"}},
+		},
+		{
+			name: "duplicate name",
+			bots: BotDefinitions{{Name: "main", Token: "t1"}, {Name: "main", Token: "t2"}},
+		},
+		{
+			name: "no token",
+			bots: BotDefinitions{{Name: "main"}},
+		},
+		{
+			name: "unknown module",
+			bots: BotDefinitions{{Name: "main", Token: "t", Modules: []string{"weather"}}},
+		},
+		{
+			name: "same webhook path",
+			bots: BotDefinitions{
+				{Name: "main", Token: "t1", Settings: map[string]string{"WEBHOOK_PATH": "/hook"}},
+				{Name: "support", Token: "t2", Settings: map[string]string{"WEBHOOK_PATH": "/hook"}},
+			},
+			env: map[string]string{"WEBHOOK_URL": "https://example.com", "PORT": "8443"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				c := &Config{Bots: tc.bots}
+				if _, err := c.BotConfigs(context.Background(), envconfig.MapLookuper(tc.env), nil); err == nil {
+					t.Error("expected error")
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,411 @@
+package tgbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/i18n"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	tgbotdb "github.com/alienvspredator/simple-tgbot/internal/tgbot/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// broadcastsShown is the number of the recent broadcasts shown by
+// /broadcasts.
+const broadcastsShown = 5
+
+// errBadBroadcastOption is returned for the /broadcast options that can not
+// be parsed.
+var errBadBroadcastOption = errors.New("bad broadcast option")
+
+// parseBroadcastOptions parses the /broadcast options. The first line holds
+// the audience filters separated by spaces:
+//
+//	lang=<code>    the users with the Telegram language
+//	seen=<period>  the users seen within the period, e.g. 30d or 12h
+//	groups         the groups the bot is a member of too
+//
+// Each next line adds a link button in its own row: "<text> | <url>".
+func parseBroadcastOptions(raw string, now time.Time) (model.BroadcastAudience, *tbot.InlineKeyboardMarkup, error) {
+	var audience model.BroadcastAudience
+
+	lines := strings.Split(strings.TrimSpace(raw), "\n")
+	for _, opt := range strings.Fields(lines[0]) {
+		key, value := opt, ""
+		if i := strings.IndexByte(opt, '='); i >= 0 {
+			key, value = opt[:i], opt[i+1:]
+		}
+
+		switch {
+		case key == "lang" && value != "":
+			audience.LanguageCode = strings.ToLower(value)
+		case key == "seen" && value != "":
+			d, err := parsePeriod(value)
+			if err != nil {
+				return audience, nil, fmt.Errorf("%w: %s", errBadBroadcastOption, opt)
+			}
+			audience.SeenSince = now.Add(-d)
+		case key == "groups" && value == "":
+			audience.Groups = true
+		default:
+			return audience, nil, fmt.Errorf("%w: %s", errBadBroadcastOption, opt)
+		}
+	}
+
+	var markup *tbot.InlineKeyboardMarkup
+	if len(lines) > 1 {
+		kb := NewInlineKeyboard()
+		for _, line := range lines[1:] {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			parts := strings.SplitN(line, "|", 2)
+			if len(parts) != 2 {
+				return audience, nil, fmt.Errorf("%w: %s", errBadBroadcastOption, line)
+			}
+			text, link := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+			if u, err := url.Parse(link); text == "" || err != nil || u.Scheme == "" || u.Host == "" {
+				return audience, nil, fmt.Errorf("%w: %s", errBadBroadcastOption, line)
+			}
+			kb.Row().URL(text, link)
+		}
+		var err error
+		if markup, err = kb.Build(); err != nil {
+			return audience, nil, err
+		}
+		if len(markup.InlineKeyboard) == 0 {
+			markup = nil
+		}
+	}
+
+	return audience, markup, nil
+}
+
+// parsePeriod parses a duration that also accepts the days, e.g. "30d".
+func parsePeriod(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid period %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid period %q", s)
+	}
+	return d, nil
+}
+
+// broadcastContent returns the broadcast of the message: its text, or its
+// file and caption.
+func broadcastContent(m *tbot.Message) (*model.Broadcast, bool) {
+	bc := &model.Broadcast{Text: m.Text}
+	if bc.Text != "" {
+		return bc, true
+	}
+
+	media := messageMedia(m)
+	switch media.Kind {
+	case mediaPhoto, mediaDocument, mediaVoice, mediaSticker:
+		bc.MediaKind, bc.MediaFileID, bc.Text = media.Kind, media.FileID, media.Caption
+		return bc, true
+	default:
+		return nil, false
+	}
+}
+
+// broadcastsModule handles the broadcast campaigns.
+type broadcastsModule struct{}
+
+func (broadcastsModule) Name() string {
+	return moduleBroadcasts
+}
+
+func (broadcastsModule) Attach(b *Bot, _ interface{}) error {
+	if b.db != nil {
+		b.AddWorker(b.dispatchBroadcasts)
+	}
+
+	b.AddCommand(&Command{
+		Name:        "broadcast",
+		Description: "command.broadcast.description",
+		Help:        "command.broadcast.help",
+		Args:        []Arg{{Name: "options", Optional: true, Rest: true}},
+		Hidden:      true,
+		Role:        RoleAdmin,
+		Run:         b.Broadcast,
+	})
+	b.AddCommand(&Command{
+		Name:        "broadcasts",
+		Description: "command.broadcasts.description",
+		Hidden:      true,
+		Role:        RoleAdmin,
+		Run:         b.Broadcasts,
+	})
+	b.AddCommand(&Command{
+		Name:        "stopbroadcast",
+		Description: "command.stopbroadcast.description",
+		Args:        []Arg{{Name: "id"}},
+		Hidden:      true,
+		Role:        RoleAdmin,
+		Run:         b.StopBroadcast,
+	})
+
+	return nil
+}
+
+// Broadcast starts sending the replied message to the audience selected by
+// the options, see parseBroadcastOptions.
+func (b *Bot) Broadcast(ctx context.Context, m *tbot.Message, args Args) {
+	log := logging.FromContext(ctx)
+
+	cmd, _ := b.commands.lookup("broadcast")
+	if m.ReplyToMessage == nil {
+		b.reply(ctx, m, b.t(ctx, "broadcast.no_message", i18n.Params{"Usage": cmd.Usage()}))
+		return
+	}
+	bc, ok := broadcastContent(m.ReplyToMessage)
+	if !ok {
+		b.reply(ctx, m, b.t(ctx, "broadcast.unsupported", nil))
+		return
+	}
+
+	audience, markup, err := parseBroadcastOptions(args.Get("options"), time.Now())
+	if err != nil {
+		b.reply(ctx, m, b.t(ctx, "broadcast.bad_options", i18n.Params{"Error": err.Error()}))
+		return
+	}
+	bc.Audience = audience
+	if markup != nil {
+		raw, err := json.Marshal(markup)
+		if err != nil {
+			log.Errorw("encode broadcast keyboard", zap.Error(err))
+			b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+			return
+		}
+		bc.ReplyMarkup = string(raw)
+	}
+	if m.From != nil {
+		bc.CreatedBy = int64(m.From.ID)
+	}
+
+	if b.db == nil {
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	if err := b.db.CreateBroadcast(ctx, bc); err != nil {
+		log.Errorw("create broadcast", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	log.Infow(
+		"broadcast created", "broadcast_id", bc.ID, "language_code", audience.LanguageCode,
+		"seen_since", audience.SeenSince, "groups", audience.Groups,
+	)
+
+	b.reply(ctx, m, b.t(ctx, "broadcast.created", i18n.Params{"ID": bc.ID}))
+}
+
+// Broadcasts shows the progress of the recent broadcasts.
+func (b *Bot) Broadcasts(ctx context.Context, m *tbot.Message, _ Args) {
+	if b.db == nil {
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+
+	bcs, err := b.db.ListBroadcasts(ctx, broadcastsShown)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("list broadcasts", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	if len(bcs) == 0 {
+		b.reply(ctx, m, b.t(ctx, "broadcasts.empty", nil))
+		return
+	}
+
+	lines := make([]string, 0, len(bcs))
+	for _, bc := range bcs {
+		lines = append(lines, b.t(ctx, "broadcasts.item", i18n.Params{
+			"ID":        bc.ID,
+			"Status":    bc.Status,
+			"CreatedAt": bc.CreatedAt.UTC().Format("2006-01-02 15:04"),
+			"Pending":   bc.Recipients[tgbotdb.RecipientPending],
+			"Sent":      bc.Recipients[tgbotdb.RecipientSent],
+			"Failed":    bc.Recipients[tgbotdb.RecipientFailed],
+			"Blocked":   bc.Recipients[tgbotdb.RecipientBlocked],
+		}))
+	}
+	b.reply(ctx, m, strings.Join(lines, "\n"))
+}
+
+// StopBroadcast cancels the running broadcast. The recipients who did not
+// get it yet are skipped.
+func (b *Bot) StopBroadcast(ctx context.Context, m *tbot.Message, args Args) {
+	log := logging.FromContext(ctx)
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(args.Get("id"), "#"), 10, 64)
+	if err != nil {
+		cmd, _ := b.commands.lookup("stopbroadcast")
+		b.reply(ctx, m, b.t(ctx, "command.usage", i18n.Params{"Usage": cmd.Usage()}))
+		return
+	}
+
+	if b.db == nil {
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	switch err := b.db.CancelBroadcast(ctx, id); {
+	case errors.Is(err, database.ErrNotFound):
+		b.reply(ctx, m, b.t(ctx, "broadcast.not_running", nil))
+		return
+	case err != nil:
+		log.Errorw("cancel broadcast", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	log.Infow("broadcast cancelled", "broadcast_id", id)
+
+	b.reply(ctx, m, b.t(ctx, "broadcast.cancelled", i18n.Params{"ID": id}))
+}
+
+// dispatchBroadcasts sends the running broadcasts until stop is closed. The
+// recipients are claimed in batches, so several replicas share the work and
+// a restarted one resumes the interrupted broadcasts.
+func (b *Bot) dispatchBroadcasts(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(b.config.BroadcastPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.flushBroadcasts(ctx, stop)
+		}
+	}
+}
+
+// flushBroadcasts sends a batch of each running broadcast and finishes the
+// broadcasts without pending recipients.
+func (b *Bot) flushBroadcasts(ctx context.Context, stop <-chan struct{}) {
+	log := logging.FromContext(ctx)
+
+	bcs, err := b.db.ListRunningBroadcasts(ctx)
+	if err != nil {
+		log.Errorw("list running broadcasts", zap.Error(err))
+		return
+	}
+
+	for _, bc := range bcs {
+		log := log.With("broadcast_id", bc.ID)
+
+		recipients, err := b.db.ClaimBroadcastRecipients(
+			ctx, bc.ID, b.config.BroadcastBatchSize, time.Now().Add(b.config.BroadcastLease),
+		)
+		if err != nil {
+			log.Errorw("claim broadcast recipients", zap.Error(err))
+			continue
+		}
+
+		if len(recipients) == 0 {
+			finished, err := b.db.FinishBroadcast(ctx, bc.ID)
+			if err != nil {
+				log.Errorw("finish broadcast", zap.Error(err))
+			} else if finished {
+				metricsware.NewMiddleware().RecordBroadcastFinished(ctx)
+				log.Infow("broadcast finished")
+			}
+			continue
+		}
+
+		for _, r := range recipients {
+			if !b.throttleBroadcast(ctx, stop) {
+				// The unsent recipients are claimed again after the lease.
+				return
+			}
+			b.sendBroadcast(logging.WithLogger(ctx, log.With("chat_id", r.ChatID)), bc, r)
+		}
+	}
+}
+
+// throttleBroadcast waits for the next broadcast message slot. The
+// broadcasts are sent at BroadcastRate, so they leave room for the answers
+// under the overall send limit. It reports false if the dispatcher stops.
+func (b *Bot) throttleBroadcast(ctx context.Context, stop <-chan struct{}) bool {
+	if b.config.BroadcastRate <= 0 {
+		return true
+	}
+
+	t := time.NewTimer(time.Duration(float64(time.Second) / b.config.BroadcastRate))
+	defer t.Stop()
+
+	select {
+	case <-stop:
+		return false
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// sendBroadcast sends the broadcast to the recipient and records the
+// delivery. The temporary failures are retried with the outbox backoff until
+// BroadcastMaxAttempts is reached.
+func (b *Bot) sendBroadcast(ctx context.Context, bc *model.Broadcast, r *model.BroadcastRecipient) {
+	log := logging.FromContext(ctx)
+	metricsMW := metricsware.NewMiddleware()
+
+	var opts []telegram.SendOption
+	if bc.ReplyMarkup != "" {
+		opts = append(opts, telegram.OptReplyMarkup(json.RawMessage(bc.ReplyMarkup)))
+	}
+
+	var err error
+	if bc.MediaKind != "" {
+		_, err = b.api.SendFile(ctx, r.ChatID, telegram.FileKind(bc.MediaKind), bc.MediaFileID, bc.Text, opts...)
+	} else {
+		_, err = b.api.SendMessage(ctx, r.ChatID, bc.Text, opts...)
+	}
+
+	status, lastError := tgbotdb.RecipientSent, ""
+	switch {
+	case err == nil:
+		metricsMW.RecordBroadcastSent(ctx)
+	case telegram.IsForbidden(err):
+		metricsMW.RecordBroadcastFailed(ctx)
+		b.markBlocked(ctx, r.ChatID)
+		status, lastError = tgbotdb.RecipientBlocked, err.Error()
+	case telegram.IsPermanent(err) || r.Attempts+1 >= b.config.BroadcastMaxAttempts:
+		metricsMW.RecordBroadcastFailed(ctx)
+		log.Errorw("send broadcast", "attempt", r.Attempts+1, zap.Error(err))
+		status, lastError = tgbotdb.RecipientFailed, err.Error()
+	default:
+		log.Warnw("send broadcast", "attempt", r.Attempts+1, zap.Error(err))
+		next := time.Now().Add(b.outboxBackoff(r.Attempts, err))
+		if err := b.db.RetryBroadcastRecipient(ctx, r, next, err.Error()); err != nil {
+			log.Errorw("schedule broadcast retry", zap.Error(err))
+		}
+		return
+	}
+
+	if err := b.db.SetBroadcastRecipientStatus(ctx, r, status, lastError); err != nil {
+		log.Errorw("save broadcast delivery", "status", status, zap.Error(err))
+	}
+}
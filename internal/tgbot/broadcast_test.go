@@ -0,0 +1,111 @@
+package tgbot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseBroadcastOptions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		raw           string
+		wantLanguage  string
+		wantSeenSince time.Time
+		wantGroups    bool
+		wantButtons   []string
+		wantErr       bool
+	}{
+		{
+			name: "everybody",
+		},
+		{
+			name:          "filters",
+			raw:           "lang=EN seen=30d groups",
+			wantLanguage:  "en",
+			wantSeenSince: now.Add(-30 * 24 * time.Hour),
+			wantGroups:    true,
+		},
+		{
+			name:          "hours",
+			raw:           "seen=12h",
+			wantSeenSince: now.Add(-12 * time.Hour),
+		},
+		{
+			name:         "buttons",
+			raw:          "lang=ru\nSite | https://example.com\n\nNews | https://example.com/news",
+			wantLanguage: "ru",
+			wantButtons:  []string{"https://example.com", "https://example.com/news"},
+		},
+		{
+			name:    "unknown filter",
+			raw:     "country=ua",
+			wantErr: true,
+		},
+		{
+			name:    "bad period",
+			raw:     "seen=-1d",
+			wantErr: true,
+		},
+		{
+			name:    "button without link",
+			raw:     "\nSite",
+			wantErr: true,
+		},
+		{
+			name:    "relative link",
+			raw:     "\nSite | /path",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			audience, markup, err := parseBroadcastOptions(tc.raw, now)
+			if tc.wantErr {
+				if !errors.Is(err, errBadBroadcastOption) {
+					t.Fatalf("error does not match, got = %v, want = %v", err, errBadBroadcastOption)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if audience.LanguageCode != tc.wantLanguage {
+				t.Errorf("language does not match, got = %s, want = %s", audience.LanguageCode, tc.wantLanguage)
+			}
+			if !audience.SeenSince.Equal(tc.wantSeenSince) {
+				t.Errorf("seen since does not match, got = %s, want = %s", audience.SeenSince, tc.wantSeenSince)
+			}
+			if audience.Groups != tc.wantGroups {
+				t.Errorf("groups does not match, got = %t, want = %t", audience.Groups, tc.wantGroups)
+			}
+
+			var buttons []string
+			if markup != nil {
+				for _, row := range markup.InlineKeyboard {
+					for _, button := range row {
+						buttons = append(buttons, button.URL)
+					}
+				}
+			}
+			if len(buttons) != len(tc.wantButtons) {
+				t.Fatalf("buttons do not match, got = %v, want = %v", buttons, tc.wantButtons)
+			}
+			for i := range buttons {
+				if buttons[i] != tc.wantButtons[i] {
+					t.Errorf("buttons do not match, got = %v, want = %v", buttons, tc.wantButtons)
+				}
+			}
+		})
+	}
+}
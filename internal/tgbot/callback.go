@@ -0,0 +1,160 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxCallbackDataLen is the Telegram limit of the callback data size in
+	// bytes.
+	maxCallbackDataLen = 64
+
+	// callbackSeparator separates the route and the arguments in the
+	// encoded callback data.
+	callbackSeparator = "|"
+)
+
+// ErrCallbackDataTooLong is returned when the encoded callback data exceeds
+// the Telegram limit of 64 bytes.
+var ErrCallbackDataTooLong = errors.New("callback data is longer than 64 bytes")
+
+// CallbackData is the payload of an inline keyboard button. Route selects
+// the handler registered with HandleCallback, Args are passed to it.
+type CallbackData struct {
+	Route string
+	Args  []string
+}
+
+// NewCallbackData creates the callback data for the route.
+func NewCallbackData(route string, args ...string) CallbackData {
+	return CallbackData{Route: route, Args: args}
+}
+
+// Encode returns the callback data as sent to Telegram. It returns
+// ErrCallbackDataTooLong if the result does not fit into 64 bytes.
+func (d CallbackData) Encode() (string, error) {
+	if d.Route == "" {
+		return "", errors.New("callback data without route")
+	}
+	for _, s := range append([]string{d.Route}, d.Args...) {
+		if strings.Contains(s, callbackSeparator) {
+			return "", fmt.Errorf("callback data %q contains %q", s, callbackSeparator)
+		}
+	}
+
+	s := strings.Join(append([]string{d.Route}, d.Args...), callbackSeparator)
+	if len(s) > maxCallbackDataLen {
+		return "", fmt.Errorf("route %s: %w", d.Route, ErrCallbackDataTooLong)
+	}
+	return s, nil
+}
+
+// ParseCallbackData decodes the callback data encoded with Encode.
+func ParseCallbackData(s string) (CallbackData, error) {
+	if s == "" {
+		return CallbackData{}, errors.New("empty callback data")
+	}
+
+	parts := strings.Split(s, callbackSeparator)
+	d := CallbackData{Route: parts[0]}
+	if len(parts) > 1 {
+		d.Args = parts[1:]
+	}
+	return d, nil
+}
+
+// Arg returns the i-th argument or an empty string.
+func (d CallbackData) Arg(i int) string {
+	if i < 0 || i >= len(d.Args) {
+		return ""
+	}
+	return d.Args[i]
+}
+
+// Int returns the i-th argument parsed as an integer.
+func (d CallbackData) Int(i int) (int64, error) {
+	if i < 0 || i >= len(d.Args) {
+		return 0, fmt.Errorf("route %s: no argument %d", d.Route, i)
+	}
+	n, err := strconv.ParseInt(d.Args[i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("route %s: argument %d: %w", d.Route, i, err)
+	}
+	return n, nil
+}
+
+// CallbackAnswer is shown to the user who pressed the button. The empty
+// answer only stops the progress indicator on the button.
+type CallbackAnswer struct {
+	Text      string
+	ShowAlert bool
+}
+
+// CallbackFunc handles the press of a button with the callback data of the
+// route. The returned answer is sent to the user.
+type CallbackFunc func(ctx context.Context, q *tbot.CallbackQuery, data CallbackData) CallbackAnswer
+
+// HandleCallback registers the handler for the callback data with the
+// route. It must be called before Serve.
+func (b *Bot) HandleCallback(route string, f CallbackFunc) {
+	if strings.Contains(route, callbackSeparator) {
+		panic(fmt.Sprintf("callback route %q contains %q", route, callbackSeparator))
+	}
+	if _, ok := b.callbackHandlers[route]; ok {
+		panic(fmt.Sprintf("callback route %q registered twice", route))
+	}
+	b.callbackHandlers[route] = f
+}
+
+// HandleCallbackQuery routes the callback query to the handler of its route
+// and answers it.
+func (b *Bot) HandleCallbackQuery(ctx context.Context, u *telegram.Update) {
+	q := u.CallbackQuery
+	log := logging.FromContext(ctx)
+
+	var answer CallbackAnswer
+	data, err := ParseCallbackData(q.Data)
+	if err == nil {
+		if f, ok := b.callbackHandlers[data.Route]; ok {
+			answer = f(ctx, q, data)
+		} else {
+			err = fmt.Errorf("unknown callback route %q", data.Route)
+		}
+	}
+	if err != nil {
+		log.Infow("invalid callback query", zap.Error(err))
+		metricsware.NewMiddleware().RecordFailedReply(ctx)
+		answer = CallbackAnswer{Text: b.t(ctx, "callback.unknown", nil)}
+	}
+
+	if err := b.api.AnswerCallbackQuery(ctx, q.ID, answer.Text, answer.ShowAlert); err != nil {
+		log.Errorw("answer callback query", zap.Error(err))
+	}
+}
+
+// EditMessage replaces the text and the inline keyboard of the message sent
+// by the bot, e.g. the one with the pressed button. A nil keyboard removes
+// it.
+func (b *Bot) EditMessage(ctx context.Context, m *tbot.Message, text string, keyboard *tbot.InlineKeyboardMarkup) {
+	log := logging.FromContext(ctx)
+
+	var opts []telegram.SendOption
+	if keyboard != nil {
+		opts = append(opts, telegram.OptReplyMarkup(keyboard))
+	}
+
+	if _, err := b.api.EditMessageText(ctx, m.Chat.ID, m.MessageID, text, opts...); err != nil {
+		metricsware.NewMiddleware().RecordSendFailure(ctx)
+		log.Errorw("edit message", "edited:message_id", m.MessageID, zap.Error(err))
+	}
+}
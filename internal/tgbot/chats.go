@@ -0,0 +1,97 @@
+package tgbot
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// memberKicked is the status of the bot in the chat it was removed from. In
+// the private chats it means the user blocked the bot.
+const memberKicked = "kicked"
+
+// chatProfile returns the chat record with the type, the title and the
+// username of the chat.
+func chatProfile(c tbot.Chat) *model.Chat {
+	return &model.Chat{
+		ID:       c.ID,
+		Type:     c.Type,
+		Title:    c.Title,
+		Username: c.Username,
+	}
+}
+
+// TrackChats records the chat of every message in the chats registry. The
+// chat is saved only when it is new or its title or username changed.
+func (b *Bot) TrackChats() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *telegram.Update) {
+			if m := updateMessage(u); m != nil && b.db != nil {
+				b.saveChat(ctx, chatProfile(m.Chat))
+			}
+
+			next(ctx, u)
+		}
+	}
+}
+
+// saveChat saves the chat unless the same profile was saved recently. The
+// bot receiving messages from a new chat is a member of it.
+func (b *Bot) saveChat(ctx context.Context, c *model.Chat) {
+	if v, ok := b.chatCache.Lookup(c.ID); ok && *v.(*model.Chat) == *c {
+		return
+	}
+
+	saved := *c
+	saved.MemberStatus = "member"
+	if err := b.db.SaveChat(ctx, &saved); err != nil {
+		logging.FromContext(ctx).Errorw("failed to save chat", "chat_id", c.ID, zap.Error(err))
+		return
+	}
+	b.cacheChat(ctx, c)
+}
+
+// cacheChat remembers the saved profile of the chat.
+func (b *Bot) cacheChat(ctx context.Context, c *model.Chat) {
+	if err := b.chatCache.Set(c.ID, c); err != nil {
+		logging.FromContext(ctx).Errorw("cache chat", zap.Error(err))
+	}
+}
+
+// HandleMyChatMember saves the new status of the bot in the chat. In the
+// private chats the status tells whether the user blocked the bot.
+func (b *Bot) HandleMyChatMember(ctx context.Context, u *telegram.Update) {
+	cm := u.MyChatMember
+	log := logging.FromContext(ctx)
+
+	if b.db == nil {
+		return
+	}
+
+	c := chatProfile(cm.Chat)
+	status := cm.NewChatMember.Status
+
+	saved := *c
+	saved.MemberStatus = status
+	if err := b.db.SetChatMemberStatus(ctx, &saved); err != nil {
+		log.Errorw("failed to save chat member status", zap.Error(err))
+		return
+	}
+	b.cacheChat(ctx, c)
+
+	if cm.Chat.Type != "private" {
+		return
+	}
+	userID, err := strconv.ParseInt(cm.Chat.ID, 10, 64)
+	if err != nil {
+		return
+	}
+	if err := b.users.block(ctx, userID, status == memberKicked); err != nil {
+		log.Errorw("failed to save user blocked", "user_id", userID, zap.Error(err))
+	}
+}
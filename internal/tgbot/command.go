@@ -0,0 +1,250 @@
+package tgbot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yanzay/tbot/v2"
+)
+
+// maxSuggestionDistance is the largest edit distance between an unknown
+// command and a registered one that is still offered as a suggestion.
+const maxSuggestionDistance = 2
+
+// Arg describes a positional command argument.
+type Arg struct {
+	Name string
+	// Optional arguments may be omitted. They must follow the required ones.
+	Optional bool
+	// Rest makes the argument consume the remaining text. Only the last
+	// argument can be Rest.
+	Rest bool
+}
+
+// Args holds the parsed command arguments by name.
+type Args map[string]string
+
+// Get returns the argument value or the empty string if it was not given.
+func (a Args) Get(name string) string {
+	return a[name]
+}
+
+// CommandFunc handles a command with the parsed arguments.
+type CommandFunc func(ctx context.Context, m *tbot.Message, args Args)
+
+// Command describes a bot command.
+type Command struct {
+	// Name is the command without the leading slash, e.g. "help".
+	Name string
+	// Description is the catalog key of a short text for the bot menu and
+	// /help.
+	Description string
+	// Help is the catalog key of the detailed text shown by /help <command>.
+	Help string
+	Args []Arg
+	// Hidden commands are not listed in the bot menu and /help.
+	Hidden bool
+	// Role is the lowest operator role allowed to run the command. Everyone
+	// can run the commands with RoleNone.
+	Role Role
+	Run  CommandFunc
+}
+
+// Usage returns the command syntax, e.g. "/remind <when> <text...>".
+func (c *Command) Usage() string {
+	var sb strings.Builder
+	sb.WriteString("/" + c.Name)
+	for _, arg := range c.Args {
+		name := arg.Name
+		if arg.Rest {
+			name += "..."
+		}
+		if arg.Optional {
+			fmt.Fprintf(&sb, " [%s]", name)
+		} else {
+			fmt.Fprintf(&sb, " <%s>", name)
+		}
+	}
+	return sb.String()
+}
+
+// parseArgs splits the raw argument text according to the command's Args.
+func (c *Command) parseArgs(raw string) (Args, error) {
+	args := make(Args, len(c.Args))
+	rest := strings.TrimSpace(raw)
+
+	for _, arg := range c.Args {
+		if rest == "" {
+			if !arg.Optional {
+				return nil, fmt.Errorf("missing argument %q", arg.Name)
+			}
+			continue
+		}
+
+		if arg.Rest {
+			args[arg.Name] = rest
+			rest = ""
+			continue
+		}
+
+		args[arg.Name], rest = rest, ""
+		if i := strings.IndexAny(args[arg.Name], " \n\t"); i >= 0 {
+			args[arg.Name], rest = args[arg.Name][:i], strings.TrimSpace(args[arg.Name][i+1:])
+		}
+	}
+
+	if rest != "" {
+		return nil, fmt.Errorf("unexpected arguments %q", rest)
+	}
+	return args, nil
+}
+
+// commandCall is a command invocation parsed from a message text.
+type commandCall struct {
+	name string
+	// mention is the bot username from the /cmd@BotName form.
+	mention string
+	rawArgs string
+}
+
+// parseCommand extracts the command from the message text. It reports false
+// if the text is not a command.
+func parseCommand(text string) (commandCall, bool) {
+	if !strings.HasPrefix(text, "/") {
+		return commandCall{}, false
+	}
+
+	head, rawArgs := text[1:], ""
+	if i := strings.IndexAny(head, " \n\t"); i >= 0 {
+		head, rawArgs = head[:i], head[i+1:]
+	}
+	if head == "" {
+		return commandCall{}, false
+	}
+
+	call := commandCall{name: head, rawArgs: rawArgs}
+	if i := strings.Index(head, "@"); i >= 0 {
+		call.name, call.mention = head[:i], head[i+1:]
+	}
+	call.name = strings.ToLower(call.name)
+
+	return call, true
+}
+
+// commandRegistry keeps the registered commands in the registration order.
+type commandRegistry struct {
+	commands []*Command
+	byName   map[string]*Command
+}
+
+func newCommandRegistry() *commandRegistry {
+	return &commandRegistry{byName: make(map[string]*Command)}
+}
+
+// register adds the command to the registry. It panics on a duplicate name
+// as it is a programming error.
+func (r *commandRegistry) register(cmd *Command) {
+	name := strings.ToLower(cmd.Name)
+	if _, ok := r.byName[name]; ok {
+		panic(fmt.Sprintf("command /%s registered twice", name))
+	}
+	for i, arg := range cmd.Args {
+		if arg.Rest && i != len(cmd.Args)-1 {
+			panic(fmt.Sprintf("command /%s: only the last argument can be Rest", name))
+		}
+	}
+
+	cmd.Name = name
+	r.commands = append(r.commands, cmd)
+	r.byName[name] = cmd
+}
+
+func (r *commandRegistry) lookup(name string) (*Command, bool) {
+	cmd, ok := r.byName[name]
+	return cmd, ok
+}
+
+// visible returns the commands shown to users.
+func (r *commandRegistry) visible() []*Command {
+	var cmds []*Command
+	for _, cmd := range r.commands {
+		if !cmd.Hidden {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
+// botCommands builds the bot menu for setMyCommands.
+func (r *commandRegistry) botCommands() []tbot.BotCommand {
+	var cmds []tbot.BotCommand
+	for _, cmd := range r.visible() {
+		cmds = append(cmds, tbot.BotCommand{Command: cmd.Name, Description: cmd.Description})
+	}
+	return cmds
+}
+
+// suggest returns the visible command closest to the given name. It reports
+// false if there is no close enough command.
+func (r *commandRegistry) suggest(name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	var candidates []candidate
+	for _, cmd := range r.visible() {
+		d := levenshtein(name, cmd.Name)
+		if d <= maxSuggestionDistance || strings.HasPrefix(cmd.Name, name) {
+			candidates = append(candidates, candidate{name: cmd.Name, distance: d})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+	return candidates[0].name, true
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
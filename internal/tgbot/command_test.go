@@ -0,0 +1,147 @@
+package tgbot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		text   string
+		want   commandCall
+		wantOK bool
+	}{
+		{
+			name:   "plain",
+			text:   "/help",
+			want:   commandCall{name: "help"},
+			wantOK: true,
+		},
+		{
+			name:   "with args",
+			text:   "/Help settings now",
+			want:   commandCall{name: "help", rawArgs: "settings now"},
+			wantOK: true,
+		},
+		{
+			name:   "addressed",
+			text:   "/help@SimpleBot settings",
+			want:   commandCall{name: "help", mention: "SimpleBot", rawArgs: "settings"},
+			wantOK: true,
+		},
+		{
+			name: "not a command",
+			text: "help",
+		},
+		{
+			name: "slash only",
+			text: "/",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				got, ok := parseCommand(tc.text)
+				if ok != tc.wantOK {
+					t.Fatalf("ok does not match, got = %t, want = %t", ok, tc.wantOK)
+				}
+				if got != tc.want {
+					t.Errorf("call does not match, got = %+v, want = %+v", got, tc.want)
+				}
+			},
+		)
+	}
+}
+
+func TestCommand_parseArgs(t *testing.T) {
+	t.Parallel()
+
+	cmd := &Command{
+		Name: "remind",
+		Args: []Arg{
+			{Name: "when"},
+			{Name: "text", Optional: true, Rest: true},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    Args
+		wantErr bool
+	}{
+		{
+			name: "all",
+			raw:  "2h call   mom",
+			want: Args{"when": "2h", "text": "call   mom"},
+		},
+		{
+			name: "optional omitted",
+			raw:  " 2h ",
+			want: Args{"when": "2h"},
+		},
+		{
+			name:    "required missing",
+			raw:     "",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				got, err := cmd.parseArgs(tc.raw)
+				if (err != nil) != tc.wantErr {
+					t.Fatalf("error = %v, wantErr = %t", err, tc.wantErr)
+				}
+				if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+					t.Errorf("args do not match, got = %v, want = %v", got, tc.want)
+				}
+			},
+		)
+	}
+
+	if got, want := cmd.Usage(), "/remind <when> [text...]"; got != want {
+		t.Errorf("usage does not match, got = %s, want = %s", got, want)
+	}
+}
+
+func TestCommandRegistry_suggest(t *testing.T) {
+	t.Parallel()
+
+	r := newCommandRegistry()
+	r.register(&Command{Name: "help"})
+	r.register(&Command{Name: "settings"})
+	r.register(&Command{Name: "secret", Hidden: true})
+
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{name: "setings", want: "settings", wantOK: true},
+		{name: "hlep", want: "help", wantOK: true},
+		{name: "set", want: "settings", wantOK: true},
+		{name: "secrt"},
+		{name: "weather"},
+	}
+
+	for _, tc := range tests {
+		got, ok := r.suggest(tc.name)
+		if ok != tc.wantOK || got != tc.want {
+			t.Errorf("suggest(%q) = %q, %t, want = %q, %t", tc.name, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
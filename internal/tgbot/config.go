@@ -1,9 +1,13 @@
 package tgbot
 
 import (
+	"time"
+
 	"github.com/alienvspredator/simple-tgbot/internal/database"
 	"github.com/alienvspredator/simple-tgbot/internal/observability"
 	"github.com/alienvspredator/simple-tgbot/internal/secrets"
+	"github.com/alienvspredator/simple-tgbot/internal/storage"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
 	"github.com/alienvspredator/simple-tgbot/internal/zapfluentd"
 )
 
@@ -12,11 +16,158 @@ type Config struct {
 	Database              database.Config
 	SecretManager         secrets.Config
 	Fluent                zapfluentd.Config
+	Blobstore             storage.Config
 	ObservabilityExporter observability.Config
 
-	TelegramToken string `env:"TG_TOKEN"`
-	Debug         bool   `env:"LOG_DEBUG, default=false"`
-	WebhookPort   string `env:"PORT"`
+	// BotName identifies the bot in the database rows, the logs and the
+	// metrics. Modules are the enabled modules, see Module; the built-in
+	// ones are enabled when it is empty.
+	BotName        string   `env:"BOT_NAME, default=default"`
+	Modules        []string `env:"MODULES"`
+	TelegramToken  string   `env:"TG_TOKEN"`
+	TelegramAPIURL string   `env:"TG_API_URL, default=https://api.telegram.org"`
+	Debug          bool     `env:"LOG_DEBUG, default=false"`
+	WebhookPort    string   `env:"PORT"`
+
+	// Bots defines the bots served by the process, see BotConfigs. The one
+	// bot of this config is served when it is empty.
+	Bots BotDefinitions `env:"BOTS" json:"-"`
+
+	// ModuleConfigs are the configs of the enabled modules keyed by the
+	// module name, see ModuleConfig. They are set by BotConfigs.
+	ModuleConfigs map[string]interface{} `json:"-"`
+
+	// WebhookURL is the public base URL of the bot. Updates are received
+	// with long polling when it is empty. Without WebhookSecretToken a random
+	// one is generated on start, so the replicas sharing the webhook must set
+	// it.
+	WebhookURL         string `env:"WEBHOOK_URL"`
+	WebhookPath        string `env:"WEBHOOK_PATH, default=/webhook"`
+	WebhookSecretToken string `env:"WEBHOOK_SECRET_TOKEN" json:"-"`
+
+	// ChatStateTTL is how long a chat stays in a dialog state without
+	// moving to another one.
+	ChatStateTTL time.Duration `env:"CHAT_STATE_TTL, default=24h"`
+
+	// Updates are handled by Workers workers, each with a queue of
+	// WorkerQueueSize updates. Updates from one chat go to the same worker.
+	Workers         int `env:"WORKERS, default=8"`
+	WorkerQueueSize int `env:"WORKER_QUEUE_SIZE, default=100"`
+
+	// The last update handled together with all the earlier ones is saved
+	// every UpdateOffsetInterval, and long polling resumes after it. The IDs
+	// of the handled updates are kept for ProcessedUpdatesRetention, so the
+	// redelivered updates are skipped.
+	UpdateOffsetInterval      time.Duration `env:"UPDATE_OFFSET_INTERVAL, default=1s"`
+	ProcessedUpdatesRetention time.Duration `env:"PROCESSED_UPDATES_RETENTION, default=48h"`
+
+	// ShutdownTimeout limits the time spent on handling the received updates
+	// and delivering the outgoing messages on shutdown. The unfinished work
+	// is abandoned after it.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT, default=30s"`
+
+	// Outbound message limits. Telegram allows about 30 messages per second
+	// overall, one message per second to a chat and 20 messages per minute
+	// to a group.
+	SendRate          float64       `env:"SEND_RATE, default=30"`
+	SendBurst         int           `env:"SEND_BURST, default=30"`
+	SendChatInterval  time.Duration `env:"SEND_CHAT_INTERVAL, default=1s"`
+	SendGroupInterval time.Duration `env:"SEND_GROUP_INTERVAL, default=3s"`
+	SendMaxRetries    int           `env:"SEND_MAX_RETRIES, default=3"`
+
+	// Outgoing messages are saved to the outbox and retried with exponential
+	// backoff until OutboxMaxAttempts is reached.
+	OutboxPollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL, default=1s"`
+	OutboxLease        time.Duration `env:"OUTBOX_LEASE, default=1m"`
+	OutboxBackoff      time.Duration `env:"OUTBOX_BACKOFF, default=1s"`
+	OutboxMaxBackoff   time.Duration `env:"OUTBOX_MAX_BACKOFF, default=1h"`
+	OutboxMaxAttempts  int           `env:"OUTBOX_MAX_ATTEMPTS, default=10"`
+
+	// Broadcasts are sent at BroadcastRate messages per second, below
+	// SendRate, so the answers are not delayed. The recipients are claimed in
+	// batches of BroadcastBatchSize for BroadcastLease; the failed deliveries
+	// are retried with the outbox backoff until BroadcastMaxAttempts.
+	BroadcastRate         float64       `env:"BROADCAST_RATE, default=20"`
+	BroadcastPollInterval time.Duration `env:"BROADCAST_POLL_INTERVAL, default=5s"`
+	BroadcastBatchSize    int           `env:"BROADCAST_BATCH_SIZE, default=100"`
+	BroadcastLease        time.Duration `env:"BROADCAST_LEASE, default=1m"`
+	BroadcastMaxAttempts  int           `env:"BROADCAST_MAX_ATTEMPTS, default=5"`
+
+	// Inline queries are answered with up to InlineResultsLimit results per
+	// page. The answers are cached by the bot and by Telegram for
+	// InlineCacheTTL.
+	InlineResultsLimit int           `env:"INLINE_RESULTS_LIMIT, default=20"`
+	InlineCacheTTL     time.Duration `env:"INLINE_CACHE_TTL, default=30s"`
+
+	// Received files up to MediaMaxSize bytes are downloaded to the blob
+	// store, if one is configured. The Bot API serves files up to 20 MB.
+	MediaMaxSize int64 `env:"MEDIA_MAX_SIZE, default=20971520"`
+
+	// The profiles of the update senders are saved every UserFlushInterval
+	// or once UserBatchSize users are seen, whichever comes first.
+	UserBatchSize     int           `env:"USER_BATCH_SIZE, default=100"`
+	UserFlushInterval time.Duration `env:"USER_FLUSH_INTERVAL, default=1s"`
+
+	// Anti-flood limits. A user sends up to FloodUserRate updates per
+	// FloodUserWindow and FloodUserDaily per UTC day, a group gets up to
+	// FloodChatRate per FloodChatWindow and FloodChatDaily per day; zero
+	// disables a limit. The updates over a limit are handled with FloodAction:
+	// "drop" drops them silently, "warn" also warns once, "mute" ignores the
+	// user or the group for FloodMuteDuration. With FloodPersist the daily
+	// counts and the mutes are saved to the database every FloodFlushInterval.
+	FloodUserRate      int           `env:"FLOOD_USER_RATE, default=20"`
+	FloodUserWindow    time.Duration `env:"FLOOD_USER_WINDOW, default=1m"`
+	FloodUserDaily     int           `env:"FLOOD_USER_DAILY, default=0"`
+	FloodChatRate      int           `env:"FLOOD_CHAT_RATE, default=60"`
+	FloodChatWindow    time.Duration `env:"FLOOD_CHAT_WINDOW, default=1m"`
+	FloodChatDaily     int           `env:"FLOOD_CHAT_DAILY, default=0"`
+	FloodAction        string        `env:"FLOOD_ACTION, default=warn"`
+	FloodMuteDuration  time.Duration `env:"FLOOD_MUTE_DURATION, default=10m"`
+	FloodPersist       bool          `env:"FLOOD_PERSIST, default=false"`
+	FloodFlushInterval time.Duration `env:"FLOOD_FLUSH_INTERVAL, default=10s"`
+
+	// Scheduled jobs, e.g. the reminders, are claimed in batches of
+	// SchedulerBatchSize for SchedulerLease. A user without a role keeps up
+	// to MaxJobsPerUser jobs. The job times of the users who have not chosen
+	// a time zone are in DefaultTimeZone.
+	SchedulerPollInterval time.Duration `env:"SCHEDULER_POLL_INTERVAL, default=5s"`
+	SchedulerBatchSize    int           `env:"SCHEDULER_BATCH_SIZE, default=100"`
+	SchedulerLease        time.Duration `env:"SCHEDULER_LEASE, default=1m"`
+	MaxJobsPerUser        int           `env:"MAX_JOBS_PER_USER, default=20"`
+	DefaultTimeZone       string        `env:"DEFAULT_TIME_ZONE, default=UTC"`
+
+	// DefaultLocale is used for the users whose language has no translation.
+	DefaultLocale        string        `env:"DEFAULT_LOCALE, default=ru"`
+	UserSettingsCacheTTL time.Duration `env:"USER_SETTINGS_CACHE_TTL, default=5m"`
+	ChatSettingsCacheTTL time.Duration `env:"CHAT_SETTINGS_CACHE_TTL, default=5m"`
+
+	// The chat profiles are saved again only when they change or after
+	// ChatCacheTTL.
+	ChatCacheTTL time.Duration `env:"CHAT_CACHE_TTL, default=10m"`
+
+	// OwnerIDs are the Telegram user IDs of the bot owners, comma separated.
+	// The owner role is given only here and is not saved, so removing an ID
+	// takes the role away on restart. The other operator roles are granted
+	// by the owners with /grant. The roles, the feature switches and the
+	// access rules are cached for AdminCacheTTL.
+	OwnerIDs      []int64       `env:"OWNER_IDS"`
+	AdminCacheTTL time.Duration `env:"ADMIN_CACHE_TTL, default=1m"`
+
+	// With AllowlistOnly the bot answers only the users and the chats of the
+	// allow rules and the operators. The messages denied by the access rules
+	// are saved with StoreDeniedMessages.
+	AllowlistOnly       bool `env:"ALLOWLIST_ONLY, default=false"`
+	StoreDeniedMessages bool `env:"STORE_DENIED_MESSAGES, default=false"`
+}
+
+func (c *Config) sendLimits() telegram.Limits {
+	return telegram.Limits{
+		Rate:          c.SendRate,
+		Burst:         c.SendBurst,
+		ChatInterval:  c.SendChatInterval,
+		GroupInterval: c.SendGroupInterval,
+		MaxRetries:    c.SendMaxRetries,
+	}
 }
 
 func (c *Config) SecretManagerConfig() *secrets.Config {
@@ -27,6 +178,10 @@ func (c *Config) DatabaseConfig() *database.Config {
 	return &c.Database
 }
 
+func (c *Config) BlobstoreConfig() *storage.Config {
+	return &c.Blobstore
+}
+
 func (c *Config) FluentConfig() *zapfluentd.Config {
 	return &c.Fluent
 }
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// ListAccessRules returns all the access rules in the creation order.
+func (db *TgBotDB) ListAccessRules(ctx context.Context) ([]*model.AccessRule, error) {
+	const q = `
+		SELECT
+			id, action, kind, value, note, created_by, created_at
+		FROM
+			access_rules
+		WHERE
+			bot_name = $1
+		ORDER BY
+			created_at, id
+	`
+
+	rows, err := db.db.Pool.Query(ctx, q, db.bot)
+	if err != nil {
+		return nil, fmt.Errorf("reading access rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*model.AccessRule
+	for rows.Next() {
+		var r model.AccessRule
+		if err := rows.Scan(&r.ID, &r.Action, &r.Kind, &r.Value, &r.Note, &r.CreatedBy, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("reading access rule: %w", err)
+		}
+		rules = append(rules, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading access rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// AddAccessRule saves the rule and sets its ID. Adding the same rule again
+// only updates the note.
+func (db *TgBotDB) AddAccessRule(ctx context.Context, r *model.AccessRule) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				access_rules
+				(bot_name, action, kind, value, note, created_by)
+			VALUES
+				($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (bot_name, action, kind, value) DO UPDATE SET
+				note = excluded.note
+			RETURNING id
+		`
+			if err := tx.QueryRow(ctx, q, db.bot, r.Action, r.Kind, r.Value, r.Note, r.CreatedBy).Scan(&r.ID); err != nil {
+				return fmt.Errorf("saving access rule: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// DeleteAccessRule deletes the rule. It returns database.ErrNotFound if
+// there is no such rule.
+func (db *TgBotDB) DeleteAccessRule(ctx context.Context, id int64) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			result, err := tx.Exec(ctx, `DELETE FROM access_rules WHERE bot_name = $1 AND id = $2`, db.bot, id)
+			if err != nil {
+				return fmt.Errorf("deleting access rule: %w", err)
+			}
+			if result.RowsAffected() == 0 {
+				return database.ErrNotFound
+			}
+
+			return nil
+		},
+	)
+}
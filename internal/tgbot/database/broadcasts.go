@@ -0,0 +1,405 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// The broadcast statuses.
+const (
+	BroadcastRunning   = "running"
+	BroadcastDone      = "done"
+	BroadcastCancelled = "cancelled"
+)
+
+// The delivery statuses of the broadcast recipients.
+const (
+	RecipientPending = "pending"
+	RecipientSent    = "sent"
+	RecipientFailed  = "failed"
+	RecipientBlocked = "blocked"
+)
+
+// CreateBroadcast saves the broadcast and its recipients selected by the
+// audience. The recipients are fixed on creation, so an interrupted broadcast
+// resumes with the same ones. It sets the ID and the creation time.
+func (db *TgBotDB) CreateBroadcast(ctx context.Context, bc *model.Broadcast) error {
+	var seenSince *time.Time
+	if !bc.Audience.SeenSince.IsZero() {
+		seenSince = &bc.Audience.SeenSince
+	}
+
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				broadcasts
+				(bot_name, message_text, media_kind, media_file_id, reply_markup,
+				 language_code, seen_since, include_groups, created_by)
+			VALUES
+				($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING
+				id, status, created_at
+		`
+			if err := tx.QueryRow(
+				ctx, q, db.bot, bc.Text, bc.MediaKind, bc.MediaFileID, bc.ReplyMarkup,
+				bc.Audience.LanguageCode, seenSince, bc.Audience.Groups, bc.CreatedBy,
+			).Scan(&bc.ID, &bc.Status, &bc.CreatedAt); err != nil {
+				return fmt.Errorf("saving broadcast: %w", err)
+			}
+
+			// The users are reached in their private chats with the bot.
+			const usersQ = `
+			INSERT INTO
+				broadcast_recipients
+				(broadcast_id, chat_id)
+			SELECT
+				$1, c.chat_id
+			FROM
+				users AS u
+				JOIN chats AS c ON c.bot_name = u.bot_name AND c.chat_id = u.user_id::text
+			WHERE
+				u.bot_name = $2
+				AND c.type = 'private'
+				AND c.member_status NOT IN ('left', 'kicked')
+				AND NOT u.blocked
+				AND NOT u.is_bot
+				AND ($3 = '' OR u.language_code = $3)
+				AND ($4::timestamptz IS NULL OR u.last_seen_at >= $4)
+		`
+			if _, err := tx.Exec(ctx, usersQ, bc.ID, db.bot, bc.Audience.LanguageCode, seenSince); err != nil {
+				return fmt.Errorf("saving broadcast recipients: %w", err)
+			}
+
+			if bc.Audience.Groups {
+				const groupsQ = `
+				INSERT INTO
+					broadcast_recipients
+					(broadcast_id, chat_id)
+				SELECT
+					$1, chat_id
+				FROM
+					chats
+				WHERE
+					bot_name = $2
+					AND type IN ('group', 'supergroup')
+					AND member_status NOT IN ('left', 'kicked')
+			`
+				if _, err := tx.Exec(ctx, groupsQ, bc.ID, db.bot); err != nil {
+					return fmt.Errorf("saving broadcast recipients: %w", err)
+				}
+			}
+
+			return nil
+		},
+	)
+}
+
+const broadcastColumns = `
+	id, message_text, media_kind, media_file_id, reply_markup,
+	language_code, seen_since, include_groups, status, created_by, created_at, finished_at
+`
+
+func scanBroadcast(row pgx.Row) (*model.Broadcast, error) {
+	var (
+		bc         model.Broadcast
+		seenSince  *time.Time
+		finishedAt *time.Time
+	)
+	if err := row.Scan(
+		&bc.ID, &bc.Text, &bc.MediaKind, &bc.MediaFileID, &bc.ReplyMarkup,
+		&bc.Audience.LanguageCode, &seenSince, &bc.Audience.Groups, &bc.Status, &bc.CreatedBy, &bc.CreatedAt,
+		&finishedAt,
+	); err != nil {
+		return nil, err
+	}
+	if seenSince != nil {
+		bc.Audience.SeenSince = *seenSince
+	}
+	if finishedAt != nil {
+		bc.FinishedAt = *finishedAt
+	}
+	return &bc, nil
+}
+
+// GetBroadcast returns the broadcast with the recipient counts. It returns
+// database.ErrNotFound if there is no such broadcast.
+func (db *TgBotDB) GetBroadcast(ctx context.Context, id int64) (*model.Broadcast, error) {
+	q := `SELECT ` + broadcastColumns + ` FROM broadcasts WHERE bot_name = $1 AND id = $2`
+
+	bc, err := scanBroadcast(db.db.Pool.QueryRow(ctx, q, db.bot, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("reading broadcast: %w", err)
+	}
+
+	if err := db.countRecipients(ctx, []*model.Broadcast{bc}); err != nil {
+		return nil, err
+	}
+	return bc, nil
+}
+
+// ListBroadcasts returns up to limit broadcasts with the recipient counts,
+// the most recent first.
+func (db *TgBotDB) ListBroadcasts(ctx context.Context, limit int) ([]*model.Broadcast, error) {
+	q := `SELECT ` + broadcastColumns + ` FROM broadcasts WHERE bot_name = $1 ORDER BY created_at DESC LIMIT $2`
+
+	bcs, err := db.queryBroadcasts(ctx, q, db.bot, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.countRecipients(ctx, bcs); err != nil {
+		return nil, err
+	}
+	return bcs, nil
+}
+
+// ListRunningBroadcasts returns the broadcasts being sent, the oldest first.
+// The recipient counts are not set.
+func (db *TgBotDB) ListRunningBroadcasts(ctx context.Context) ([]*model.Broadcast, error) {
+	q := `SELECT ` + broadcastColumns + ` FROM broadcasts WHERE bot_name = $1 AND status = $2 ORDER BY created_at`
+	return db.queryBroadcasts(ctx, q, db.bot, BroadcastRunning)
+}
+
+func (db *TgBotDB) queryBroadcasts(ctx context.Context, q string, args ...interface{}) ([]*model.Broadcast, error) {
+	rows, err := db.db.Pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("reading broadcasts: %w", err)
+	}
+	defer rows.Close()
+
+	var bcs []*model.Broadcast
+	for rows.Next() {
+		bc, err := scanBroadcast(rows)
+		if err != nil {
+			return nil, fmt.Errorf("reading broadcast: %w", err)
+		}
+		bcs = append(bcs, bc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading broadcasts: %w", err)
+	}
+
+	return bcs, nil
+}
+
+// countRecipients sets the recipient counts of the broadcasts.
+func (db *TgBotDB) countRecipients(ctx context.Context, bcs []*model.Broadcast) error {
+	if len(bcs) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(bcs))
+	byID := make(map[int64]*model.Broadcast, len(bcs))
+	for i, bc := range bcs {
+		ids[i] = bc.ID
+		bc.Recipients = make(map[string]int64)
+		byID[bc.ID] = bc
+	}
+
+	const q = `
+		SELECT
+			broadcast_id, status, count(*)
+		FROM
+			broadcast_recipients
+		WHERE
+			broadcast_id = ANY($1)
+		GROUP BY
+			broadcast_id, status
+	`
+	rows, err := db.db.Pool.Query(ctx, q, ids)
+	if err != nil {
+		return fmt.Errorf("reading broadcast recipients: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id     int64
+			status string
+			n      int64
+		)
+		if err := rows.Scan(&id, &status, &n); err != nil {
+			return fmt.Errorf("reading broadcast recipients: %w", err)
+		}
+		byID[id].Recipients[status] = n
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading broadcast recipients: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimBroadcastRecipients returns up to limit pending recipients of the
+// broadcast. The claimed recipients are not returned again until leaseUntil,
+// so concurrent senders do not send twice, and the ones left unsent by a
+// stopped sender are sent after the lease.
+func (db *TgBotDB) ClaimBroadcastRecipients(
+	ctx context.Context, broadcastID int64, limit int, leaseUntil time.Time,
+) ([]*model.BroadcastRecipient, error) {
+	var recipients []*model.BroadcastRecipient
+
+	err := db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			UPDATE
+				broadcast_recipients
+			SET
+				claimed_until = $4
+			WHERE
+				broadcast_id = $1
+				AND chat_id IN (
+					SELECT chat_id FROM broadcast_recipients
+					WHERE broadcast_id = $1 AND status = $2 AND claimed_until <= now()
+					ORDER BY claimed_until
+					LIMIT $3
+				)
+			RETURNING
+				broadcast_id, chat_id, attempts
+		`
+			rows, err := tx.Query(ctx, q, broadcastID, RecipientPending, limit, leaseUntil)
+			if err != nil {
+				return fmt.Errorf("claiming broadcast recipients: %w", err)
+			}
+			defer rows.Close()
+
+			recipients = recipients[:0]
+			for rows.Next() {
+				var r model.BroadcastRecipient
+				if err := rows.Scan(&r.BroadcastID, &r.ChatID, &r.Attempts); err != nil {
+					return fmt.Errorf("reading broadcast recipient: %w", err)
+				}
+				recipients = append(recipients, &r)
+			}
+
+			return rows.Err()
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return recipients, nil
+}
+
+// SetBroadcastRecipientStatus records the delivery to the recipient.
+func (db *TgBotDB) SetBroadcastRecipientStatus(
+	ctx context.Context, r *model.BroadcastRecipient, status, lastError string,
+) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			UPDATE
+				broadcast_recipients
+			SET
+				status = $3,
+				last_error = $4,
+				sent_at = CASE WHEN $3 = 'sent' THEN now() END
+			WHERE
+				broadcast_id = $1 AND chat_id = $2
+		`
+			if _, err := tx.Exec(ctx, q, r.BroadcastID, r.ChatID, status, lastError); err != nil {
+				return fmt.Errorf("saving broadcast recipient: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// RetryBroadcastRecipient records the failed delivery to the recipient and
+// schedules the next attempt.
+func (db *TgBotDB) RetryBroadcastRecipient(
+	ctx context.Context, r *model.BroadcastRecipient, next time.Time, lastError string,
+) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			UPDATE
+				broadcast_recipients
+			SET
+				attempts = attempts + 1,
+				last_error = $3,
+				claimed_until = $4
+			WHERE
+				broadcast_id = $1 AND chat_id = $2
+		`
+			if _, err := tx.Exec(ctx, q, r.BroadcastID, r.ChatID, lastError, next); err != nil {
+				return fmt.Errorf("saving broadcast recipient: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// FinishBroadcast marks the running broadcast done if it has no pending
+// recipients. It reports whether the broadcast was finished.
+func (db *TgBotDB) FinishBroadcast(ctx context.Context, id int64) (bool, error) {
+	var finished bool
+
+	err := db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			UPDATE
+				broadcasts
+			SET
+				status = $2,
+				finished_at = now()
+			WHERE
+				id = $1
+				AND status = $3
+				AND NOT EXISTS (
+					SELECT 1 FROM broadcast_recipients
+					WHERE broadcast_id = $1 AND status = $4
+				)
+		`
+			result, err := tx.Exec(ctx, q, id, BroadcastDone, BroadcastRunning, RecipientPending)
+			if err != nil {
+				return fmt.Errorf("finishing broadcast: %w", err)
+			}
+			finished = result.RowsAffected() > 0
+
+			return nil
+		},
+	)
+
+	return finished, err
+}
+
+// CancelBroadcast stops the running broadcast. The pending recipients are
+// left unsent. It returns database.ErrNotFound if there is no such running
+// broadcast.
+func (db *TgBotDB) CancelBroadcast(ctx context.Context, id int64) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			UPDATE
+				broadcasts
+			SET
+				status = $2,
+				finished_at = now()
+			WHERE
+				bot_name = $4 AND id = $1 AND status = $3
+		`
+			result, err := tx.Exec(ctx, q, id, BroadcastCancelled, BroadcastRunning, db.bot)
+			if err != nil {
+				return fmt.Errorf("cancelling broadcast: %w", err)
+			}
+			if result.RowsAffected() == 0 {
+				return database.ErrNotFound
+			}
+
+			return nil
+		},
+	)
+}
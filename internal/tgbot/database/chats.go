@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// SaveChat saves the type, the title and the username of the chat. The
+// member status is saved only for a new chat.
+func (db *TgBotDB) SaveChat(ctx context.Context, c *model.Chat) error {
+	return db.upsertChat(ctx, c, false)
+}
+
+// SetChatMemberStatus saves the chat with the new member status of the bot.
+func (db *TgBotDB) SetChatMemberStatus(ctx context.Context, c *model.Chat) error {
+	return db.upsertChat(ctx, c, true)
+}
+
+func (db *TgBotDB) upsertChat(ctx context.Context, c *model.Chat, withStatus bool) error {
+	q := `
+		INSERT INTO
+			chats
+			(bot_name, chat_id, type, title, username, member_status)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (bot_name, chat_id) DO UPDATE SET
+			type = excluded.type,
+			title = excluded.title,
+			username = excluded.username,
+			updated_at = now()
+	`
+	if withStatus {
+		q += `, member_status = excluded.member_status`
+	}
+
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, q, db.bot, c.ID, c.Type, c.Title, c.Username, c.MemberStatus); err != nil {
+				return fmt.Errorf("saving chat: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// GetChat returns the chat. It returns database.ErrNotFound if the chat is
+// unknown.
+func (db *TgBotDB) GetChat(ctx context.Context, chatID string) (*model.Chat, error) {
+	const q = `
+		SELECT
+			chat_id, type, title, username, member_status, created_at, updated_at
+		FROM
+			chats
+		WHERE
+			bot_name = $1 AND chat_id = $2
+	`
+
+	var c model.Chat
+	if err := db.db.Pool.QueryRow(ctx, q, db.bot, chatID).Scan(
+		&c.ID, &c.Type, &c.Title, &c.Username, &c.MemberStatus, &c.CreatedAt, &c.UpdatedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("reading chat: %w", err)
+	}
+
+	return &c, nil
+}
+
+// MigrateChat moves the data of the group to the supergroup it was upgraded
+// to. The chat ID changes on the upgrade. Migrating the same chat again
+// changes nothing.
+func (db *TgBotDB) MigrateChat(ctx context.Context, fromChatID, toChatID string) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			// The chat can have only one state and one record, the ones of
+			// the group win. The record is updated by the next message.
+			for _, table := range []string{"chat_states", "chats"} {
+				q := fmt.Sprintf(
+					`DELETE FROM %s WHERE bot_name = $1 AND chat_id = $3
+					AND EXISTS (SELECT 1 FROM %[1]s WHERE bot_name = $1 AND chat_id = $2)`,
+					table,
+				)
+				if _, err := tx.Exec(ctx, q, db.bot, fromChatID, toChatID); err != nil {
+					return fmt.Errorf("migrating %s: %w", table, err)
+				}
+			}
+
+			for _, table := range []string{
+				"received_messages", "message_revisions", "received_media",
+				"chat_states", "chats", "outbox", "scheduled_jobs",
+			} {
+				q := fmt.Sprintf(`UPDATE %s SET chat_id = $3 WHERE bot_name = $1 AND chat_id = $2`, table)
+				if _, err := tx.Exec(ctx, q, db.bot, fromChatID, toChatID); err != nil {
+					return fmt.Errorf("migrating %s: %w", table, err)
+				}
+			}
+
+			// The recipients belong to the broadcasts of the bot. The
+			// supergroup already receiving a broadcast gets it once.
+			const deleteRecipients = `
+				DELETE FROM broadcast_recipients r
+				WHERE r.chat_id = $2
+					AND r.broadcast_id IN (SELECT id FROM broadcasts WHERE bot_name = $1)
+					AND EXISTS (
+						SELECT 1 FROM broadcast_recipients
+						WHERE broadcast_id = r.broadcast_id AND chat_id = $3
+					)
+			`
+			const updateRecipients = `
+				UPDATE broadcast_recipients SET chat_id = $3
+				WHERE chat_id = $2
+					AND broadcast_id IN (SELECT id FROM broadcasts WHERE bot_name = $1)
+			`
+			for _, q := range []string{deleteRecipients, updateRecipients} {
+				if _, err := tx.Exec(ctx, q, db.bot, fromChatID, toChatID); err != nil {
+					return fmt.Errorf("migrating broadcast_recipients: %w", err)
+				}
+			}
+
+			return nil
+		},
+	)
+}
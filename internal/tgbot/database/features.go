@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// ListFeatures returns the features switched by the operators. The features
+// never switched are not returned.
+func (db *TgBotDB) ListFeatures(ctx context.Context) ([]*model.Feature, error) {
+	const q = `SELECT name, enabled, updated_by, updated_at FROM features WHERE bot_name = $1 ORDER BY name`
+
+	rows, err := db.db.Pool.Query(ctx, q, db.bot)
+	if err != nil {
+		return nil, fmt.Errorf("reading features: %w", err)
+	}
+	defer rows.Close()
+
+	var features []*model.Feature
+	for rows.Next() {
+		var f model.Feature
+		if err := rows.Scan(&f.Name, &f.Enabled, &f.UpdatedBy, &f.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("reading features: %w", err)
+		}
+		features = append(features, &f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading features: %w", err)
+	}
+
+	return features, nil
+}
+
+// SetFeature switches the feature on or off.
+func (db *TgBotDB) SetFeature(ctx context.Context, f *model.Feature) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				features
+				(bot_name, name, enabled, updated_by)
+			VALUES
+				($1, $2, $3, $4)
+			ON CONFLICT (bot_name, name) DO UPDATE SET
+				enabled = excluded.enabled,
+				updated_by = excluded.updated_by,
+				updated_at = now()
+		`
+			if _, err := tx.Exec(ctx, q, db.bot, f.Name, f.Enabled, f.UpdatedBy); err != nil {
+				return fmt.Errorf("saving feature: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// floodCounterColumns is the number of the columns SaveFloodCounters inserts
+// per counter.
+const floodCounterColumns = 4
+
+// ListFloodCounters returns the counters of the day and the ones with an
+// active mute.
+func (db *TgBotDB) ListFloodCounters(ctx context.Context, day time.Time) ([]*model.FloodCounter, error) {
+	const q = `
+		SELECT
+			key, day, daily_count, muted_until
+		FROM
+			flood_counters
+		WHERE
+			bot_name = $1 AND (day = $2 OR muted_until > now())
+	`
+	rows, err := db.db.Pool.Query(ctx, q, db.bot, day)
+	if err != nil {
+		return nil, fmt.Errorf("reading flood counters: %w", err)
+	}
+	defer rows.Close()
+
+	var counters []*model.FloodCounter
+	for rows.Next() {
+		var (
+			c          model.FloodCounter
+			mutedUntil *time.Time
+		)
+		if err := rows.Scan(&c.Key, &c.Day, &c.DailyCount, &mutedUntil); err != nil {
+			return nil, fmt.Errorf("reading flood counter: %w", err)
+		}
+		if mutedUntil != nil {
+			c.MutedUntil = *mutedUntil
+		}
+		counters = append(counters, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading flood counters: %w", err)
+	}
+
+	return counters, nil
+}
+
+// SaveFloodCounters saves the counters in one statement. The replicas count
+// separately, so the larger count and the later mute of the same day win.
+// The counters of the past days without an active mute are deleted.
+func (db *TgBotDB) SaveFloodCounters(ctx context.Context, counters []*model.FloodCounter) error {
+	if len(counters) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(counters))
+	args := make([]interface{}, 0, 1+len(counters)*floodCounterColumns)
+	args = append(args, db.bot)
+	for i, c := range counters {
+		n := 1 + i*floodCounterColumns
+		values = append(values, fmt.Sprintf("($1, $%d, $%d::date, $%d, $%d::timestamptz)", n+1, n+2, n+3, n+4))
+
+		var mutedUntil *time.Time
+		if !c.MutedUntil.IsZero() {
+			mutedUntil = &c.MutedUntil
+		}
+		args = append(args, c.Key, c.Day, c.DailyCount, mutedUntil)
+	}
+
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			q := `
+			INSERT INTO
+				flood_counters
+				(bot_name, key, day, daily_count, muted_until)
+			VALUES
+				` + strings.Join(values, ", ") + `
+			ON CONFLICT (bot_name, key) DO UPDATE SET
+				daily_count = CASE
+					WHEN flood_counters.day = excluded.day
+					THEN greatest(flood_counters.daily_count, excluded.daily_count)
+					ELSE excluded.daily_count
+				END,
+				day = excluded.day,
+				muted_until = greatest(flood_counters.muted_until, excluded.muted_until),
+				updated_at = now()
+		`
+			if _, err := tx.Exec(ctx, q, args...); err != nil {
+				return fmt.Errorf("saving flood counters: %w", err)
+			}
+
+			const cleanup = `
+			DELETE FROM
+				flood_counters
+			WHERE
+				bot_name = $1 AND day < current_date() AND (muted_until IS NULL OR muted_until < now())
+		`
+			if _, err := tx.Exec(ctx, cleanup, db.bot); err != nil {
+				return fmt.Errorf("deleting flood counters: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+const scheduledJobColumns = `
+	id, kind, chat_id, created_by, message_text, schedule, time_zone,
+	next_run_at, runs, last_run_at, created_at
+`
+
+func scanScheduledJob(row pgx.Row) (*model.ScheduledJob, error) {
+	var (
+		job       model.ScheduledJob
+		lastRunAt *time.Time
+	)
+	if err := row.Scan(
+		&job.ID, &job.Kind, &job.ChatID, &job.CreatedBy, &job.Text, &job.Schedule, &job.TimeZone,
+		&job.NextRunAt, &job.Runs, &lastRunAt, &job.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if lastRunAt != nil {
+		job.LastRunAt = *lastRunAt
+	}
+	return &job, nil
+}
+
+// AddScheduledJob saves the job. It sets the ID and the creation time.
+func (db *TgBotDB) AddScheduledJob(ctx context.Context, job *model.ScheduledJob) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				scheduled_jobs
+				(bot_name, kind, chat_id, created_by, message_text, schedule, time_zone, next_run_at)
+			VALUES
+				($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING
+				id, created_at
+		`
+			if err := tx.QueryRow(
+				ctx, q, db.bot, job.Kind, job.ChatID, job.CreatedBy, job.Text, job.Schedule, job.TimeZone, job.NextRunAt,
+			).Scan(&job.ID, &job.CreatedAt); err != nil {
+				return fmt.Errorf("saving scheduled job: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// GetScheduledJob returns the job. It returns database.ErrNotFound if there
+// is no such job.
+func (db *TgBotDB) GetScheduledJob(ctx context.Context, id int64) (*model.ScheduledJob, error) {
+	q := `SELECT ` + scheduledJobColumns + ` FROM scheduled_jobs WHERE bot_name = $1 AND id = $2`
+
+	job, err := scanScheduledJob(db.db.Pool.QueryRow(ctx, q, db.bot, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("reading scheduled job: %w", err)
+	}
+
+	return job, nil
+}
+
+// ListScheduledJobs returns the jobs created by the user, the nearest first.
+func (db *TgBotDB) ListScheduledJobs(ctx context.Context, createdBy int64) ([]*model.ScheduledJob, error) {
+	q := `SELECT ` + scheduledJobColumns + `
+		FROM scheduled_jobs WHERE bot_name = $1 AND created_by = $2 ORDER BY next_run_at`
+
+	rows, err := db.db.Pool.Query(ctx, q, db.bot, createdBy)
+	if err != nil {
+		return nil, fmt.Errorf("reading scheduled jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*model.ScheduledJob
+	for rows.Next() {
+		job, err := scanScheduledJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("reading scheduled job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading scheduled jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// DeleteScheduledJob deletes the job. It returns database.ErrNotFound if there
+// is no such job.
+func (db *TgBotDB) DeleteScheduledJob(ctx context.Context, id int64) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `DELETE FROM scheduled_jobs WHERE bot_name = $1 AND id = $2`
+
+			result, err := tx.Exec(ctx, q, db.bot, id)
+			if err != nil {
+				return fmt.Errorf("deleting scheduled job: %w", err)
+			}
+			if result.RowsAffected() == 0 {
+				return database.ErrNotFound
+			}
+
+			return nil
+		},
+	)
+}
+
+// ClaimScheduledJobs returns up to limit due jobs. The claimed jobs are not
+// returned again until leaseUntil, so the replicas polling the table do not
+// run a job twice, and a job left by a stopped replica runs after the lease.
+func (db *TgBotDB) ClaimScheduledJobs(
+	ctx context.Context, limit int, leaseUntil time.Time,
+) ([]*model.ScheduledJob, error) {
+	var jobs []*model.ScheduledJob
+
+	err := db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			q := `
+			UPDATE
+				scheduled_jobs
+			SET
+				claimed_until = $2
+			WHERE
+				id IN (
+					SELECT id FROM scheduled_jobs
+					WHERE bot_name = $3 AND next_run_at <= now() AND claimed_until <= now()
+					ORDER BY next_run_at
+					LIMIT $1
+				)
+			RETURNING
+		` + scheduledJobColumns
+			rows, err := tx.Query(ctx, q, limit, leaseUntil, db.bot)
+			if err != nil {
+				return fmt.Errorf("claiming scheduled jobs: %w", err)
+			}
+			defer rows.Close()
+
+			jobs = jobs[:0]
+			for rows.Next() {
+				job, err := scanScheduledJob(rows)
+				if err != nil {
+					return fmt.Errorf("reading scheduled job: %w", err)
+				}
+				jobs = append(jobs, job)
+			}
+
+			return rows.Err()
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// RescheduleJob records the run of the recurring job and releases it until
+// the next run.
+func (db *TgBotDB) RescheduleJob(ctx context.Context, id int64, next time.Time) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			UPDATE
+				scheduled_jobs
+			SET
+				next_run_at = $2,
+				claimed_until = now(),
+				runs = runs + 1,
+				last_run_at = now()
+			WHERE
+				id = $1
+		`
+			if _, err := tx.Exec(ctx, q, id, next); err != nil {
+				return fmt.Errorf("rescheduling job: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// AddMedia saves the file attached to the received message. The media
+// already saved is left as it is.
+func (db *TgBotDB) AddMedia(ctx context.Context, m *model.Media) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				received_media
+				(bot_name, chat_id, telegram_message_id, user_id, kind, file_id, file_unique_id,
+				 mime_type, file_size, caption, content_hash)
+			VALUES
+				($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (bot_name, chat_id, telegram_message_id) DO NOTHING
+		`
+			if _, err := tx.Exec(
+				ctx, q, db.bot, m.ChatID, m.TgMessageID, m.UserID, m.Kind, m.FileID, m.FileUniqueID,
+				m.MIMEType, m.FileSize, m.Caption, m.ContentHash,
+			); err != nil {
+				return fmt.Errorf("saving media: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// FindMediaContentHash returns the content hash of the file downloaded
+// earlier by any bot, the blob store is shared. It returns
+// database.ErrNotFound if the file was not downloaded.
+func (db *TgBotDB) FindMediaContentHash(ctx context.Context, fileUniqueID string) (string, error) {
+	const q = `
+		SELECT
+			content_hash
+		FROM
+			received_media
+		WHERE
+			file_unique_id = $1 AND content_hash != ''
+		LIMIT 1
+	`
+
+	var hash string
+	if err := db.db.Pool.QueryRow(ctx, q, fileUniqueID).Scan(&hash); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", database.ErrNotFound
+		}
+		return "", fmt.Errorf("reading media content hash: %w", err)
+	}
+
+	return hash, nil
+}
+
+// SetMediaContentHash records that the file of the message is downloaded to
+// the blob store.
+func (db *TgBotDB) SetMediaContentHash(ctx context.Context, chatID string, messageID int64, hash string) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			UPDATE
+				received_media
+			SET
+				content_hash = $3
+			WHERE
+				bot_name = $4 AND chat_id = $1 AND telegram_message_id = $2
+		`
+			if _, err := tx.Exec(ctx, q, chatID, messageID, hash, db.bot); err != nil {
+				return fmt.Errorf("saving media content hash: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// MediaBlobExists reports whether the contents with the hash are in the blob
+// store.
+func (db *TgBotDB) MediaBlobExists(ctx context.Context, hash string) (bool, error) {
+	const q = `SELECT EXISTS (SELECT 1 FROM media_blobs WHERE content_hash = $1)`
+
+	var exists bool
+	if err := db.db.Pool.QueryRow(ctx, q, hash).Scan(&exists); err != nil {
+		return false, fmt.Errorf("reading media blob: %w", err)
+	}
+
+	return exists, nil
+}
+
+// AddMediaBlob records the contents written to the blob store.
+func (db *TgBotDB) AddMediaBlob(ctx context.Context, hash string, size int64, mimeType string) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				media_blobs
+				(content_hash, size, mime_type)
+			VALUES
+				($1, $2, $3)
+			ON CONFLICT (content_hash) DO NOTHING
+		`
+			if _, err := tx.Exec(ctx, q, hash, size, mimeType); err != nil {
+				return fmt.Errorf("saving media blob: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
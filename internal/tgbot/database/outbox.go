@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// AddOutboxMessage saves the outgoing message and sets its ID. The message
+// is not delivered before msg.NextAttemptAt.
+func (db *TgBotDB) AddOutboxMessage(ctx context.Context, msg *model.OutboxMessage) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				outbox
+				(bot_name, chat_id, message_text, reply_to_message_id, reply_markup, next_attempt_at)
+			VALUES
+				($1, $2, $3, $4, $5, $6)
+			RETURNING
+				id, created_at
+		`
+			if err := tx.QueryRow(
+				ctx, q, db.bot, msg.ChatID, msg.Text, msg.ReplyToMessageID, msg.ReplyMarkup, msg.NextAttemptAt,
+			).Scan(&msg.ID, &msg.CreatedAt); err != nil {
+				return fmt.Errorf("saving outbox message: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// ClaimOutboxMessages returns up to limit messages due for delivery. The
+// claimed messages are not returned again until leaseUntil, so concurrent
+// dispatchers do not send them twice.
+func (db *TgBotDB) ClaimOutboxMessages(
+	ctx context.Context, limit int, leaseUntil time.Time,
+) ([]*model.OutboxMessage, error) {
+	var msgs []*model.OutboxMessage
+
+	err := db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			UPDATE
+				outbox
+			SET
+				next_attempt_at = $2
+			WHERE
+				id IN (
+					SELECT id FROM outbox
+					WHERE bot_name = $3 AND next_attempt_at <= now()
+					ORDER BY next_attempt_at
+					LIMIT $1
+				)
+			RETURNING
+				id, chat_id, message_text, reply_to_message_id, reply_markup,
+				attempts, last_error, next_attempt_at, created_at
+		`
+			rows, err := tx.Query(ctx, q, limit, leaseUntil, db.bot)
+			if err != nil {
+				return fmt.Errorf("claiming outbox messages: %w", err)
+			}
+			defer rows.Close()
+
+			msgs = msgs[:0]
+			for rows.Next() {
+				var msg model.OutboxMessage
+				if err := rows.Scan(
+					&msg.ID, &msg.ChatID, &msg.Text, &msg.ReplyToMessageID, &msg.ReplyMarkup,
+					&msg.Attempts, &msg.LastError, &msg.NextAttemptAt, &msg.CreatedAt,
+				); err != nil {
+					return fmt.Errorf("reading outbox message: %w", err)
+				}
+				msgs = append(msgs, &msg)
+			}
+
+			return rows.Err()
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return msgs, nil
+}
+
+// DeleteOutboxMessage removes the delivered message.
+func (db *TgBotDB) DeleteOutboxMessage(ctx context.Context, id int64) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `DELETE FROM outbox WHERE id = $1`
+			if _, err := tx.Exec(ctx, q, id); err != nil {
+				return fmt.Errorf("deleting outbox message: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// RetryOutboxMessage records the failed delivery and schedules the next one.
+func (db *TgBotDB) RetryOutboxMessage(
+	ctx context.Context, id int64, nextAttemptAt time.Time, lastError string,
+) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			UPDATE
+				outbox
+			SET
+				attempts = attempts + 1,
+				last_error = $2,
+				next_attempt_at = $3
+			WHERE
+				id = $1
+		`
+			if _, err := tx.Exec(ctx, q, id, lastError, nextAttemptAt); err != nil {
+				return fmt.Errorf("saving outbox message retry: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// DeadLetterOutboxMessage moves the message that cannot be delivered to the
+// dead letters.
+func (db *TgBotDB) DeadLetterOutboxMessage(ctx context.Context, id int64, lastError string) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const insert = `
+			INSERT INTO
+				outbox_dead_letters
+				(id, bot_name, chat_id, message_text, reply_to_message_id, reply_markup, attempts, last_error, created_at)
+			SELECT
+				id, bot_name, chat_id, message_text, reply_to_message_id, reply_markup, attempts + 1, $2, created_at
+			FROM
+				outbox
+			WHERE
+				id = $1
+		`
+			if _, err := tx.Exec(ctx, insert, id, lastError); err != nil {
+				return fmt.Errorf("saving dead letter: %w", err)
+			}
+
+			const q = `DELETE FROM outbox WHERE id = $1`
+			if _, err := tx.Exec(ctx, q, id); err != nil {
+				return fmt.Errorf("deleting outbox message: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// ListDeadLetters returns up to limit dead letters, the most recent first.
+func (db *TgBotDB) ListDeadLetters(ctx context.Context, limit int) ([]*model.DeadLetter, error) {
+	const q = `
+		SELECT
+			id, chat_id, message_text, reply_to_message_id, reply_markup,
+			attempts, last_error, created_at, failed_at
+		FROM
+			outbox_dead_letters
+		WHERE
+			bot_name = $1
+		ORDER BY
+			failed_at DESC
+		LIMIT $2
+	`
+
+	rows, err := db.db.Pool.Query(ctx, q, db.bot, limit)
+	if err != nil {
+		return nil, fmt.Errorf("reading dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []*model.DeadLetter
+	for rows.Next() {
+		var l model.DeadLetter
+		if err := rows.Scan(
+			&l.ID, &l.ChatID, &l.Text, &l.ReplyToMessageID, &l.ReplyMarkup,
+			&l.Attempts, &l.LastError, &l.CreatedAt, &l.FailedAt,
+		); err != nil {
+			return nil, fmt.Errorf("reading dead letter: %w", err)
+		}
+		letters = append(letters, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading dead letters: %w", err)
+	}
+
+	return letters, nil
+}
+
+// ReplayDeadLetter moves the dead letter back to the outbox for immediate
+// delivery. It returns database.ErrNotFound if there is no such dead letter.
+func (db *TgBotDB) ReplayDeadLetter(ctx context.Context, id int64) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const insert = `
+			INSERT INTO
+				outbox
+				(id, bot_name, chat_id, message_text, reply_to_message_id, reply_markup, created_at)
+			SELECT
+				id, bot_name, chat_id, message_text, reply_to_message_id, reply_markup, created_at
+			FROM
+				outbox_dead_letters
+			WHERE
+				bot_name = $1 AND id = $2
+		`
+			result, err := tx.Exec(ctx, insert, db.bot, id)
+			if err != nil {
+				return fmt.Errorf("saving outbox message: %w", err)
+			}
+			if result.RowsAffected() == 0 {
+				return database.ErrNotFound
+			}
+
+			const q = `DELETE FROM outbox_dead_letters WHERE id = $1`
+			if _, err := tx.Exec(ctx, q, id); err != nil {
+				return fmt.Errorf("deleting dead letter: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
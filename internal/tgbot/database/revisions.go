@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// EditUserMessage replaces the text of the saved message with the edited one
+// and keeps the replaced text as a revision. Edits not newer than the last
+// saved one are ignored, so a repeated update changes nothing. It returns
+// database.ErrNotFound if the message was not saved.
+func (db *TgBotDB) EditUserMessage(ctx context.Context, msg *model.Message) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const sel = `
+			SELECT
+				message_text, revision, edited_at
+			FROM
+				received_messages
+			WHERE
+				bot_name = $3 AND chat_id = $1 AND telegram_message_id = $2
+			FOR UPDATE
+		`
+			var (
+				text     string
+				revision int
+				editedAt *time.Time
+			)
+			if err := tx.QueryRow(ctx, sel, msg.ChatID, msg.TgMessageID, db.bot).Scan(&text, &revision, &editedAt); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return database.ErrNotFound
+				}
+				return fmt.Errorf("reading message: %w", err)
+			}
+			if editedAt != nil && !msg.EditedAt.After(*editedAt) {
+				return nil
+			}
+
+			const insert = `
+			INSERT INTO
+				message_revisions
+				(bot_name, chat_id, telegram_message_id, revision, message_text, replaced_at)
+			VALUES
+				($1, $2, $3, $4, $5, $6)
+		`
+			if _, err := tx.Exec(
+				ctx, insert, db.bot, msg.ChatID, msg.TgMessageID, revision, text, msg.EditedAt,
+			); err != nil {
+				return fmt.Errorf("saving message revision: %w", err)
+			}
+
+			const update = `
+			UPDATE
+				received_messages
+			SET
+				message_text = $3,
+				revision = $4,
+				edited_at = $5
+			WHERE
+				bot_name = $6 AND chat_id = $1 AND telegram_message_id = $2
+		`
+			if _, err := tx.Exec(
+				ctx, update, msg.ChatID, msg.TgMessageID, msg.Text, revision+1, msg.EditedAt, db.bot,
+			); err != nil {
+				return fmt.Errorf("saving message: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// GetUserMessage returns the saved message with its latest text. It returns
+// database.ErrNotFound if there is no such message.
+func (db *TgBotDB) GetUserMessage(ctx context.Context, chatID string, messageID int64) (*model.Message, error) {
+	const q = `
+		SELECT
+			telegram_message_id, user_id, chat_id, message_text, revision, edited_at
+		FROM
+			received_messages
+		WHERE
+			bot_name = $3 AND chat_id = $1 AND telegram_message_id = $2
+	`
+
+	var (
+		msg      model.Message
+		editedAt *time.Time
+	)
+	if err := db.db.Pool.QueryRow(ctx, q, chatID, messageID, db.bot).Scan(
+		&msg.TgMessageID, &msg.UserID, &msg.ChatID, &msg.Text, &msg.Revision, &editedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+	if editedAt != nil {
+		msg.EditedAt = *editedAt
+	}
+
+	return &msg, nil
+}
+
+// ListMessageRevisions returns the replaced texts of the message, the
+// original first.
+func (db *TgBotDB) ListMessageRevisions(
+	ctx context.Context, chatID string, messageID int64,
+) ([]*model.MessageRevision, error) {
+	const q = `
+		SELECT
+			chat_id, telegram_message_id, revision, message_text, replaced_at
+		FROM
+			message_revisions
+		WHERE
+			bot_name = $3 AND chat_id = $1 AND telegram_message_id = $2
+		ORDER BY
+			revision
+	`
+
+	rows, err := db.db.Pool.Query(ctx, q, chatID, messageID, db.bot)
+	if err != nil {
+		return nil, fmt.Errorf("reading message revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []*model.MessageRevision
+	for rows.Next() {
+		var r model.MessageRevision
+		if err := rows.Scan(&r.ChatID, &r.TgMessageID, &r.Revision, &r.Text, &r.ReplacedAt); err != nil {
+			return nil, fmt.Errorf("reading message revision: %w", err)
+		}
+		revisions = append(revisions, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading message revisions: %w", err)
+	}
+
+	return revisions, nil
+}
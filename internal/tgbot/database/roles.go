@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// GetUserRole returns the role of the user. It returns database.ErrNotFound
+// if the user has no role.
+func (db *TgBotDB) GetUserRole(ctx context.Context, userID int64) (*model.UserRole, error) {
+	const q = `SELECT user_id, role, granted_by, updated_at FROM user_roles WHERE bot_name = $1 AND user_id = $2`
+
+	var r model.UserRole
+	if err := db.db.Pool.QueryRow(ctx, q, db.bot, userID).Scan(&r.UserID, &r.Role, &r.GrantedBy, &r.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("reading user role: %w", err)
+	}
+
+	return &r, nil
+}
+
+// ListUserRoles returns the users with a role.
+func (db *TgBotDB) ListUserRoles(ctx context.Context) ([]*model.UserRole, error) {
+	const q = `SELECT user_id, role, granted_by, updated_at FROM user_roles WHERE bot_name = $1 ORDER BY user_id`
+
+	rows, err := db.db.Pool.Query(ctx, q, db.bot)
+	if err != nil {
+		return nil, fmt.Errorf("reading user roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []*model.UserRole
+	for rows.Next() {
+		var r model.UserRole
+		if err := rows.Scan(&r.UserID, &r.Role, &r.GrantedBy, &r.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("reading user roles: %w", err)
+		}
+		roles = append(roles, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading user roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+// SetUserRole saves the role of the user.
+func (db *TgBotDB) SetUserRole(ctx context.Context, r *model.UserRole) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				user_roles
+				(bot_name, user_id, role, granted_by)
+			VALUES
+				($1, $2, $3, $4)
+			ON CONFLICT (bot_name, user_id) DO UPDATE SET
+				role = excluded.role,
+				granted_by = excluded.granted_by,
+				updated_at = now()
+		`
+			if _, err := tx.Exec(ctx, q, db.bot, r.UserID, r.Role, r.GrantedBy); err != nil {
+				return fmt.Errorf("saving user role: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// DeleteUserRole takes the role away from the user.
+func (db *TgBotDB) DeleteUserRole(ctx context.Context, userID int64) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, `DELETE FROM user_roles WHERE bot_name = $1 AND user_id = $2`, db.bot, userID); err != nil {
+				return fmt.Errorf("deleting user role: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// GetUserLocale returns the locale chosen by the user. It returns
+// database.ErrNotFound if the user has not chosen one.
+func (db *TgBotDB) GetUserLocale(ctx context.Context, userID int64) (string, error) {
+	const q = `SELECT locale FROM user_settings WHERE bot_name = $1 AND user_id = $2`
+
+	var locale string
+	if err := db.db.Pool.QueryRow(ctx, q, db.bot, userID).Scan(&locale); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", database.ErrNotFound
+		}
+		return "", fmt.Errorf("reading user locale: %w", err)
+	}
+
+	return locale, nil
+}
+
+// SetUserLocale saves the locale chosen by the user.
+func (db *TgBotDB) SetUserLocale(ctx context.Context, userID int64, locale string) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				user_settings
+				(bot_name, user_id, locale)
+			VALUES
+				($1, $2, $3)
+			ON CONFLICT (bot_name, user_id) DO UPDATE SET
+				locale = excluded.locale
+		`
+			if _, err := tx.Exec(ctx, q, db.bot, userID, locale); err != nil {
+				return fmt.Errorf("saving user locale: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// GetChatSettings returns the configuration of the chat. It returns
+// database.ErrNotFound if the chat is unknown.
+func (db *TgBotDB) GetChatSettings(ctx context.Context, chatID string) (*model.ChatSettings, error) {
+	const q = `SELECT settings FROM chats WHERE bot_name = $1 AND chat_id = $2`
+
+	var data []byte
+	if err := db.db.Pool.QueryRow(ctx, q, db.bot, chatID).Scan(&data); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("reading chat settings: %w", err)
+	}
+
+	s := model.ChatSettings{ChatID: chatID}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("decoding chat settings: %w", err)
+	}
+
+	return &s, nil
+}
+
+// SetChatSettings saves the configuration of the chat.
+func (db *TgBotDB) SetChatSettings(ctx context.Context, s *model.ChatSettings) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("encoding chat settings: %w", err)
+	}
+
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				chats
+				(bot_name, chat_id, settings)
+			VALUES
+				($1, $2, $3)
+			ON CONFLICT (bot_name, chat_id) DO UPDATE SET
+				settings = excluded.settings,
+				updated_at = now()
+		`
+			if _, err := tx.Exec(ctx, q, db.bot, s.ChatID, data); err != nil {
+				return fmt.Errorf("saving chat settings: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// GetUserTimeZone returns the time zone chosen by the user. It returns
+// database.ErrNotFound if the user has not chosen one.
+func (db *TgBotDB) GetUserTimeZone(ctx context.Context, userID int64) (string, error) {
+	const q = `SELECT time_zone FROM user_settings WHERE bot_name = $1 AND user_id = $2`
+
+	var tz string
+	if err := db.db.Pool.QueryRow(ctx, q, db.bot, userID).Scan(&tz); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", database.ErrNotFound
+		}
+		return "", fmt.Errorf("reading user time zone: %w", err)
+	}
+	if tz == "" {
+		return "", database.ErrNotFound
+	}
+
+	return tz, nil
+}
+
+// SetUserTimeZone saves the time zone chosen by the user.
+func (db *TgBotDB) SetUserTimeZone(ctx context.Context, userID int64, tz string) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				user_settings
+				(bot_name, user_id, time_zone)
+			VALUES
+				($1, $2, $3)
+			ON CONFLICT (bot_name, user_id) DO UPDATE SET
+				time_zone = excluded.time_zone
+		`
+			if _, err := tx.Exec(ctx, q, db.bot, userID, tz); err != nil {
+				return fmt.Errorf("saving user time zone: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
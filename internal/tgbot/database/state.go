@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// GetChatState returns the state of the chat. It returns database.ErrNotFound
+// if the chat has no state or the state has expired.
+func (db *TgBotDB) GetChatState(ctx context.Context, chatID string) (*model.ChatState, error) {
+	const q = `
+		SELECT
+			state, state_data, expires_at
+		FROM
+			chat_states
+		WHERE
+			bot_name = $1 AND chat_id = $2 AND expires_at > now()
+	`
+
+	state := model.ChatState{ChatID: chatID}
+	var data []byte
+	if err := db.db.Pool.QueryRow(ctx, q, db.bot, chatID).Scan(
+		&state.State, &data, &state.ExpiresAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("reading chat state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &state.Data); err != nil {
+		return nil, fmt.Errorf("decoding chat state data: %w", err)
+	}
+
+	return &state, nil
+}
+
+// SetChatState saves the state of the chat, replacing the previous one.
+func (db *TgBotDB) SetChatState(ctx context.Context, state *model.ChatState) error {
+	data, err := json.Marshal(state.Data)
+	if err != nil {
+		return fmt.Errorf("encoding chat state data: %w", err)
+	}
+
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				chat_states
+				(bot_name, chat_id, state, state_data, expires_at)
+			VALUES
+				($1, $2, $3, $4, $5)
+			ON CONFLICT (bot_name, chat_id) DO UPDATE SET
+				state = excluded.state,
+				state_data = excluded.state_data,
+				expires_at = excluded.expires_at
+		`
+			if _, err := tx.Exec(
+				ctx, q, db.bot, state.ChatID, state.State, string(data), state.ExpiresAt,
+			); err != nil {
+				return fmt.Errorf("saving chat state: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// DeleteChatState removes the state of the chat.
+func (db *TgBotDB) DeleteChatState(ctx context.Context, chatID string) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `DELETE FROM chat_states WHERE bot_name = $1 AND chat_id = $2`
+			if _, err := tx.Exec(ctx, q, db.bot, chatID); err != nil {
+				return fmt.Errorf("deleting chat state: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
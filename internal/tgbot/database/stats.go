@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+)
+
+// GetStats returns the totals of the users, the chats and the outgoing
+// messages.
+func (db *TgBotDB) GetStats(ctx context.Context) (*model.Stats, error) {
+	s := model.Stats{Chats: make(map[string]int64)}
+
+	const q = `
+		SELECT
+			(SELECT count(*) FROM users WHERE bot_name = $1),
+			(SELECT count(*) FROM users WHERE bot_name = $1 AND blocked),
+			(SELECT count(*) FROM outbox WHERE bot_name = $1),
+			(SELECT count(*) FROM outbox_dead_letters WHERE bot_name = $1)
+	`
+	if err := db.db.Pool.QueryRow(ctx, q, db.bot).Scan(
+		&s.Users, &s.BlockedUsers, &s.OutboxPending, &s.DeadLetters,
+	); err != nil {
+		return nil, fmt.Errorf("reading stats: %w", err)
+	}
+
+	const chatsQ = `
+		SELECT
+			type, count(*)
+		FROM
+			chats
+		WHERE
+			bot_name = $1 AND type != '' AND member_status NOT IN ('left', 'kicked')
+		GROUP BY
+			type
+	`
+	rows, err := db.db.Pool.Query(ctx, chatsQ, db.bot)
+	if err != nil {
+		return nil, fmt.Errorf("reading chat stats: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			chatType string
+			n        int64
+		)
+		if err := rows.Scan(&chatType, &n); err != nil {
+			return nil, fmt.Errorf("reading chat stats: %w", err)
+		}
+		s.Chats[chatType] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading chat stats: %w", err)
+	}
+
+	return &s, nil
+}
@@ -3,32 +3,54 @@ package database
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/alienvspredator/simple-tgbot/internal/database"
 	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
 	"github.com/jackc/pgx/v4"
 )
 
+// DefaultBot is the name of the bot the rows saved before the bots got names
+// belong to.
+const DefaultBot = "default"
+
+// TgBotDB reads and writes the rows of one bot. The bots of one database
+// never see the rows of each other.
 type TgBotDB struct {
-	db *database.DB
+	db  *database.DB
+	bot string
 }
 
+// New returns the database of DefaultBot.
 func New(db *database.DB) *TgBotDB {
-	return &TgBotDB{db: db}
+	return &TgBotDB{db: db, bot: DefaultBot}
+}
+
+// ForBot returns the database of the named bot sharing the connection pool.
+func (db *TgBotDB) ForBot(name string) *TgBotDB {
+	return &TgBotDB{db: db.db, bot: name}
 }
 
+// Bot returns the name of the bot.
+func (db *TgBotDB) Bot() string {
+	return db.bot
+}
+
+// AddUserMessage saves the received message. The message already saved is
+// left as it is.
 func (db *TgBotDB) AddUserMessage(ctx context.Context, msg *model.Message) error {
 	return db.db.InTx(
 		ctx, pgx.Serializable, func(tx pgx.Tx) error {
 			const q = `
 			INSERT INTO
 				received_messages
-				(telegram_message_id, user_id, chat_id, message_text)
+				(bot_name, telegram_message_id, user_id, chat_id, message_text)
 			VALUES
-				($1, $2, $3, $4)
+				($1, $2, $3, $4, $5)
+			ON CONFLICT (bot_name, chat_id, telegram_message_id) DO NOTHING
 		`
 			if _, err := tx.Exec(
-				ctx, q, msg.TgMessageID, msg.UserID, msg.ChatID, msg.Text,
+				ctx, q, db.bot, msg.TgMessageID, msg.UserID, msg.ChatID, msg.Text,
 			); err != nil {
 				return fmt.Errorf("saving message: %w", err)
 			}
@@ -37,3 +59,49 @@ func (db *TgBotDB) AddUserMessage(ctx context.Context, msg *model.Message) error
 		},
 	)
 }
+
+// SearchUserMessages returns the messages of the user containing the query,
+// the most recent first. The empty query matches all the messages.
+func (db *TgBotDB) SearchUserMessages(
+	ctx context.Context, userID int64, query string, offset, limit int,
+) ([]*model.Message, error) {
+	const q = `
+		SELECT
+			telegram_message_id, user_id, chat_id, message_text
+		FROM
+			received_messages
+		WHERE
+			bot_name = $1 AND user_id = $2 AND message_text ILIKE '%' || $3 || '%'
+		ORDER BY
+			received_at DESC, telegram_message_id DESC
+		OFFSET $4
+		LIMIT $5
+	`
+
+	rows, err := db.db.Pool.Query(ctx, q, db.bot, userID, escapeLike(query), offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("reading messages: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []*model.Message
+	for rows.Next() {
+		var msg model.Message
+		if err := rows.Scan(&msg.TgMessageID, &msg.UserID, &msg.ChatID, &msg.Text); err != nil {
+			return nil, fmt.Errorf("reading message: %w", err)
+		}
+		msgs = append(msgs, &msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading messages: %w", err)
+	}
+
+	return msgs, nil
+}
+
+// escapeLike escapes the LIKE pattern characters in s.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
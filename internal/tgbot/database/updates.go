@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// GetUpdateOffset returns the saved update offset of the bot. It returns
+// database.ErrNotFound if none is saved.
+func (db *TgBotDB) GetUpdateOffset(ctx context.Context, botID int64) (*model.UpdateOffset, error) {
+	const q = `SELECT bot_id, last_update_id, updated_at FROM update_offsets WHERE bot_id = $1`
+
+	var o model.UpdateOffset
+	if err := db.db.Pool.QueryRow(ctx, q, botID).Scan(&o.BotID, &o.LastUpdateID, &o.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("reading update offset: %w", err)
+	}
+
+	return &o, nil
+}
+
+// SetUpdateOffset saves the update offset of the bot.
+func (db *TgBotDB) SetUpdateOffset(ctx context.Context, botID, lastUpdateID int64) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				update_offsets
+				(bot_id, last_update_id, updated_at)
+			VALUES
+				($1, $2, now())
+			ON CONFLICT (bot_id) DO UPDATE SET
+				last_update_id = excluded.last_update_id,
+				updated_at = excluded.updated_at
+		`
+			if _, err := tx.Exec(ctx, q, botID, lastUpdateID); err != nil {
+				return fmt.Errorf("saving update offset: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// ClaimUpdate records the update as processed. It reports false if the
+// update was recorded before, i.e. it is redelivered.
+func (db *TgBotDB) ClaimUpdate(ctx context.Context, botID, updateID int64) (bool, error) {
+	var claimed bool
+
+	err := db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				processed_updates
+				(bot_id, update_id)
+			VALUES
+				($1, $2)
+			ON CONFLICT (bot_id, update_id) DO NOTHING
+		`
+			result, err := tx.Exec(ctx, q, botID, updateID)
+			if err != nil {
+				return fmt.Errorf("saving processed update: %w", err)
+			}
+			claimed = result.RowsAffected() > 0
+
+			return nil
+		},
+	)
+
+	return claimed, err
+}
+
+// DeleteProcessedUpdates deletes the records of the updates processed
+// before the time.
+func (db *TgBotDB) DeleteProcessedUpdates(ctx context.Context, before time.Time) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `DELETE FROM processed_updates WHERE processed_at < $1`
+			if _, err := tx.Exec(ctx, q, before); err != nil {
+				return fmt.Errorf("deleting processed updates: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// userColumns is the number of the columns UpsertUsers inserts per user.
+const userColumns = 8
+
+// UpsertUsers saves the profiles of the users with one statement. The users
+// must be unique. The blocked flag is kept, except for the users in
+// unblocked, who wrote to the bot since and so did not block it.
+func (db *TgBotDB) UpsertUsers(ctx context.Context, users []*model.User, unblocked []int64) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	args := make([]interface{}, 0, 1+len(users)*userColumns)
+	args = append(args, db.bot)
+	for i, u := range users {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("($1")
+		for j := 1; j <= userColumns; j++ {
+			fmt.Fprintf(&sb, ", $%d", 1+i*userColumns+j)
+		}
+		sb.WriteString(")")
+
+		args = append(
+			args, u.ID, u.Username, u.FirstName, u.LastName, u.LanguageCode, u.IsBot,
+			u.FirstSeenAt, u.LastSeenAt,
+		)
+	}
+
+	q := `
+		INSERT INTO
+			users
+			(bot_name, user_id, username, first_name, last_name, language_code, is_bot,
+			 first_seen_at, last_seen_at)
+		VALUES
+			` + sb.String() + `
+		ON CONFLICT (bot_name, user_id) DO UPDATE SET
+			username = excluded.username,
+			first_name = excluded.first_name,
+			last_name = excluded.last_name,
+			language_code = excluded.language_code,
+			is_bot = excluded.is_bot,
+			first_seen_at = least(users.first_seen_at, excluded.first_seen_at),
+			last_seen_at = greatest(users.last_seen_at, excluded.last_seen_at)
+	`
+
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, q, args...); err != nil {
+				return fmt.Errorf("saving users: %w", err)
+			}
+			if len(unblocked) == 0 {
+				return nil
+			}
+
+			const unblock = `
+				UPDATE users SET blocked = false
+				WHERE bot_name = $1 AND user_id = ANY($2) AND blocked
+			`
+			if _, err := tx.Exec(ctx, unblock, db.bot, unblocked); err != nil {
+				return fmt.Errorf("saving users unblocked: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// SetUserBlocked records whether the user blocked the bot.
+func (db *TgBotDB) SetUserBlocked(ctx context.Context, userID int64, blocked bool) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `UPDATE users SET blocked = $3 WHERE bot_name = $1 AND user_id = $2`
+			if _, err := tx.Exec(ctx, q, db.bot, userID, blocked); err != nil {
+				return fmt.Errorf("saving user blocked: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// GetUser returns the profile of the user. It returns database.ErrNotFound if
+// the user was not seen.
+func (db *TgBotDB) GetUser(ctx context.Context, userID int64) (*model.User, error) {
+	const q = `
+		SELECT
+			user_id, username, first_name, last_name, language_code, is_bot, blocked,
+			first_seen_at, last_seen_at
+		FROM
+			users
+		WHERE
+			bot_name = $1 AND user_id = $2
+	`
+
+	var u model.User
+	if err := db.db.Pool.QueryRow(ctx, q, db.bot, userID).Scan(
+		&u.ID, &u.Username, &u.FirstName, &u.LastName, &u.LanguageCode, &u.IsBot, &u.Blocked,
+		&u.FirstSeenAt, &u.LastSeenAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("reading user: %w", err)
+	}
+
+	return &u, nil
+}
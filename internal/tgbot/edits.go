@@ -0,0 +1,43 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"go.uber.org/zap"
+)
+
+// HandleEditedMessage saves the new text of the edited message. The previous
+// text is kept as a revision. Edits of the messages that were not saved,
+// e.g. commands, are ignored.
+func (b *Bot) HandleEditedMessage(ctx context.Context, u *telegram.Update) {
+	m := u.EditedMessage
+	log := logging.FromContext(ctx)
+
+	if b.db == nil {
+		return
+	}
+
+	err := b.db.EditUserMessage(
+		ctx, &model.Message{
+			TgMessageID: int64(m.MessageID),
+			UserID:      int64(m.From.ID),
+			ChatID:      m.Chat.ID,
+			Text:        m.Text,
+			EditedAt:    time.Unix(m.EditDate, 0),
+		},
+	)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		log.Debug("the edited message was not saved")
+	case err != nil:
+		metricsware.NewMiddleware().RecordMessageSaveFailure(ctx)
+		log.Errorw("failed to save edited message", "text", m.Text, zap.Error(err))
+	}
+}
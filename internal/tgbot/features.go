@@ -0,0 +1,124 @@
+package tgbot
+
+import (
+	"context"
+	"strings"
+
+	"github.com/alienvspredator/simple-tgbot/internal/i18n"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// The features the operators can switch off with /feature.
+const (
+	// featureInline answers the inline queries.
+	featureInline = "inline"
+	// featureMedia downloads the received files to the blob store.
+	featureMedia = "media"
+)
+
+// features lists the switchable features. All of them are on by default.
+var features = []string{featureInline, featureMedia}
+
+func isFeature(name string) bool {
+	for _, f := range features {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// featureEnabled reports whether the feature is on. Without the database all
+// the features are on.
+func (b *Bot) featureEnabled(ctx context.Context, name string) bool {
+	if b.db == nil {
+		return true
+	}
+
+	v, err := b.featureCache.WriteThruLookup(
+		name, func() (interface{}, error) {
+			switched, err := b.db.ListFeatures(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range switched {
+				if f.Name == name {
+					return f.Enabled, nil
+				}
+			}
+			return true, nil
+		},
+	)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("get feature", "feature", name, zap.Error(err))
+		return true
+	}
+
+	return v.(bool)
+}
+
+// Feature shows the state of the features or switches one on or off.
+func (b *Bot) Feature(ctx context.Context, m *tbot.Message, args Args) {
+	name, mode := strings.ToLower(args.Get("feature")), strings.ToLower(args.Get("mode"))
+
+	if name == "" {
+		lines := make([]string, 0, len(features))
+		for _, f := range features {
+			lines = append(lines, b.featureText(ctx, f, b.featureEnabled(ctx, f)))
+		}
+		b.reply(ctx, m, strings.Join(lines, "\n"))
+		return
+	}
+	if !isFeature(name) {
+		b.reply(ctx, m, b.t(ctx, "feature.unknown", i18n.Params{"Features": strings.Join(features, ", ")}))
+		return
+	}
+	if mode == "" {
+		b.reply(ctx, m, b.featureText(ctx, name, b.featureEnabled(ctx, name)))
+		return
+	}
+	if mode != "on" && mode != "off" {
+		cmd, _ := b.commands.lookup("feature")
+		b.reply(ctx, m, b.t(ctx, "command.usage", i18n.Params{"Usage": cmd.Usage()}))
+		return
+	}
+
+	if err := b.setFeature(ctx, name, mode == "on", m.From); err != nil {
+		logging.FromContext(ctx).Errorw("set feature", "feature", name, zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	logging.FromContext(ctx).Infow("feature switched", "feature", name, "enabled", mode == "on")
+
+	b.reply(ctx, m, b.featureText(ctx, name, mode == "on"))
+}
+
+func (b *Bot) setFeature(ctx context.Context, name string, enabled bool, by *tbot.User) error {
+	if b.db == nil {
+		return errNoDatabase
+	}
+
+	f := &model.Feature{Name: name, Enabled: enabled}
+	if by != nil {
+		f.UpdatedBy = int64(by.ID)
+	}
+	if err := b.db.SetFeature(ctx, f); err != nil {
+		return err
+	}
+	if err := b.featureCache.Set(name, enabled); err != nil {
+		logging.FromContext(ctx).Errorw("cache feature", zap.Error(err))
+	}
+
+	return nil
+}
+
+func (b *Bot) featureText(ctx context.Context, name string, enabled bool) string {
+	state := b.t(ctx, "feature.off", nil)
+	if enabled {
+		state = b.t(ctx, "feature.on", nil)
+	}
+	return b.t(ctx, "feature.state", i18n.Params{"Feature": name, "State": state})
+}
@@ -0,0 +1,399 @@
+package tgbot
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/i18n"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"go.uber.org/zap"
+)
+
+// The anti-flood actions applied to the updates over a limit.
+const (
+	// floodDrop drops the updates silently.
+	floodDrop = "drop"
+	// floodWarn drops the updates and warns once.
+	floodWarn = "warn"
+	// floodMute ignores the sender or the chat for a while.
+	floodMute = "mute"
+)
+
+// The anti-flood limits an update can exceed.
+const (
+	limitRate  = "rate"
+	limitQuota = "quota"
+	limitMuted = "muted"
+)
+
+// floodSweepInterval is how often the idle counters are removed from memory.
+const floodSweepInterval = time.Minute
+
+// floodDayLayout formats the UTC days of the daily quotas.
+const floodDayLayout = "2006-01-02"
+
+// floodLimit limits the updates of one user or one chat. The zero values
+// disable the limits.
+type floodLimit struct {
+	// rate updates are allowed per sliding window.
+	rate   int
+	window time.Duration
+	// daily updates are allowed per UTC day.
+	daily int
+}
+
+// floodKey is a counted user or chat with its limit.
+type floodKey struct {
+	key   string
+	limit floodLimit
+}
+
+// floodCounter is the state of one user or chat.
+type floodCounter struct {
+	// hits are the times of the allowed updates within the window, the
+	// oldest first.
+	hits       []time.Time
+	day        string
+	daily      int64
+	mutedUntil time.Time
+	// warned is set once the warning is sent and cleared by the next allowed
+	// update.
+	warned bool
+	// dirty is set when the daily count or the mute is not persisted yet.
+	dirty bool
+}
+
+// floodGuard tracks the update rates and the daily counts in memory. With
+// the persistence the daily counts and the mutes survive the restarts and are
+// shared by the replicas on the flush.
+type floodGuard struct {
+	action    string
+	muteFor   time.Duration
+	userLimit floodLimit
+	chatLimit floodLimit
+	maxWindow time.Duration
+	interval  time.Duration
+	save      func(ctx context.Context, counters []*model.FloodCounter) error
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	mu        sync.Mutex
+	counters  map[string]*floodCounter
+	lastSweep time.Time
+}
+
+func newFloodGuard(config *Config) *floodGuard {
+	g := &floodGuard{
+		action:  config.FloodAction,
+		muteFor: config.FloodMuteDuration,
+		userLimit: floodLimit{
+			rate:   config.FloodUserRate,
+			window: config.FloodUserWindow,
+			daily:  config.FloodUserDaily,
+		},
+		chatLimit: floodLimit{
+			rate:   config.FloodChatRate,
+			window: config.FloodChatWindow,
+			daily:  config.FloodChatDaily,
+		},
+		interval: config.FloodFlushInterval,
+		closing:  make(chan struct{}),
+		counters: make(map[string]*floodCounter),
+	}
+	g.maxWindow = g.userLimit.window
+	if g.chatLimit.window > g.maxWindow {
+		g.maxWindow = g.chatLimit.window
+	}
+	return g
+}
+
+// enabled reports whether any limit is set.
+func (l floodLimit) enabled() bool {
+	return (l.rate > 0 && l.window > 0) || l.daily > 0
+}
+
+// exceeded returns the limit the counter reached, if any.
+func (l floodLimit) exceeded(c *floodCounter) string {
+	switch {
+	case l.rate > 0 && l.window > 0 && len(c.hits) >= l.rate:
+		return limitRate
+	case l.daily > 0 && c.daily >= int64(l.daily):
+		return limitQuota
+	default:
+		return ""
+	}
+}
+
+// check counts the update of the keys at now. It returns an empty action if
+// the update is allowed, otherwise the action to apply and the exceeded
+// limit. The denied updates are not counted.
+func (g *floodGuard) check(now time.Time, keys ...floodKey) (action, limit string) {
+	return g.count(now, true, keys...)
+}
+
+// checkUnanswered is check for the updates the bot does not answer. The
+// warning is kept for the next answered update, as nobody would see it.
+func (g *floodGuard) checkUnanswered(now time.Time, keys ...floodKey) (action, limit string) {
+	return g.count(now, false, keys...)
+}
+
+func (g *floodGuard) count(now time.Time, warn bool, keys ...floodKey) (action, limit string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now.Sub(g.lastSweep) >= floodSweepInterval {
+		g.sweep(now)
+	}
+
+	day := now.UTC().Format(floodDayLayout)
+	counters := make([]*floodCounter, len(keys))
+	for i, k := range keys {
+		c := g.counter(k.key, day)
+		c.prune(now, k.limit.window)
+		if now.Before(c.mutedUntil) {
+			return floodDrop, limitMuted
+		}
+		counters[i] = c
+	}
+
+	for i, k := range keys {
+		if limit := k.limit.exceeded(counters[i]); limit != "" {
+			return g.apply(counters[i], now, warn), limit
+		}
+	}
+
+	for i, k := range keys {
+		c := counters[i]
+		if k.limit.rate > 0 && k.limit.window > 0 {
+			c.hits = append(c.hits, now)
+		}
+		if k.limit.daily > 0 {
+			c.daily++
+			c.dirty = true
+		}
+		c.warned = false
+	}
+	return "", ""
+}
+
+// apply returns the action for the counter over the limit and records it.
+// Without warn the warning is not given and not recorded.
+func (g *floodGuard) apply(c *floodCounter, now time.Time, warn bool) string {
+	switch g.action {
+	case floodMute:
+		c.mutedUntil = now.Add(g.muteFor)
+		c.hits = nil
+		c.dirty = true
+		return floodMute
+	case floodWarn:
+		if c.warned || !warn {
+			return floodDrop
+		}
+		c.warned = true
+		return floodWarn
+	default:
+		return floodDrop
+	}
+}
+
+// counter returns the counter of the key, resetting the daily count on a new
+// day. It must be called with the lock held.
+func (g *floodGuard) counter(key, day string) *floodCounter {
+	c, ok := g.counters[key]
+	if !ok {
+		c = &floodCounter{day: day}
+		g.counters[key] = c
+	}
+	if c.day != day {
+		c.day, c.daily, c.warned = day, 0, false
+	}
+	return c
+}
+
+// prune drops the hits that left the window.
+func (c *floodCounter) prune(now time.Time, window time.Duration) {
+	cutoff := now.Add(-window)
+	i := 0
+	for i < len(c.hits) && !c.hits[i].After(cutoff) {
+		i++
+	}
+	c.hits = c.hits[i:]
+}
+
+// sweep removes the counters with nothing to remember: no recent hits, no
+// daily count of today, no mute and nothing to persist. It must be called
+// with the lock held.
+func (g *floodGuard) sweep(now time.Time) {
+	g.lastSweep = now
+	day := now.UTC().Format(floodDayLayout)
+	for key, c := range g.counters {
+		c.prune(now, g.maxWindow)
+		if len(c.hits) == 0 && (c.day != day || c.daily == 0) && !now.Before(c.mutedUntil) && !c.dirty {
+			delete(g.counters, key)
+		}
+	}
+}
+
+// load merges the persisted counters into the memory ones.
+func (g *floodGuard) load(counters []*model.FloodCounter) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, pc := range counters {
+		day := pc.Day.UTC().Format(floodDayLayout)
+		c, ok := g.counters[pc.Key]
+		if !ok {
+			c = &floodCounter{day: day}
+			g.counters[pc.Key] = c
+		}
+		if c.day == day && pc.DailyCount > c.daily {
+			c.daily = pc.DailyCount
+		}
+		if pc.MutedUntil.After(c.mutedUntil) {
+			c.mutedUntil = pc.MutedUntil
+		}
+	}
+}
+
+// run saves the changed counters every interval. After close it saves the
+// rest and returns.
+func (g *floodGuard) run(ctx context.Context) {
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.closing:
+			g.flush(ctx)
+			return
+		case <-ticker.C:
+			g.flush(ctx)
+		}
+	}
+}
+
+// close makes run save the changed counters and return.
+func (g *floodGuard) close() {
+	g.closeOnce.Do(func() { close(g.closing) })
+}
+
+// flush saves the changed counters. The counters that failed to save are
+// saved on the next flush.
+func (g *floodGuard) flush(ctx context.Context) {
+	g.mu.Lock()
+	var (
+		counters []*model.FloodCounter
+		flushed  []*floodCounter
+	)
+	for key, c := range g.counters {
+		if !c.dirty {
+			continue
+		}
+		day, _ := time.Parse(floodDayLayout, c.day)
+		counters = append(counters, &model.FloodCounter{
+			Key:        key,
+			Day:        day,
+			DailyCount: c.daily,
+			MutedUntil: c.mutedUntil,
+		})
+		flushed = append(flushed, c)
+		c.dirty = false
+	}
+	g.mu.Unlock()
+
+	if len(counters) == 0 {
+		return
+	}
+	if err := g.save(ctx, counters); err != nil {
+		logging.FromContext(ctx).Errorw("save flood counters", "counters", len(counters), zap.Error(err))
+
+		g.mu.Lock()
+		for _, c := range flushed {
+			c.dirty = true
+		}
+		g.mu.Unlock()
+	}
+}
+
+// floodKeys returns the counted keys of the update: the sender and the group
+// it came from. The updates other than the messages, the callbacks and the
+// inline queries are not counted.
+func (g *floodGuard) floodKeys(u *telegram.Update) []floodKey {
+	if u.Message == nil && u.EditedMessage == nil && u.CallbackQuery == nil && u.InlineQuery == nil {
+		return nil
+	}
+	from := updateSender(u)
+	if from == nil {
+		return nil
+	}
+
+	var keys []floodKey
+	userID := strconv.Itoa(from.ID)
+	if g.userLimit.enabled() {
+		keys = append(keys, floodKey{key: "user:" + userID, limit: g.userLimit})
+	}
+	if m := updateMessage(u); m != nil && m.Chat.ID != userID && g.chatLimit.enabled() {
+		keys = append(keys, floodKey{key: "chat:" + m.Chat.ID, limit: g.chatLimit})
+	}
+	return keys
+}
+
+// Throttle drops the updates over the anti-flood limits and applies the
+// configured action. The operators are not limited.
+func (b *Bot) Throttle() Middleware {
+	return b.throttle(true)
+}
+
+// CountFlood counts the updates the bot does not answer, e.g. in the silent
+// chats, against the anti-flood limits. The updates over a limit are still
+// handled, so they are saved, and nothing is replied.
+func (b *Bot) CountFlood() Middleware {
+	return b.throttle(false)
+}
+
+func (b *Bot) throttle(answered bool) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *telegram.Update) {
+			keys := b.flood.floodKeys(u)
+			if len(keys) == 0 || b.userRole(ctx, updateSender(u)) > RoleNone {
+				next(ctx, u)
+				return
+			}
+
+			check := b.flood.check
+			if !answered {
+				check = b.flood.checkUnanswered
+			}
+			action, limit := check(time.Now(), keys...)
+			if action == "" {
+				next(ctx, u)
+				return
+			}
+
+			metricsware.NewMiddleware().RecordFloodLimited(ctx, action, limit)
+			logging.FromContext(ctx).Warnw("flood limit exceeded", "action", action, "limit", limit)
+			if !answered {
+				next(ctx, u)
+				return
+			}
+
+			m := u.Message
+			if m == nil {
+				return
+			}
+			switch {
+			case action == floodMute:
+				b.reply(ctx, m, b.t(ctx, "flood.muted", i18n.Params{"Duration": b.config.FloodMuteDuration.String()}))
+			case action == floodWarn && limit == limitQuota:
+				b.reply(ctx, m, b.t(ctx, "flood.quota", nil))
+			case action == floodWarn:
+				b.reply(ctx, m, b.t(ctx, "flood.warning", nil))
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package tgbot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/serverenv"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram/telegramtest"
+	"github.com/yanzay/tbot/v2"
+)
+
+func TestFloodGuard_Check(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 10, 18, 23, 59, 0, 0, time.UTC)
+	rate := floodLimit{rate: 2, window: time.Minute}
+	quota := floodLimit{daily: 2}
+
+	type step struct {
+		after      time.Duration
+		key        floodKey
+		wantAction string
+		wantLimit  string
+	}
+
+	tests := []struct {
+		name   string
+		action string
+		steps  []step
+	}{
+		{
+			name:   "warn once",
+			action: floodWarn,
+			steps: []step{
+				{key: floodKey{"user:1", rate}},
+				{key: floodKey{"user:1", rate}},
+				{key: floodKey{"user:1", rate}, wantAction: floodWarn, wantLimit: limitRate},
+				{key: floodKey{"user:1", rate}, wantAction: floodDrop, wantLimit: limitRate},
+				{key: floodKey{"user:2", rate}},
+				{after: time.Minute, key: floodKey{"user:1", rate}},
+				{after: time.Minute, key: floodKey{"user:1", rate}},
+				{after: time.Minute, key: floodKey{"user:1", rate}, wantAction: floodWarn, wantLimit: limitRate},
+			},
+		},
+		{
+			name:   "drop",
+			action: floodDrop,
+			steps: []step{
+				{key: floodKey{"user:1", rate}},
+				{key: floodKey{"user:1", rate}},
+				{key: floodKey{"user:1", rate}, wantAction: floodDrop, wantLimit: limitRate},
+			},
+		},
+		{
+			name:   "mute",
+			action: floodMute,
+			steps: []step{
+				{key: floodKey{"user:1", rate}},
+				{key: floodKey{"user:1", rate}},
+				{key: floodKey{"user:1", rate}, wantAction: floodMute, wantLimit: limitRate},
+				{after: 2 * time.Minute, key: floodKey{"user:1", rate}, wantAction: floodDrop, wantLimit: limitMuted},
+				{after: 11 * time.Minute, key: floodKey{"user:1", rate}},
+			},
+		},
+		{
+			name:   "daily quota",
+			action: floodWarn,
+			steps: []step{
+				{key: floodKey{"chat:-1", quota}},
+				{key: floodKey{"chat:-1", quota}},
+				{key: floodKey{"chat:-1", quota}, wantAction: floodWarn, wantLimit: limitQuota},
+				// The next UTC day.
+				{after: time.Minute, key: floodKey{"chat:-1", quota}},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			g := newFloodGuard(&Config{FloodAction: tc.action, FloodMuteDuration: 10 * time.Minute})
+			for i, s := range tc.steps {
+				action, limit := g.check(start.Add(s.after), s.key)
+				if action != s.wantAction || limit != s.wantLimit {
+					t.Errorf(
+						"step %d does not match, got = %q %q, want = %q %q",
+						i, action, limit, s.wantAction, s.wantLimit,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestFloodGuard_CheckChat(t *testing.T) {
+	t.Parallel()
+
+	g := newFloodGuard(&Config{FloodAction: floodDrop})
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	user := floodLimit{rate: 2, window: time.Minute}
+	chat := floodLimit{rate: 3, window: time.Minute}
+
+	for i, userKey := range []string{"user:1", "user:2", "user:3"} {
+		if action, _ := g.check(now, floodKey{userKey, user}, floodKey{"chat:-1", chat}); action != "" {
+			t.Fatalf("update %d is limited, got = %q", i, action)
+		}
+	}
+
+	// The chat is over its limit, so the user is not counted.
+	if action, limit := g.check(now, floodKey{"user:4", user}, floodKey{"chat:-1", chat}); limit != limitRate {
+		t.Errorf("limit does not match, got = %q %q, want = %q", action, limit, limitRate)
+	}
+	if got := len(g.counters["user:4"].hits); got != 0 {
+		t.Errorf("hits of the denied user do not match, got = %d, want = 0", got)
+	}
+}
+
+func TestBot_CountFlood(t *testing.T) {
+	t.Parallel()
+
+	srv := telegramtest.NewServer()
+	defer srv.Close()
+
+	b, err := New(serverenv.New(context.Background()), &Config{
+		TelegramToken:   telegramtest.Token,
+		TelegramAPIURL:  srv.URL(),
+		DefaultLocale:   "ru",
+		FloodUserRate:   2,
+		FloodUserWindow: time.Hour,
+		FloodAction:     floodWarn,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The messages of a silent chat go to the listen handler, which saves
+	// them all even over the limit.
+	var listened int
+	h := Chain(func(ctx context.Context, u *telegram.Update) { listened++ }, b.listenMiddlewares()...)
+	for i := 0; i < 4; i++ {
+		u := chatUpdate(i, "-100")
+		u.Message.Chat.Type = "supergroup"
+		u.Message.From = &tbot.User{ID: 7}
+		h(context.Background(), u)
+	}
+
+	if listened != 4 {
+		t.Errorf("number of listened messages does not match, got = %d, want = 4", listened)
+	}
+	if sent := srv.SentMessages(); len(sent) != 0 {
+		t.Errorf("replies were sent in the silent chat, got = %+v", sent)
+	}
+
+	// The warning is kept for the first answered message.
+	u := chatUpdate(4, "-100")
+	u.Message.From = &tbot.User{ID: 7}
+	if action, _ := b.flood.check(time.Now(), b.flood.floodKeys(u)...); action != floodWarn {
+		t.Errorf("action does not match, got = %q, want = %q", action, floodWarn)
+	}
+}
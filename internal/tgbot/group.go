@@ -0,0 +1,257 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/i18n"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// isGroup reports whether the chat is a group or a supergroup.
+func isGroup(chat tbot.Chat) bool {
+	return chat.Type == "group" || chat.Type == "supergroup"
+}
+
+// isCommand reports whether the message is a bot command.
+func isCommand(m *tbot.Message) bool {
+	return strings.HasPrefix(m.Text, "/")
+}
+
+// isChatEvent reports whether the message is a service message about the
+// chat members or the upgrade of the group to a supergroup.
+func isChatEvent(m *tbot.Message) bool {
+	return len(m.NewChatMembers) > 0 || m.LeftChatMember != nil ||
+		m.MigrateToChatID != 0 || m.MigrateFromChatID != 0
+}
+
+// shouldReply reports whether the bot answers the message. The bot answers
+// all the messages in the private chats and only the ones addressed to it
+// in the groups. Nothing is answered in the silent chats.
+func (b *Bot) shouldReply(ctx context.Context, m *tbot.Message) bool {
+	if b.chatSettings(ctx, m.Chat.ID).Silent {
+		return false
+	}
+	return !isGroup(m.Chat) || b.addressed(m)
+}
+
+// addressed reports whether the message mentions the bot or replies to a
+// message of the bot.
+func (b *Bot) addressed(m *tbot.Message) bool {
+	if r := m.ReplyToMessage; r != nil && r.From != nil && r.From.ID == b.botID {
+		return true
+	}
+
+	text, entities := m.Text, m.Entities
+	if text == "" {
+		text, entities = m.Caption, m.CaptionEntities
+	}
+	for _, e := range entities {
+		switch e.Type {
+		case "mention":
+			if strings.EqualFold(entityText(text, e), "@"+b.username) {
+				return true
+			}
+		case "text_mention":
+			if e.User != nil && e.User.ID == b.botID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// entityText returns the part of the text the entity covers. The entity
+// offsets are in UTF-16 code units.
+func entityText(text string, e *tbot.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	if e.Offset < 0 || e.Length < 0 || e.Offset+e.Length > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[e.Offset : e.Offset+e.Length]))
+}
+
+// Listen saves the message without answering it.
+func (b *Bot) Listen(ctx context.Context, u *telegram.Update) {
+	m := u.Message
+
+	if media := messageMedia(m); media != nil {
+		b.saveMedia(ctx, media)
+		b.keepMedia(ctx, media)
+		return
+	}
+	if m.Text != "" {
+		b.saveMessage(ctx, m)
+	}
+}
+
+// chatSettings returns the configuration of the chat. The chats without
+// one and all the chats without the database have the defaults.
+func (b *Bot) chatSettings(ctx context.Context, chatID string) *model.ChatSettings {
+	defaults := &model.ChatSettings{ChatID: chatID}
+	if b.db == nil {
+		return defaults
+	}
+
+	v, err := b.chatSettingsCache.WriteThruLookup(
+		chatID, func() (interface{}, error) {
+			s, err := b.db.GetChatSettings(ctx, chatID)
+			if errors.Is(err, database.ErrNotFound) {
+				return defaults, nil
+			}
+			return s, err
+		},
+	)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("get chat settings", zap.Error(err))
+		return defaults
+	}
+
+	return v.(*model.ChatSettings)
+}
+
+// setChatSettings saves the configuration of the chat.
+func (b *Bot) setChatSettings(ctx context.Context, s *model.ChatSettings) error {
+	if b.db == nil {
+		return errNoDatabase
+	}
+
+	if err := b.db.SetChatSettings(ctx, s); err != nil {
+		return err
+	}
+	if err := b.chatSettingsCache.Set(s.ChatID, s); err != nil {
+		logging.FromContext(ctx).Errorw("cache chat settings", zap.Error(err))
+	}
+
+	return nil
+}
+
+// HandleChatEvent handles the service messages: greets the new members and
+// moves the chat data when the group is upgraded to a supergroup.
+func (b *Bot) HandleChatEvent(ctx context.Context, u *telegram.Update) {
+	m := u.Message
+	log := logging.FromContext(ctx)
+	metricsMW := metricsware.NewMiddleware()
+
+	switch {
+	case m.MigrateToChatID != 0:
+		b.migrateChat(ctx, m.Chat.ID, strconv.Itoa(m.MigrateToChatID))
+	case m.MigrateFromChatID != 0:
+		b.migrateChat(ctx, strconv.Itoa(m.MigrateFromChatID), m.Chat.ID)
+	case m.LeftChatMember != nil:
+		log.Infow("chat member left", "user_id", m.LeftChatMember.ID)
+		if m.LeftChatMember.ID != b.botID {
+			metricsMW.RecordChatMembersLeft(ctx, 1)
+		}
+	case len(m.NewChatMembers) > 0:
+		var (
+			names []string
+			added bool
+		)
+		for _, user := range m.NewChatMembers {
+			log.Infow("chat member joined", "user_id", user.ID)
+			switch {
+			case user.ID == b.botID:
+				added = true
+			case !user.IsBot:
+				names = append(names, user.FirstName)
+			}
+		}
+		metricsMW.RecordChatMembersJoined(ctx, int64(len(names)))
+
+		if b.chatSettings(ctx, m.Chat.ID).Silent {
+			return
+		}
+		if added {
+			b.reply(ctx, m, b.t(ctx, "group.hello", nil))
+		}
+		if len(names) > 0 {
+			b.reply(ctx, m, b.t(ctx, "group.welcome", i18n.Params{"Names": strings.Join(names, ", ")}))
+		}
+	}
+}
+
+// migrateChat moves the chat data to the new chat ID. Both the group and the
+// supergroup get a service message about the upgrade, so it runs twice.
+func (b *Bot) migrateChat(ctx context.Context, fromChatID, toChatID string) {
+	log := logging.FromContext(ctx).With("from_chat_id", fromChatID, "to_chat_id", toChatID)
+
+	if b.db == nil {
+		return
+	}
+	if err := b.db.MigrateChat(ctx, fromChatID, toChatID); err != nil {
+		log.Errorw("failed to migrate chat", zap.Error(err))
+		return
+	}
+	log.Info("chat migrated to supergroup")
+}
+
+// Silent shows or changes the listen silently mode of the chat. In the groups
+// only the administrators can change it.
+func (b *Bot) Silent(ctx context.Context, m *tbot.Message, args Args) {
+	log := logging.FromContext(ctx)
+
+	mode := strings.ToLower(args.Get("mode"))
+	if mode == "" {
+		b.reply(ctx, m, b.silentText(ctx, b.chatSettings(ctx, m.Chat.ID).Silent))
+		return
+	}
+	if mode != "on" && mode != "off" {
+		cmd, _ := b.commands.lookup("silent")
+		metricsware.NewMiddleware().RecordFailedReply(ctx)
+		b.reply(ctx, m, b.t(ctx, "command.usage", i18n.Params{"Usage": cmd.Usage()}))
+		return
+	}
+
+	if isGroup(m.Chat) {
+		admin, err := b.isChatAdmin(ctx, m.Chat.ID, m.From)
+		if err != nil {
+			log.Errorw("get chat member", zap.Error(err))
+			b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+			return
+		}
+		if !admin {
+			b.reply(ctx, m, b.t(ctx, "silent.forbidden", nil))
+			return
+		}
+	}
+
+	silent := mode == "on"
+	if err := b.setChatSettings(ctx, &model.ChatSettings{ChatID: m.Chat.ID, Silent: silent}); err != nil {
+		log.Errorw("set chat settings", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+
+	b.reply(ctx, m, b.silentText(ctx, silent))
+}
+
+func (b *Bot) silentText(ctx context.Context, silent bool) string {
+	if silent {
+		return b.t(ctx, "silent.on", nil)
+	}
+	return b.t(ctx, "silent.off", nil)
+}
+
+// isChatAdmin reports whether the user is the creator or an administrator of
+// the chat.
+func (b *Bot) isChatAdmin(ctx context.Context, chatID string, user *tbot.User) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+
+	member, err := b.api.GetChatMember(ctx, chatID, user.ID)
+	if err != nil {
+		return false, err
+	}
+	return member.Status == "creator" || member.Status == "administrator", nil
+}
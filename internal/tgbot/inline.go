@@ -0,0 +1,217 @@
+package tgbot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// maxInlineResults is the Telegram limit of the results in one answer.
+const maxInlineResults = 50
+
+// InlineProvider returns the results for the inline queries, i.e. the
+// "@bot query" typed by the users in any chat. The inline mode must be
+// enabled for the bot with @BotFather.
+type InlineProvider interface {
+	// Name identifies the provider in the logs.
+	Name() string
+	// Results returns up to limit results of the query starting at offset,
+	// and the offset of the next page. The first page has the empty offset;
+	// the empty next offset means there are no more results.
+	Results(ctx context.Context, q *tbot.InlineQuery, offset string, limit int) ([]tbot.InlineQueryResult, string, error)
+}
+
+// AddInlineProvider adds the provider of the inline query results. The
+// results of the providers are shown in the order the providers are added.
+// It must be called before Serve.
+func (b *Bot) AddInlineProvider(p InlineProvider) {
+	b.inlineProviders = append(b.inlineProviders, p)
+}
+
+// inlineOffset is the position in the results of all the providers: the
+// index of the provider and the offset in its results.
+type inlineOffset struct {
+	provider int
+	offset   string
+}
+
+func (o inlineOffset) String() string {
+	return strconv.Itoa(o.provider) + ":" + o.offset
+}
+
+func parseInlineOffset(s string) (inlineOffset, error) {
+	if s == "" {
+		return inlineOffset{}, nil
+	}
+
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return inlineOffset{}, fmt.Errorf("invalid inline offset %q", s)
+	}
+	provider, err := strconv.Atoi(s[:i])
+	if err != nil || provider < 0 {
+		return inlineOffset{}, fmt.Errorf("invalid inline offset %q", s)
+	}
+	return inlineOffset{provider: provider, offset: s[i+1:]}, nil
+}
+
+// HandleInlineQuery answers the inline query with a page of the provider
+// results. The answers are cached per user, query and offset.
+func (b *Bot) HandleInlineQuery(ctx context.Context, u *telegram.Update) {
+	q := u.InlineQuery
+	log := logging.FromContext(ctx)
+	metricsMW := metricsware.NewMiddleware()
+
+	start := time.Now()
+
+	if !b.featureEnabled(ctx, featureInline) {
+		if err := b.api.AnswerInlineQuery(ctx, q.ID, &telegram.InlineAnswer{Personal: true}); err != nil {
+			metricsMW.RecordSendFailure(ctx)
+			log.Errorw("answer inline query", zap.Error(err))
+		}
+		return
+	}
+
+	key := strings.Join([]string{strconv.Itoa(q.From.ID), q.Offset, q.Query}, "\x00")
+	v, err := b.inlineCache.WriteThruLookup(key, func() (interface{}, error) {
+		return b.inlineResults(ctx, q)
+	})
+	if err != nil {
+		metricsMW.RecordFailedReply(ctx)
+		log.Errorw("get inline query results", zap.Error(err))
+		v = &telegram.InlineAnswer{}
+	}
+
+	answer := *v.(*telegram.InlineAnswer)
+	answer.CacheTime = b.config.InlineCacheTTL
+	answer.Personal = true
+
+	if err := b.api.AnswerInlineQuery(ctx, q.ID, &answer); err != nil {
+		metricsMW.RecordSendFailure(ctx)
+		log.Errorw("answer inline query", zap.Error(err))
+	}
+	metricsMW.RecordInlineQueryLatency(ctx, time.Since(start))
+}
+
+// inlineResults collects a page of the results starting at the query
+// offset. A page holds the results of one provider; the providers without
+// results are skipped.
+func (b *Bot) inlineResults(ctx context.Context, q *tbot.InlineQuery) (*telegram.InlineAnswer, error) {
+	pos, err := parseInlineOffset(q.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := b.config.InlineResultsLimit
+	if limit < 1 || limit > maxInlineResults {
+		limit = maxInlineResults
+	}
+
+	for ; pos.provider < len(b.inlineProviders); pos = (inlineOffset{provider: pos.provider + 1}) {
+		p := b.inlineProviders[pos.provider]
+
+		results, next, err := p.Results(ctx, q, pos.offset, limit)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", p.Name(), err)
+		}
+		if len(results) > limit {
+			results = results[:limit]
+		}
+
+		var nextOffset string
+		switch {
+		case next != "":
+			nextOffset = inlineOffset{provider: pos.provider, offset: next}.String()
+		case pos.provider+1 < len(b.inlineProviders):
+			nextOffset = inlineOffset{provider: pos.provider + 1}.String()
+		}
+
+		if len(results) > 0 || next != "" {
+			return &telegram.InlineAnswer{Results: results, NextOffset: nextOffset}, nil
+		}
+	}
+
+	return &telegram.InlineAnswer{}, nil
+}
+
+// historyTitleLen is the length of the message text shown as the title of
+// the history result.
+const historyTitleLen = 64
+
+// inlineModule answers the inline queries with the message history. It
+// needs the database.
+type inlineModule struct{}
+
+func (inlineModule) Name() string {
+	return moduleInline
+}
+
+func (inlineModule) Attach(b *Bot, _ interface{}) error {
+	if b.db != nil {
+		b.AddInlineProvider(&historyProvider{db: b.db})
+	}
+	return nil
+}
+
+// historyProvider finds the messages the user sent to the bot.
+type historyProvider struct {
+	db *database.TgBotDB
+}
+
+func (p *historyProvider) Name() string {
+	return "history"
+}
+
+// Results returns the user messages containing the query, the most recent
+// first. The offset is the number of the messages on the previous pages.
+func (p *historyProvider) Results(
+	ctx context.Context, q *tbot.InlineQuery, offset string, limit int,
+) ([]tbot.InlineQueryResult, string, error) {
+	skip := 0
+	if offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return nil, "", fmt.Errorf("invalid offset %q", offset)
+		}
+		skip = n
+	}
+
+	msgs, err := p.db.SearchUserMessages(ctx, int64(q.From.ID), strings.TrimSpace(q.Query), skip, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	results := make([]tbot.InlineQueryResult, 0, len(msgs))
+	for _, m := range msgs {
+		results = append(results, tbot.InlineQueryResultArticle{
+			Type:                "article",
+			ID:                  m.ChatID + ":" + strconv.FormatInt(m.TgMessageID, 10),
+			Title:               truncate(m.Text, historyTitleLen),
+			InputMessageContent: tbot.InputTextMessageContent{MessageText: m.Text},
+		})
+	}
+
+	var next string
+	if len(msgs) == limit {
+		next = strconv.Itoa(skip + limit)
+	}
+	return results, next, nil
+}
+
+// truncate shortens s to n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,135 @@
+package tgbot_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot"
+	"github.com/yanzay/tbot/v2"
+)
+
+// staticProvider returns the articles with the given titles. The offset is
+// the index of the first result.
+type staticProvider struct {
+	name   string
+	titles []string
+	calls  int32
+}
+
+func (p *staticProvider) Name() string {
+	return p.name
+}
+
+func (p *staticProvider) Results(
+	_ context.Context, _ *tbot.InlineQuery, offset string, limit int,
+) ([]tbot.InlineQueryResult, string, error) {
+	atomic.AddInt32(&p.calls, 1)
+
+	start := 0
+	if offset != "" {
+		var err error
+		if start, err = strconv.Atoi(offset); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var results []tbot.InlineQueryResult
+	end := start
+	for ; end < len(p.titles) && end < start+limit; end++ {
+		results = append(results, tbot.InlineQueryResultArticle{
+			Type:                "article",
+			ID:                  p.name + strconv.Itoa(end),
+			Title:               p.titles[end],
+			InputMessageContent: tbot.InputTextMessageContent{MessageText: p.titles[end]},
+		})
+	}
+
+	var next string
+	if end < len(p.titles) {
+		next = strconv.Itoa(end)
+	}
+	return results, next, nil
+}
+
+func TestBot_InlineQuery(t *testing.T) {
+	t.Parallel()
+
+	first := &staticProvider{name: "first", titles: []string{"a", "b", "c"}}
+	empty := &staticProvider{name: "empty"}
+	last := &staticProvider{name: "last", titles: []string{"d"}}
+
+	srv := serveBotWith(
+		t,
+		func(config *tgbot.Config) {
+			config.InlineResultsLimit = 2
+			config.InlineCacheTTL = time.Minute
+		},
+		func(bot *tgbot.Bot) {
+			bot.AddInlineProvider(first)
+			bot.AddInlineProvider(empty)
+			bot.AddInlineProvider(last)
+		},
+	)
+
+	pages := []struct {
+		offset     string
+		wantTitles []string
+		wantNext   string
+	}{
+		{offset: "", wantTitles: []string{"a", "b"}, wantNext: "0:2"},
+		{offset: "0:2", wantTitles: []string{"c"}, wantNext: "1:"},
+		{offset: "1:", wantTitles: []string{"d"}, wantNext: ""},
+		// The repeated query is answered from the cache.
+		{offset: "", wantTitles: []string{"a", "b"}, wantNext: "0:2"},
+		{offset: "bad", wantTitles: nil, wantNext: ""},
+	}
+
+	for i, page := range pages {
+		id := srv.AddInlineQuery(7, "query", page.offset)
+
+		calls, err := srv.WaitForCalls("answerInlineQuery", i+1, 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := calls[i].Params
+
+		if got := params.Get("inline_query_id"); got != id {
+			t.Errorf("inline query ID does not match, got = %s, want = %s", got, id)
+		}
+		if got := params.Get("next_offset"); got != page.wantNext {
+			t.Errorf("offset %q: next offset does not match, got = %q, want = %q", page.offset, got, page.wantNext)
+		}
+		if got := params.Get("is_personal"); got != "true" {
+			t.Errorf("is_personal does not match, got = %q, want = %q", got, "true")
+		}
+		if got := params.Get("cache_time"); got != "60" {
+			t.Errorf("cache_time does not match, got = %q, want = %q", got, "60")
+		}
+
+		var results []struct {
+			Title string `json:"title"`
+		}
+		if err := json.Unmarshal([]byte(params.Get("results")), &results); err != nil {
+			t.Fatalf("decode results: %v", err)
+		}
+		var titles []string
+		for _, r := range results {
+			titles = append(titles, r.Title)
+		}
+		if !reflect.DeepEqual(titles, page.wantTitles) {
+			t.Errorf("offset %q: titles do not match, got = %v, want = %v", page.offset, titles, page.wantTitles)
+		}
+	}
+
+	if got := atomic.LoadInt32(&first.calls); got != 2 {
+		t.Errorf("first provider calls do not match, got = %d, want = %d", got, 2)
+	}
+	if got := atomic.LoadInt32(&empty.calls); got != 1 {
+		t.Errorf("empty provider calls do not match, got = %d, want = %d", got, 1)
+	}
+}
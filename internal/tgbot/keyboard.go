@@ -0,0 +1,119 @@
+package tgbot
+
+import (
+	"github.com/yanzay/tbot/v2"
+)
+
+// InlineKeyboard builds the buttons attached to a message. Buttons are added
+// to the current row; Row starts a new one. The first error, e.g. too long
+// callback data, is returned by Build.
+type InlineKeyboard struct {
+	rows [][]tbot.InlineKeyboardButton
+	err  error
+}
+
+// NewInlineKeyboard starts an inline keyboard with one empty row.
+func NewInlineKeyboard() *InlineKeyboard {
+	return &InlineKeyboard{rows: [][]tbot.InlineKeyboardButton{nil}}
+}
+
+// Row starts a new row of buttons.
+func (k *InlineKeyboard) Row() *InlineKeyboard {
+	if len(k.rows[len(k.rows)-1]) > 0 {
+		k.rows = append(k.rows, nil)
+	}
+	return k
+}
+
+// Callback adds the button that sends the callback data to the bot.
+func (k *InlineKeyboard) Callback(text string, data CallbackData) *InlineKeyboard {
+	s, err := data.Encode()
+	if err != nil {
+		if k.err == nil {
+			k.err = err
+		}
+		return k
+	}
+
+	return k.add(tbot.InlineKeyboardButton{Text: text, CallbackData: s})
+}
+
+// URL adds the button that opens the link.
+func (k *InlineKeyboard) URL(text, url string) *InlineKeyboard {
+	return k.add(tbot.InlineKeyboardButton{Text: text, URL: url})
+}
+
+// SwitchInline adds the button that makes the user choose a chat and starts
+// an inline query to the bot there.
+func (k *InlineKeyboard) SwitchInline(text, query string) *InlineKeyboard {
+	return k.add(tbot.InlineKeyboardButton{Text: text, SwitchInlineQuery: &query})
+}
+
+func (k *InlineKeyboard) add(button tbot.InlineKeyboardButton) *InlineKeyboard {
+	last := len(k.rows) - 1
+	k.rows[last] = append(k.rows[last], button)
+	return k
+}
+
+// Build returns the keyboard markup.
+func (k *InlineKeyboard) Build() (*tbot.InlineKeyboardMarkup, error) {
+	if k.err != nil {
+		return nil, k.err
+	}
+
+	rows := k.rows
+	if len(rows[len(rows)-1]) == 0 {
+		rows = rows[:len(rows)-1]
+	}
+	return &tbot.InlineKeyboardMarkup{InlineKeyboard: rows}, nil
+}
+
+// ReplyKeyboard builds the keyboard shown instead of the system one. Pressing
+// a button sends its text as a message.
+type ReplyKeyboard struct {
+	markup tbot.ReplyKeyboardMarkup
+}
+
+// NewReplyKeyboard starts a reply keyboard with one empty row.
+func NewReplyKeyboard() *ReplyKeyboard {
+	return &ReplyKeyboard{
+		markup: tbot.ReplyKeyboardMarkup{Keyboard: [][]tbot.KeyboardButton{nil}},
+	}
+}
+
+// Row starts a new row of buttons.
+func (k *ReplyKeyboard) Row() *ReplyKeyboard {
+	if rows := k.markup.Keyboard; len(rows[len(rows)-1]) > 0 {
+		k.markup.Keyboard = append(rows, nil)
+	}
+	return k
+}
+
+// Button adds the button with the text.
+func (k *ReplyKeyboard) Button(text string) *ReplyKeyboard {
+	last := len(k.markup.Keyboard) - 1
+	k.markup.Keyboard[last] = append(k.markup.Keyboard[last], tbot.KeyboardButton{Text: text})
+	return k
+}
+
+// Resize fits the keyboard height to the buttons.
+func (k *ReplyKeyboard) Resize() *ReplyKeyboard {
+	k.markup.ResizeKeyboard = true
+	return k
+}
+
+// OneTime hides the keyboard after a button is pressed.
+func (k *ReplyKeyboard) OneTime() *ReplyKeyboard {
+	k.markup.OneTimeKeyboard = true
+	return k
+}
+
+// Build returns the keyboard markup.
+func (k *ReplyKeyboard) Build() *tbot.ReplyKeyboardMarkup {
+	markup := k.markup
+	rows := markup.Keyboard
+	if len(rows[len(rows)-1]) == 0 {
+		markup.Keyboard = rows[:len(rows)-1]
+	}
+	return &markup
+}
@@ -0,0 +1,139 @@
+package tgbot_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot"
+	"github.com/yanzay/tbot/v2"
+)
+
+func TestCallbackData(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		data    tgbot.CallbackData
+		want    string
+		wantErr error
+	}{
+		{
+			name: "route only",
+			data: tgbot.NewCallbackData("lang"),
+			want: "lang",
+		},
+		{
+			name: "args",
+			data: tgbot.NewCallbackData("vote", "42", "up"),
+			want: "vote|42|up",
+		},
+		{
+			name:    "too long",
+			data:    tgbot.NewCallbackData("vote", strings.Repeat("x", 60)),
+			wantErr: tgbot.ErrCallbackDataTooLong,
+		},
+		{
+			name: "64 bytes",
+			data: tgbot.NewCallbackData("vote", strings.Repeat("x", 59)),
+			want: "vote|" + strings.Repeat("x", 59),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				got, err := tc.data.Encode()
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("error does not match, got = %v, want = %v", err, tc.wantErr)
+				}
+				if err != nil {
+					return
+				}
+				if got != tc.want {
+					t.Errorf("encoded data does not match, got = %q, want = %q", got, tc.want)
+				}
+
+				parsed, err := tgbot.ParseCallbackData(got)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(parsed, tc.data) {
+					t.Errorf("parsed data does not match, got = %+v, want = %+v", parsed, tc.data)
+				}
+			},
+		)
+	}
+}
+
+func TestCallbackData_Separator(t *testing.T) {
+	t.Parallel()
+
+	if _, err := tgbot.NewCallbackData("vote", "a|b").Encode(); err == nil {
+		t.Error("expected error for argument with separator")
+	}
+}
+
+func TestCallbackData_Int(t *testing.T) {
+	t.Parallel()
+
+	data := tgbot.NewCallbackData("vote", "42", "up")
+	if got, err := data.Int(0); err != nil || got != 42 {
+		t.Errorf("int argument does not match, got = %d, %v, want = 42", got, err)
+	}
+	if _, err := data.Int(1); err == nil {
+		t.Error("expected error for non-integer argument")
+	}
+	if _, err := data.Int(2); err == nil {
+		t.Error("expected error for missing argument")
+	}
+}
+
+func TestInlineKeyboard(t *testing.T) {
+	t.Parallel()
+
+	markup, err := tgbot.NewInlineKeyboard().
+		Callback("Up", tgbot.NewCallbackData("vote", "1", "up")).
+		Callback("Down", tgbot.NewCallbackData("vote", "1", "down")).
+		Row().
+		URL("Site", "https://example.com").
+		Row().
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]tbot.InlineKeyboardButton{
+		{{Text: "Up", CallbackData: "vote|1|up"}, {Text: "Down", CallbackData: "vote|1|down"}},
+		{{Text: "Site", URL: "https://example.com"}},
+	}
+	if !reflect.DeepEqual(markup.InlineKeyboard, want) {
+		t.Errorf("keyboard does not match, got = %+v, want = %+v", markup.InlineKeyboard, want)
+	}
+
+	_, err = tgbot.NewInlineKeyboard().
+		Callback("Long", tgbot.NewCallbackData("vote", strings.Repeat("x", 64))).
+		Build()
+	if !errors.Is(err, tgbot.ErrCallbackDataTooLong) {
+		t.Errorf("expected ErrCallbackDataTooLong, got = %v", err)
+	}
+}
+
+func TestReplyKeyboard(t *testing.T) {
+	t.Parallel()
+
+	markup := tgbot.NewReplyKeyboard().Button("Yes").Button("No").Row().Button("Cancel").OneTime().Build()
+
+	want := &tbot.ReplyKeyboardMarkup{
+		Keyboard:        [][]tbot.KeyboardButton{{{Text: "Yes"}, {Text: "No"}}, {{Text: "Cancel"}}},
+		OneTimeKeyboard: true,
+	}
+	if !reflect.DeepEqual(markup, want) {
+		t.Errorf("keyboard does not match, got = %+v, want = %+v", markup, want)
+	}
+}
@@ -0,0 +1,200 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/i18n"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// updateSender returns the user who sent the update or nil.
+func updateSender(u *telegram.Update) *tbot.User {
+	switch {
+	case u.Message != nil:
+		return u.Message.From
+	case u.EditedMessage != nil:
+		return u.EditedMessage.From
+	case u.CallbackQuery != nil:
+		return u.CallbackQuery.From
+	case u.InlineQuery != nil:
+		return u.InlineQuery.From
+	case u.MyChatMember != nil:
+		return &u.MyChatMember.From
+	default:
+		return nil
+	}
+}
+
+// Localize attaches the localizer for the update sender to the context. The
+// locale chosen with /language wins over the Telegram language_code.
+func (b *Bot) Localize() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *telegram.Update) {
+			languageCode := ""
+			if from := updateSender(u); from != nil {
+				languageCode = from.LanguageCode
+				if locale, ok := b.userLocale(ctx, int64(from.ID)); ok {
+					languageCode = locale
+				}
+			}
+
+			next(i18n.WithLocalizer(ctx, b.catalog.Localizer(languageCode)), u)
+		}
+	}
+}
+
+// userLocale returns the locale chosen by the user. It reports false if the
+// user has not chosen one.
+func (b *Bot) userLocale(ctx context.Context, userID int64) (string, bool) {
+	if b.db == nil {
+		return "", false
+	}
+
+	v, err := b.localeCache.WriteThruLookup(
+		strconv.FormatInt(userID, 10), func() (interface{}, error) {
+			locale, err := b.db.GetUserLocale(ctx, userID)
+			if errors.Is(err, database.ErrNotFound) {
+				return "", nil
+			}
+			return locale, err
+		},
+	)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("get user locale", zap.Error(err))
+		return "", false
+	}
+
+	locale := v.(string)
+	return locale, locale != ""
+}
+
+// localizer returns the localizer attached to the context or the one for the
+// default locale.
+func (b *Bot) localizer(ctx context.Context) *i18n.Localizer {
+	if l := i18n.FromContext(ctx); l != nil {
+		return l
+	}
+	return b.catalog.Localizer(b.config.DefaultLocale)
+}
+
+// t renders the catalog message in the locale of the context.
+func (b *Bot) t(ctx context.Context, key string, params i18n.Params) string {
+	return b.localizer(ctx).Get(key, params)
+}
+
+// languageRoute is the callback route of the language buttons.
+const languageRoute = "lang"
+
+// Language shows or changes the locale of the user. Without the argument it
+// offers a button for every locale.
+func (b *Bot) Language(ctx context.Context, m *tbot.Message, args Args) {
+	languages := strings.Join(b.catalog.Tags(), ", ")
+
+	code := args.Get("language")
+	if code == "" {
+		text := b.t(ctx, "language.current", i18n.Params{
+			"Language":  b.localizer(ctx).Tag(),
+			"Languages": languages,
+		})
+
+		keyboard := NewInlineKeyboard()
+		for _, tag := range b.catalog.Tags() {
+			keyboard.Callback(
+				b.catalog.Localizer(tag).Get("language.name", nil),
+				NewCallbackData(languageRoute, tag),
+			)
+		}
+		markup, err := keyboard.Build()
+		if err != nil {
+			logging.FromContext(ctx).Errorw("build language keyboard", zap.Error(err))
+			b.reply(ctx, m, text)
+			return
+		}
+
+		b.replyWithKeyboard(ctx, m, text, markup)
+		return
+	}
+
+	locale, ok := b.catalog.Match(code)
+	if !ok {
+		b.reply(ctx, m, b.t(ctx, "language.unknown", i18n.Params{"Languages": languages}))
+		return
+	}
+
+	if err := b.setUserLocale(ctx, m.From, locale); err != nil {
+		logging.FromContext(ctx).Errorw("set user locale", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+
+	b.reply(ctx, m, b.catalog.Localizer(locale).Get("language.changed", nil))
+}
+
+// LanguageCallback changes the locale of the user to the one of the pressed
+// button.
+func (b *Bot) LanguageCallback(ctx context.Context, q *tbot.CallbackQuery, data CallbackData) CallbackAnswer {
+	locale, ok := b.catalog.Match(data.Arg(0))
+	if !ok {
+		return CallbackAnswer{Text: b.t(ctx, "callback.unknown", nil)}
+	}
+
+	if err := b.setUserLocale(ctx, q.From, locale); err != nil {
+		logging.FromContext(ctx).Errorw("set user locale", zap.Error(err))
+		return CallbackAnswer{Text: b.t(ctx, "error.try_again", nil), ShowAlert: true}
+	}
+
+	text := b.catalog.Localizer(locale).Get("language.changed", nil)
+	if q.Message != nil {
+		b.EditMessage(ctx, q.Message, text, nil)
+	}
+	return CallbackAnswer{Text: text}
+}
+
+// setUserLocale saves the locale chosen by the user.
+func (b *Bot) setUserLocale(ctx context.Context, user *tbot.User, locale string) error {
+	if b.db == nil {
+		return errNoDatabase
+	}
+	if user == nil {
+		return errors.New("update without sender")
+	}
+
+	if err := b.db.SetUserLocale(ctx, int64(user.ID), locale); err != nil {
+		return err
+	}
+	if err := b.localeCache.Set(strconv.Itoa(user.ID), locale); err != nil {
+		logging.FromContext(ctx).Errorw("cache user locale", zap.Error(err))
+	}
+
+	return nil
+}
+
+// setCommandMenus sets the bot menu in every catalog locale. The menu in the
+// default locale is shown to the users with other languages.
+func (b *Bot) setCommandMenus(ctx context.Context) {
+	log := logging.FromContext(ctx)
+
+	menu := func(l *i18n.Localizer) []tbot.BotCommand {
+		cmds := b.commands.botCommands()
+		for i := range cmds {
+			cmds[i].Description = l.Get(cmds[i].Description, nil)
+		}
+		return cmds
+	}
+
+	if err := b.api.SetMyCommands(ctx, menu(b.catalog.Localizer(b.config.DefaultLocale)), ""); err != nil {
+		log.Errorw("set bot commands", zap.Error(err))
+	}
+	for _, tag := range b.catalog.Tags() {
+		if err := b.api.SetMyCommands(ctx, menu(b.catalog.Localizer(tag)), tag); err != nil {
+			log.Errorw("set bot commands", "language_code", tag, zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package tgbot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// mediaFolder is the blob store folder of the received files. The objects
+// are named by the content hash, so a file uploaded many times is stored
+// once.
+const mediaFolder = "media"
+
+// The kinds of the received files.
+const (
+	mediaPhoto    = "photo"
+	mediaDocument = "document"
+	mediaVoice    = "voice"
+	mediaSticker  = "sticker"
+)
+
+// messageMedia returns the file attached to the message or nil if there is
+// none. Of the photo sizes the largest one is returned.
+func messageMedia(m *tbot.Message) *model.Media {
+	media := &model.Media{
+		ChatID:      m.Chat.ID,
+		TgMessageID: int64(m.MessageID),
+		Caption:     m.Caption,
+	}
+	if m.From != nil {
+		media.UserID = int64(m.From.ID)
+	}
+
+	switch {
+	case len(m.Photo) > 0:
+		p := m.Photo[len(m.Photo)-1]
+		media.Kind = mediaPhoto
+		media.FileID, media.FileUniqueID = p.FileID, p.FileUniqueID
+		media.MIMEType = "image/jpeg"
+		media.FileSize = int64(p.FileSize)
+	case m.Document != nil:
+		d := m.Document
+		media.Kind = mediaDocument
+		media.FileID, media.FileUniqueID = d.FileID, d.FileUniqueID
+		media.MIMEType = d.MIMEType
+		media.FileSize = int64(d.FileSize)
+	case m.Voice != nil:
+		v := m.Voice
+		media.Kind = mediaVoice
+		media.FileID, media.FileUniqueID = v.FileID, v.FileUniqueID
+		media.MIMEType = v.MimeType
+		media.FileSize = int64(v.FileSize)
+	case m.Sticker != nil:
+		s := m.Sticker
+		media.Kind = mediaSticker
+		media.FileID, media.FileUniqueID = s.FileID, s.FileUniqueID
+		media.MIMEType = "image/webp"
+		if s.IsAnimated {
+			media.MIMEType = "application/x-tgsticker"
+		}
+		media.FileSize = int64(s.FileSize)
+	default:
+		return nil
+	}
+
+	return media
+}
+
+// mediaModule answers the received files and downloads them. Without it the
+// files are only saved with the other messages the bot does not answer.
+type mediaModule struct{}
+
+func (mediaModule) Name() string {
+	return moduleMedia
+}
+
+func (mediaModule) Attach(b *Bot, _ interface{}) error {
+	b.mediaHandler = Chain(b.HandleMedia, b.middlewares()...)
+	return nil
+}
+
+// HandleMedia saves the file attached to the message and downloads it to
+// the blob store.
+func (b *Bot) HandleMedia(ctx context.Context, u *telegram.Update) {
+	m := u.Message
+	media := messageMedia(m)
+
+	b.saveMedia(ctx, media)
+	b.reply(ctx, m, b.t(ctx, "media.received", nil))
+	b.keepMedia(ctx, media)
+}
+
+// saveMedia saves the description of the file.
+func (b *Bot) saveMedia(ctx context.Context, media *model.Media) {
+	if b.db == nil {
+		return
+	}
+
+	if err := b.db.AddMedia(ctx, media); err != nil {
+		metricsware.NewMiddleware().RecordMessageSaveFailure(ctx)
+		logging.FromContext(ctx).Errorw(
+			"failed to save media", "kind", media.Kind, "file_unique_id", media.FileUniqueID, zap.Error(err),
+		)
+	}
+}
+
+// keepMedia downloads the file to the blob store, if there is one and the
+// media module is enabled.
+func (b *Bot) keepMedia(ctx context.Context, media *model.Media) {
+	if b.db == nil || b.blobs == nil || !b.config.enables(moduleMedia) || !b.featureEnabled(ctx, featureMedia) {
+		return
+	}
+
+	if err := b.storeMedia(ctx, media); err != nil {
+		logging.FromContext(ctx).Errorw(
+			"failed to store media", "kind", media.Kind, "file_unique_id", media.FileUniqueID, zap.Error(err),
+		)
+	}
+}
+
+// storeMedia downloads the file to the blob store unless it is too large or
+// already stored.
+func (b *Bot) storeMedia(ctx context.Context, media *model.Media) error {
+	log := logging.FromContext(ctx)
+	metricsMW := metricsware.NewMiddleware()
+
+	if media.FileSize > b.config.MediaMaxSize {
+		metricsMW.RecordMediaTooLarge(ctx)
+		log.Infow("media is too large to download", "file_size", media.FileSize)
+		return nil
+	}
+
+	// The same file sent again has the same unique ID.
+	hash, err := b.db.FindMediaContentHash(ctx, media.FileUniqueID)
+	switch {
+	case err == nil:
+		metricsMW.RecordMediaDeduplicated(ctx)
+		return b.db.SetMediaContentHash(ctx, media.ChatID, media.TgMessageID, hash)
+	case !errors.Is(err, database.ErrNotFound):
+		return err
+	}
+
+	data, err := b.downloadFile(ctx, media.FileID)
+	if err != nil {
+		if errors.Is(err, telegram.ErrFileTooLarge) {
+			metricsMW.RecordMediaTooLarge(ctx)
+			log.Infow("media is too large to download", zap.Error(err))
+			return nil
+		}
+		metricsMW.RecordMediaDownloadFailed(ctx)
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	hash = hex.EncodeToString(sum[:])
+
+	// A different file with the same contents is already stored.
+	exists, err := b.db.MediaBlobExists(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if exists {
+		metricsMW.RecordMediaDeduplicated(ctx)
+	} else {
+		if err := b.blobs.CreateObject(ctx, mediaFolder, hash, data); err != nil {
+			return err
+		}
+		if err := b.db.AddMediaBlob(ctx, hash, int64(len(data)), media.MIMEType); err != nil {
+			return err
+		}
+		metricsMW.RecordMediaStored(ctx)
+	}
+
+	return b.db.SetMediaContentHash(ctx, media.ChatID, media.TgMessageID, hash)
+}
+
+// downloadFile returns the contents of the file up to the size limit.
+func (b *Bot) downloadFile(ctx context.Context, fileID string) ([]byte, error) {
+	f, err := b.api.GetFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	return b.api.DownloadFile(ctx, f.FilePath, b.config.MediaMaxSize)
+}
@@ -0,0 +1,159 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+)
+
+// ErrUnauthorized is returned by a Policy to reject the update.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Handler handles an update from Telegram.
+type Handler func(ctx context.Context, u *telegram.Update)
+
+// Middleware wraps a Handler with additional behaviour.
+type Middleware func(next Handler) Handler
+
+// Chain wraps the handler with the middlewares. The first middleware is the
+// outermost one.
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Recover stops a handler panic from crashing the bot. The panic is logged
+// and counted.
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *telegram.Update) {
+			defer func() {
+				if r := recover(); r != nil {
+					metricsware.NewMiddleware().RecordHandlerPanic(ctx)
+					logging.FromContext(ctx).Errorw(
+						"handler panic",
+						"update_id", u.UpdateID,
+						"panic", r,
+						"stacktrace", string(debug.Stack()),
+					)
+				}
+			}()
+
+			next(ctx, u)
+		}
+	}
+}
+
+// Trace starts a span for the update handling.
+func Trace() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *telegram.Update) {
+			ctx, span := trace.StartSpan(ctx, "tgbot.HandleUpdate")
+			defer span.End()
+
+			span.AddAttributes(trace.Int64Attribute("update_id", int64(u.UpdateID)))
+			if q := u.CallbackQuery; q != nil {
+				span.AddAttributes(trace.StringAttribute("callback_query_id", q.ID))
+			}
+			if q := u.InlineQuery; q != nil {
+				span.AddAttributes(trace.StringAttribute("inline_query_id", q.ID))
+			}
+			if m := updateMessage(u); m != nil {
+				span.AddAttributes(
+					trace.StringAttribute("chat_id", m.Chat.ID),
+					trace.Int64Attribute("message_id", int64(m.MessageID)),
+				)
+			}
+
+			next(ctx, u)
+		}
+	}
+}
+
+// Logging attaches the logger with the update fields to the context and logs
+// the incoming message, edit, callback query, inline query or chat member
+// update.
+func Logging() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *telegram.Update) {
+			log := logging.FromContext(ctx).
+				Desugar().With(logging.TraceFromContext(ctx)...).Sugar().
+				With("update_id", u.UpdateID)
+
+			if m := updateMessage(u); m != nil {
+				log = log.With(
+					"chat_id", m.Chat.ID,
+					"incoming:message_id", m.MessageID,
+				)
+			}
+			switch {
+			case u.Message != nil:
+				log.Infow("got message", "text", u.Message.Text)
+			case u.EditedMessage != nil:
+				log.Infow("got edited message", "text", u.EditedMessage.Text)
+			case u.CallbackQuery != nil:
+				log = log.With("callback_query_id", u.CallbackQuery.ID)
+				log.Infow("got callback query", "data", u.CallbackQuery.Data)
+			case u.InlineQuery != nil:
+				log = log.With("inline_query_id", u.InlineQuery.ID, "user_id", u.InlineQuery.From.ID)
+				log.Infow("got inline query", "query", u.InlineQuery.Query, "offset", u.InlineQuery.Offset)
+			case u.MyChatMember != nil:
+				cm := u.MyChatMember
+				log = log.With("chat_id", cm.Chat.ID, "user_id", cm.From.ID)
+				log.Infow(
+					"got chat member update",
+					"old_status", cm.OldChatMember.Status, "new_status", cm.NewChatMember.Status,
+				)
+			}
+
+			next(logging.WithLogger(ctx, log), u)
+		}
+	}
+}
+
+// Metrics records the incoming updates.
+func Metrics() Middleware {
+	metricsMW := metricsware.NewMiddleware()
+	return func(next Handler) Handler {
+		h := metricsMW.Handle(func(ctx context.Context, u metricsware.Update) {
+			next(ctx, u.(*telegram.Update))
+		})
+		return func(ctx context.Context, u *telegram.Update) {
+			h(ctx, u)
+		}
+	}
+}
+
+// Policy decides whether the update may be handled. It returns
+// ErrUnauthorized, or an error wrapping it, to reject the update.
+type Policy func(ctx context.Context, u *telegram.Update) error
+
+// Authorize handles only the updates allowed by the policy. Rejected updates
+// are logged and counted.
+func Authorize(policy Policy) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *telegram.Update) {
+			if err := policy(ctx, u); err != nil {
+				log := logging.FromContext(ctx)
+				if !errors.Is(err, ErrUnauthorized) {
+					log.Errorw("authorize update", zap.Error(err))
+					return
+				}
+
+				metricsware.NewMiddleware().RecordUnauthorizedUpdate(ctx)
+				log.Warnw("unauthorized update", zap.Error(err))
+				return
+			}
+
+			next(ctx, u)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package tgbot_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+)
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	mw := func(name string) tgbot.Middleware {
+		return func(next tgbot.Handler) tgbot.Handler {
+			return func(ctx context.Context, u *telegram.Update) {
+				calls = append(calls, name)
+				next(ctx, u)
+			}
+		}
+	}
+
+	h := tgbot.Chain(
+		func(ctx context.Context, u *telegram.Update) {
+			calls = append(calls, "handler")
+		},
+		mw("first"), mw("second"),
+	)
+	h(context.Background(), &telegram.Update{})
+
+	want := []string{"first", "second", "handler"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls do not match, got = %v, want = %v", calls, want)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	t.Parallel()
+
+	h := tgbot.Chain(
+		func(ctx context.Context, u *telegram.Update) {
+			panic("boom")
+		},
+		tgbot.Recover(),
+	)
+
+	// Must not panic.
+	h(context.Background(), &telegram.Update{})
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		policyErr  error
+		wantCalled bool
+	}{
+		{
+			name:       "allowed",
+			wantCalled: true,
+		},
+		{
+			name:      "unauthorized",
+			policyErr: fmt.Errorf("user 1: %w", tgbot.ErrUnauthorized),
+		},
+		{
+			name:      "policy failure",
+			policyErr: fmt.Errorf("lookup roles"),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				called := false
+				h := tgbot.Chain(
+					func(ctx context.Context, u *telegram.Update) {
+						called = true
+					},
+					tgbot.Authorize(func(ctx context.Context, u *telegram.Update) error {
+						return tc.policyErr
+					}),
+				)
+				h(context.Background(), &telegram.Update{})
+
+				if called != tc.wantCalled {
+					t.Errorf("handler called = %t, want = %t", called, tc.wantCalled)
+				}
+			},
+		)
+	}
+}
@@ -1,9 +1,231 @@
 package model
 
+import "time"
+
 type Message struct {
 	// Telegram's message ID
 	TgMessageID int64
 	UserID      int64
 	ChatID      string
-	Text        string
+	// Text is the latest text of the message.
+	Text string
+	// Revision is the number of the edits, EditedAt is the time of the last
+	// one.
+	Revision int
+	EditedAt time.Time
+}
+
+// MessageRevision is a replaced text of the message.
+type MessageRevision struct {
+	ChatID      string
+	TgMessageID int64
+	// Revision is 0 for the original text and increases with every edit.
+	Revision   int
+	Text       string
+	ReplacedAt time.Time
+}
+
+// ChatState is the conversation state of a chat. Messages in the chat are
+// routed to the handler of the state until it expires.
+type ChatState struct {
+	ChatID    string
+	State     string
+	Data      map[string]string
+	ExpiresAt time.Time
+}
+
+// OutboxMessage is an outgoing message waiting for delivery.
+type OutboxMessage struct {
+	ID               int64
+	ChatID           string
+	Text             string
+	ReplyToMessageID int64
+	// ReplyMarkup is the JSON encoded keyboard or an empty string.
+	ReplyMarkup string
+	// Attempts is the number of failed deliveries.
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
+
+// DeadLetter is an outgoing message that could not be delivered.
+type DeadLetter struct {
+	OutboxMessage
+	FailedAt time.Time
+}
+
+// Media is a file attached to a received message.
+type Media struct {
+	ChatID       string
+	TgMessageID  int64
+	UserID       int64
+	Kind         string
+	FileID       string
+	FileUniqueID string
+	MIMEType     string
+	FileSize     int64
+	Caption      string
+	// ContentHash is the hex encoded SHA-256 of the file contents, empty
+	// until the file is downloaded to the blob store.
+	ContentHash string
+	CreatedAt   time.Time
+}
+
+// ChatSettings is the configuration of a chat.
+type ChatSettings struct {
+	ChatID string `json:"-"`
+	// Silent chats are listened to: the messages are saved, but only the
+	// commands are answered.
+	Silent bool `json:"silent"`
+}
+
+// User is a Telegram user seen by the bot.
+type User struct {
+	ID           int64
+	Username     string
+	FirstName    string
+	LastName     string
+	LanguageCode string
+	IsBot        bool
+	// Blocked is set when the user blocked the bot and cleared when the user
+	// writes to it again.
+	Blocked     bool
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+// Chat is a chat the bot is or was a member of.
+type Chat struct {
+	ID string
+	// Type is one of "private", "group", "supergroup" and "channel".
+	Type     string
+	Title    string
+	Username string
+	// MemberStatus is the status of the bot in the chat, e.g. "member",
+	// "administrator" or "kicked". The private chat is "kicked" when the user
+	// blocked the bot.
+	MemberStatus string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// UserRole is the operator role of a user, e.g. "admin".
+type UserRole struct {
+	UserID int64
+	Role   string
+	// GrantedBy is the user who granted the role or 0 for the owners from
+	// the configuration.
+	GrantedBy int64
+	UpdatedAt time.Time
+}
+
+// Feature is a bot feature switched on or off by the operators.
+type Feature struct {
+	Name      string
+	Enabled   bool
+	UpdatedBy int64
+	UpdatedAt time.Time
+}
+
+// Stats are the totals shown to the operators.
+type Stats struct {
+	Users        int64
+	BlockedUsers int64
+	// Chats is the number of the chats the bot is a member of by chat type.
+	Chats         map[string]int64
+	OutboxPending int64
+	DeadLetters   int64
+}
+
+// AccessRule allows or denies the updates from a user or a chat.
+type AccessRule struct {
+	ID int64
+	// Action is "allow" or "deny".
+	Action string
+	// Kind is "user" or "chat" for the rules by ID, or "username" for the
+	// rules by a username pattern, e.g. "*_spam_bot".
+	Kind      string
+	Value     string
+	Note      string
+	CreatedBy int64
+	CreatedAt time.Time
+}
+
+// Broadcast is a message sent to many chats, e.g. an announcement.
+type Broadcast struct {
+	ID   int64
+	Text string
+	// MediaKind and MediaFileID are set when the message is a file, the
+	// Text is its caption then.
+	MediaKind   string
+	MediaFileID string
+	// ReplyMarkup is the JSON encoded keyboard or an empty string.
+	ReplyMarkup string
+	Audience    BroadcastAudience
+	// Status is "running", "done" or "cancelled".
+	Status     string
+	CreatedBy  int64
+	CreatedAt  time.Time
+	FinishedAt time.Time
+	// Recipients is the number of the recipients by delivery status:
+	// "pending", "sent", "failed" or "blocked".
+	Recipients map[string]int64
+}
+
+// BroadcastAudience selects the recipients of a broadcast. The zero value
+// selects all the users who did not block the bot.
+type BroadcastAudience struct {
+	// LanguageCode selects the users with the Telegram language.
+	LanguageCode string
+	// SeenSince selects the users seen after the time.
+	SeenSince time.Time
+	// Groups adds the groups the bot is a member of.
+	Groups bool
+}
+
+// BroadcastRecipient is a chat waiting for a broadcast.
+type BroadcastRecipient struct {
+	BroadcastID int64
+	ChatID      string
+	// Attempts is the number of the failed deliveries.
+	Attempts int
+}
+
+// ScheduledJob is a message sent at a scheduled time, e.g. a reminder.
+type ScheduledJob struct {
+	ID int64
+	// Kind is "reminder" for the user reminders or "post" for the messages
+	// scheduled by the operators.
+	Kind      string
+	ChatID    string
+	CreatedBy int64
+	Text      string
+	// Schedule is the cron expression of a recurring job or an empty string
+	// for a one-time job. It is evaluated in the TimeZone.
+	Schedule  string
+	TimeZone  string
+	NextRunAt time.Time
+	Runs      int
+	LastRunAt time.Time
+	CreatedAt time.Time
+}
+
+// FloodCounter is the persisted anti-flood state of a user or a chat.
+type FloodCounter struct {
+	// Key is "user:<id>" or "chat:<id>".
+	Key string
+	// Day is the UTC day of the DailyCount.
+	Day        time.Time
+	DailyCount int64
+	// MutedUntil is the end of the mute or the zero time.
+	MutedUntil time.Time
+}
+
+// UpdateOffset is the last update of the bot handled together with all the
+// earlier ones.
+type UpdateOffset struct {
+	BotID        int64
+	LastUpdateID int64
+	UpdatedAt    time.Time
 }
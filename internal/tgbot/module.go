@@ -0,0 +1,274 @@
+package tgbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/i18n"
+	"github.com/sethvargo/go-envconfig"
+	"github.com/yanzay/tbot/v2"
+)
+
+// The built-in modules.
+const (
+	// moduleReminders handles /remind, /reminders, /unremind, /timezone and
+	// /schedule and runs the scheduled jobs.
+	moduleReminders = "reminders"
+	// moduleBroadcasts handles /broadcast, /broadcasts and /stopbroadcast
+	// and sends the broadcasts.
+	moduleBroadcasts = "broadcasts"
+	// moduleAdmin handles the other operator commands.
+	moduleAdmin = "admin"
+	// moduleInline answers the inline queries with the message history.
+	moduleInline = "inline"
+	// moduleMedia answers the received files and downloads them.
+	moduleMedia = "media"
+	// moduleEcho answers the text messages with their text.
+	moduleEcho = "echo"
+)
+
+// defaultModules are enabled when Config.Modules is empty.
+var defaultModules = []string{
+	moduleReminders, moduleBroadcasts, moduleAdmin, moduleInline, moduleMedia, moduleEcho,
+}
+
+func init() {
+	RegisterModule(remindersModule{})
+	RegisterModule(broadcastsModule{})
+	RegisterModule(adminModule{})
+	RegisterModule(inlineModule{})
+	RegisterModule(mediaModule{})
+	RegisterModule(echoModule{})
+}
+
+// Module is a feature of the bot enabled per deployment with Config.Modules,
+// e.g. the reminders or the FAQ. The commands like /start, /help and
+// /language, the buttons and the chat events belong to the core and are
+// always handled.
+//
+// Attach declares what the module adds to the bot: the commands with
+// AddCommand, the message handlers with HandleMessage, the buttons with
+// HandleCallback, the dialogs with HandleState, the inline results with
+// AddInlineProvider, the messages with AddLocale and the background workers
+// with AddWorker. The module settings are declared with ModuleConfig and its
+// tables with ModuleMigrations.
+type Module interface {
+	// Name identifies the module in Config.Modules and prefixes its
+	// settings. It matches moduleNameRx.
+	Name() string
+	// Attach adds the module to the bot. It is called for every bot serving
+	// the module before the updates are received. The config is the one
+	// returned by ModuleConfig, processed from the environment, or nil. The
+	// module state belongs to the values created by Attach, as the module is
+	// shared by the bots of the process.
+	Attach(b *Bot, config interface{}) error
+}
+
+// ModuleConfig is implemented by the modules with settings.
+type ModuleConfig interface {
+	// NewConfig returns a pointer to a new config struct processed with
+	// envconfig. The variable names are prefixed with the upper case module
+	// name, e.g. the `env:"ENTRIES"` field of the faq module is set with
+	// FAQ_ENTRIES.
+	NewConfig() interface{}
+}
+
+// ModuleMigrations is implemented by the modules with their own tables.
+type ModuleMigrations interface {
+	// Migrations returns the directory of the module migrations, named like
+	// the core ones. They are applied by cmd/migrate after the core ones and
+	// versioned apart from them, in the schema_migrations_<name> table.
+	Migrations() http.FileSystem
+}
+
+// Worker runs in the background while the bot is served. It returns once
+// stop is closed. The context outlives stop to finish the started work and is
+// canceled only if the shutdown takes too long.
+type Worker func(ctx context.Context, stop <-chan struct{})
+
+// moduleNameRx matches the valid module names.
+var moduleNameRx = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+var (
+	modulesMu sync.RWMutex
+	modules   = make(map[string]Module)
+)
+
+// RegisterModule makes the module available to Config.Modules. It is meant
+// to be called from the init function of the module package, which is then
+// linked into the binaries with a blank import. It panics if the name is
+// invalid or taken.
+func RegisterModule(m Module) {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+
+	name := m.Name()
+	if !moduleNameRx.MatchString(name) {
+		panic(fmt.Sprintf("invalid module name %q", name))
+	}
+	if _, ok := modules[name]; ok {
+		panic(fmt.Sprintf("module %q registered twice", name))
+	}
+	modules[name] = m
+}
+
+// Modules returns the registered modules sorted by name.
+func Modules() []Module {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+
+	list := make([]Module, 0, len(modules))
+	for _, m := range modules {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list
+}
+
+func lookupModule(name string) (Module, bool) {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+
+	m, ok := modules[name]
+	return m, ok
+}
+
+// moduleNames returns the names of the enabled modules in the attach order.
+func (c *Config) moduleNames() []string {
+	if len(c.Modules) == 0 {
+		return defaultModules
+	}
+	return c.Modules
+}
+
+// enables reports whether the module is enabled.
+func (c *Config) enables(name string) bool {
+	for _, m := range c.moduleNames() {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateModules returns an error if an enabled module is not registered or
+// is enabled twice.
+func (c *Config) validateModules() error {
+	seen := make(map[string]bool, len(c.Modules))
+	for _, name := range c.Modules {
+		if _, ok := lookupModule(name); !ok {
+			var names []string
+			for _, m := range Modules() {
+				names = append(names, m.Name())
+			}
+			return fmt.Errorf("unknown module %q, want one of %s", name, strings.Join(names, ", "))
+		}
+		if seen[name] {
+			return fmt.Errorf("module %q is enabled twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// processModules sets ModuleConfigs to the configs of the enabled modules
+// looked up in l with the module name prefix.
+func (c *Config) processModules(ctx context.Context, l envconfig.Lookuper, fns ...envconfig.MutatorFunc) error {
+	if err := c.validateModules(); err != nil {
+		return err
+	}
+
+	for _, name := range c.moduleNames() {
+		m, _ := lookupModule(name)
+		mc, ok := m.(ModuleConfig)
+		if !ok {
+			continue
+		}
+
+		config := mc.NewConfig()
+		prefix := strings.ToUpper(name) + "_"
+		if err := envconfig.ProcessWith(ctx, config, envconfig.PrefixLookuper(prefix, l), fns...); err != nil {
+			return fmt.Errorf("module %q: %w", name, err)
+		}
+		if c.ModuleConfigs == nil {
+			c.ModuleConfigs = make(map[string]interface{})
+		}
+		c.ModuleConfigs[name] = config
+	}
+	return nil
+}
+
+// attachModules attaches the enabled modules. The modules without a config
+// in ModuleConfigs get the defaults.
+func (b *Bot) attachModules(ctx context.Context) error {
+	for _, name := range b.config.moduleNames() {
+		m, _ := lookupModule(name)
+
+		config := b.config.ModuleConfigs[name]
+		if mc, ok := m.(ModuleConfig); ok && config == nil {
+			config = mc.NewConfig()
+			if err := envconfig.ProcessWith(ctx, config, envconfig.MapLookuper(nil)); err != nil {
+				return fmt.Errorf("module %q: %w", name, err)
+			}
+		}
+
+		if err := m.Attach(b, config); err != nil {
+			return fmt.Errorf("attach module %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// AddCommand registers the command. It must be called from Module.Attach.
+func (b *Bot) AddCommand(cmd *Command) {
+	b.commands.register(cmd)
+}
+
+// HandleMessage registers the handler for the messages with text matching
+// the pattern. The handlers are checked after the commands in the
+// registration order, the messages in the chats with a dialog go to the
+// state handlers first. It must be called from Module.Attach.
+func (b *Bot) HandleMessage(pattern string, h Handler) {
+	b.handleMessage(pattern, b.withState(h))
+}
+
+// AddWorker runs the worker while the bot is served. It must be called from
+// Module.Attach.
+func (b *Bot) AddWorker(w Worker) {
+	b.moduleWorkers = append(b.moduleWorkers, w)
+}
+
+// AddLocale adds the messages of the locale to the catalog of the bot.
+func (b *Bot) AddLocale(l *i18n.Locale) error {
+	return b.catalog.Add(l)
+}
+
+// Reply sends the text as a reply to the message.
+func (b *Bot) Reply(ctx context.Context, m *tbot.Message, text string) {
+	b.reply(ctx, m, text)
+}
+
+// T renders the catalog message in the locale of the context.
+func (b *Bot) T(ctx context.Context, key string, params i18n.Params) string {
+	return b.t(ctx, key, params)
+}
+
+// Name returns the name of the bot, see Config.BotName.
+func (b *Bot) Name() string {
+	return b.config.botName()
+}
+
+// Database returns the database of the environment, or nil if there is
+// none. The modules keep the bot name in their rows, so the bots sharing the
+// database do not see the data of each other.
+func (b *Bot) Database() *database.DB {
+	return b.env.Database()
+}
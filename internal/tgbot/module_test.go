@@ -0,0 +1,165 @@
+package tgbot
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sethvargo/go-envconfig"
+)
+
+type testModuleConfig struct {
+	Greeting string `env:"GREETING, default=hello"`
+	Limit    int    `env:"LIMIT"`
+}
+
+// testModule is a module with settings.
+type testModule struct{}
+
+func (testModule) Name() string {
+	return "testing"
+}
+
+func (testModule) NewConfig() interface{} {
+	return &testModuleConfig{}
+}
+
+func (testModule) Attach(*Bot, interface{}) error {
+	return nil
+}
+
+func init() {
+	RegisterModule(testModule{})
+}
+
+func TestRegisterModule(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		module Module
+	}{
+		{
+			name:   "registered twice",
+			module: testModule{},
+		},
+		{
+			name:   "invalid name",
+			module: namedModule("Test Module"),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				defer func() {
+					if recover() == nil {
+						t.Error("expected panic")
+					}
+				}()
+				RegisterModule(tc.module)
+			},
+		)
+	}
+}
+
+// namedModule is a module without handlers.
+type namedModule string
+
+func (m namedModule) Name() string {
+	return string(m)
+}
+
+func (namedModule) Attach(*Bot, interface{}) error {
+	return nil
+}
+
+func TestConfig_ProcessModules(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		modules []string
+		env     map[string]string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "default modules",
+		},
+		{
+			name:    "module config",
+			modules: []string{moduleEcho, "testing"},
+			env:     map[string]string{"TESTING_LIMIT": "3", "LIMIT": "5"},
+			want:    map[string]interface{}{"testing": &testModuleConfig{Greeting: "hello", Limit: 3}},
+		},
+		{
+			name:    "invalid setting",
+			modules: []string{"testing"},
+			env:     map[string]string{"TESTING_LIMIT": "many"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown module",
+			modules: []string{"weather"},
+			wantErr: true,
+		},
+		{
+			name:    "enabled twice",
+			modules: []string{moduleEcho, moduleEcho},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				c := &Config{Modules: tc.modules}
+				err := c.processModules(context.Background(), envconfig.MapLookuper(tc.env))
+				if (err != nil) != tc.wantErr {
+					t.Fatalf("error does not match, got = %v, want error = %t", err, tc.wantErr)
+				}
+				if err == nil && !reflect.DeepEqual(c.ModuleConfigs, tc.want) {
+					t.Errorf("module configs do not match, got = %+v, want = %+v", c.ModuleConfigs, tc.want)
+				}
+			},
+		)
+	}
+}
+
+func TestConfig_BotConfigs_Modules(t *testing.T) {
+	t.Parallel()
+
+	c := &Config{
+		Bots: BotDefinitions{
+			{Name: "main", Token: "t1", Modules: []string{"testing"}},
+			{
+				Name:     "support",
+				Token:    "t2",
+				Modules:  []string{"testing"},
+				Settings: map[string]string{"TESTING_GREETING": "hi"},
+			},
+		},
+	}
+	env := envconfig.MapLookuper(map[string]string{"TESTING_LIMIT": "3"})
+
+	configs, err := c.BotConfigs(context.Background(), env, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*testModuleConfig{{Greeting: "hello", Limit: 3}, {Greeting: "hi", Limit: 3}}
+	for i, config := range configs {
+		if got := config.ModuleConfigs["testing"]; !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("module config of %s does not match, got = %+v, want = %+v", config.BotName, got, want[i])
+		}
+	}
+}
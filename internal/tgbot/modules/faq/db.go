@@ -0,0 +1,84 @@
+package faq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/jackc/pgx/v4"
+)
+
+// entriesDB keeps the answers of one bot in the faq_entries table.
+type entriesDB struct {
+	db  *database.DB
+	bot string
+}
+
+// list returns the saved answers keyed by topic.
+func (db *entriesDB) list(ctx context.Context) (map[string]string, error) {
+	const q = `SELECT topic, answer FROM faq_entries WHERE bot_name = $1`
+
+	rows, err := db.db.Pool.Query(ctx, q, db.bot)
+	if err != nil {
+		return nil, fmt.Errorf("reading faq entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make(map[string]string)
+	for rows.Next() {
+		var topic, answer string
+		if err := rows.Scan(&topic, &answer); err != nil {
+			return nil, fmt.Errorf("reading faq entries: %w", err)
+		}
+		entries[topic] = answer
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading faq entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+// set saves the answer on the topic.
+func (db *entriesDB) set(ctx context.Context, topic, answer string, updatedBy int64) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `
+			INSERT INTO
+				faq_entries
+				(bot_name, topic, answer, updated_by)
+			VALUES
+				($1, $2, $3, $4)
+			ON CONFLICT (bot_name, topic) DO UPDATE SET
+				answer = excluded.answer,
+				updated_by = excluded.updated_by,
+				updated_at = now()
+		`
+			if _, err := tx.Exec(ctx, q, db.bot, topic, answer, updatedBy); err != nil {
+				return fmt.Errorf("saving faq entry: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// delete deletes the answer on the topic. It returns database.ErrNotFound if
+// there is none.
+func (db *entriesDB) delete(ctx context.Context, topic string) error {
+	return db.db.InTx(
+		ctx, pgx.Serializable, func(tx pgx.Tx) error {
+			const q = `DELETE FROM faq_entries WHERE bot_name = $1 AND topic = $2`
+
+			result, err := tx.Exec(ctx, q, db.bot, topic)
+			if err != nil {
+				return fmt.Errorf("deleting faq entry: %w", err)
+			}
+			if result.RowsAffected() == 0 {
+				return database.ErrNotFound
+			}
+
+			return nil
+		},
+	)
+}
@@ -0,0 +1,341 @@
+// Package faq answers the frequent questions. The operators set the answers
+// with /setfaq, the users get them with /faq <topic> or a #topic message.
+// The module is enabled with MODULES, e.g. MODULES=faq,echo, and set up with
+// the FAQ_ variables, see Config.
+package faq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/i18n"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/markbates/pkger"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// Name is the name of the module.
+const Name = "faq"
+
+// topicPattern matches a topic: one word of letters, digits and
+// underscores.
+const topicPattern = `[\p{L}\p{N}_]+`
+
+// topicRx matches the valid topics.
+var topicRx = regexp.MustCompile("^" + topicPattern + "$")
+
+// errNoDatabase is returned by the changes of the answers when the bot runs
+// without the database.
+var errNoDatabase = errors.New("no database configured")
+
+func init() {
+	tgbot.RegisterModule(Module{})
+}
+
+// Config is the config of the module.
+type Config struct {
+	// Entries are the answers keyed by topic, e.g.
+	// FAQ_ENTRIES={"rules": "Be nice."}. The answers set with /setfaq take
+	// precedence.
+	Entries Entries `env:"ENTRIES"`
+	// RefreshInterval is how often the answers are reloaded from the
+	// database, so the changes made by the other replicas are seen.
+	RefreshInterval time.Duration `env:"REFRESH_INTERVAL, default=1m"`
+	// MaxAnswerLength limits the answers set with /setfaq, in characters.
+	MaxAnswerLength int `env:"MAX_ANSWER_LENGTH, default=1000"`
+}
+
+// Entries are the answers keyed by topic.
+type Entries map[string]string
+
+// EnvDecode decodes the answers from the JSON object.
+func (e *Entries) EnvDecode(val string) error {
+	if strings.TrimSpace(val) == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(val), e); err != nil {
+		return fmt.Errorf("decoding faq entries: %w", err)
+	}
+	return nil
+}
+
+// Module is the FAQ module.
+type Module struct{}
+
+// Name implements tgbot.Module.
+func (Module) Name() string {
+	return Name
+}
+
+// NewConfig implements tgbot.ModuleConfig.
+func (Module) NewConfig() interface{} {
+	return &Config{}
+}
+
+// Migrations implements tgbot.ModuleMigrations.
+func (Module) Migrations() http.FileSystem {
+	return pkger.Dir("/internal/tgbot/modules/faq/migrations")
+}
+
+// Attach implements tgbot.Module.
+func (Module) Attach(b *tgbot.Bot, config interface{}) error {
+	f := &faq{
+		bot:      b,
+		config:   config.(*Config),
+		defaults: make(map[string]string),
+	}
+	for topic, answer := range f.config.Entries {
+		f.defaults[normalizeTopic(topic)] = answer
+	}
+	f.entries = f.merge(nil)
+
+	for _, l := range locales {
+		if err := b.AddLocale(l); err != nil {
+			return err
+		}
+	}
+	if db := b.Database(); db != nil {
+		f.db = &entriesDB{db: db, bot: b.Name()}
+		b.AddWorker(f.refresh)
+	}
+
+	b.AddCommand(&tgbot.Command{
+		Name:        "faq",
+		Description: "command.faq.description",
+		Help:        "command.faq.help",
+		Args:        []tgbot.Arg{{Name: "topic", Optional: true}},
+		Run:         f.FAQ,
+	})
+	b.AddCommand(&tgbot.Command{
+		Name:        "setfaq",
+		Description: "command.setfaq.description",
+		Args:        []tgbot.Arg{{Name: "topic"}, {Name: "answer", Rest: true}},
+		Hidden:      true,
+		Role:        tgbot.RoleModerator,
+		Run:         f.SetFAQ,
+	})
+	b.AddCommand(&tgbot.Command{
+		Name:        "unfaq",
+		Description: "command.unfaq.description",
+		Args:        []tgbot.Arg{{Name: "topic"}},
+		Hidden:      true,
+		Role:        tgbot.RoleModerator,
+		Run:         f.UnFAQ,
+	})
+	b.HandleMessage("^#"+topicPattern+"$", f.Hashtag)
+
+	return nil
+}
+
+// faq is the module attached to one bot.
+type faq struct {
+	bot    *tgbot.Bot
+	config *Config
+	// db is nil when the bot has no database. The answers can't be changed
+	// then.
+	db *entriesDB
+	// defaults are the configured answers.
+	defaults map[string]string
+
+	mu      sync.RWMutex
+	entries map[string]string
+}
+
+// normalizeTopic returns the topic without the leading # in lower case.
+func normalizeTopic(topic string) string {
+	return strings.ToLower(strings.TrimPrefix(topic, "#"))
+}
+
+// merge returns the configured answers overridden by the saved ones.
+func (f *faq) merge(saved map[string]string) map[string]string {
+	entries := make(map[string]string, len(f.defaults)+len(saved))
+	for topic, answer := range f.defaults {
+		entries[topic] = answer
+	}
+	for topic, answer := range saved {
+		entries[topic] = answer
+	}
+	return entries
+}
+
+// answer returns the answer on the topic.
+func (f *faq) answer(topic string) (string, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	answer, ok := f.entries[topic]
+	return answer, ok
+}
+
+// topics returns the topics with the answers sorted by name.
+func (f *faq) topics() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	topics := make([]string, 0, len(f.entries))
+	for topic := range f.entries {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// refresh reloads the answers from the database every RefreshInterval until
+// stop is closed.
+func (f *faq) refresh(ctx context.Context, stop <-chan struct{}) {
+	f.reload(ctx)
+
+	ticker := time.NewTicker(f.config.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.reload(ctx)
+		}
+	}
+}
+
+// reload reads the saved answers.
+func (f *faq) reload(ctx context.Context) {
+	saved, err := f.db.list(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("list faq entries", zap.Error(err))
+		return
+	}
+
+	entries := f.merge(saved)
+
+	f.mu.Lock()
+	f.entries = entries
+	f.mu.Unlock()
+}
+
+// FAQ lists the topics or sends the answer on the topic given as the
+// argument.
+func (f *faq) FAQ(ctx context.Context, m *tbot.Message, args tgbot.Args) {
+	if topic := args.Get("topic"); topic != "" {
+		f.reply(ctx, m, normalizeTopic(topic))
+		return
+	}
+
+	topics := f.topics()
+	if len(topics) == 0 {
+		f.bot.Reply(ctx, m, f.bot.T(ctx, "faq.empty", nil))
+		return
+	}
+	for i, topic := range topics {
+		topics[i] = "#" + topic
+	}
+	f.bot.Reply(ctx, m, f.bot.T(ctx, "faq.topics", i18n.Params{"Topics": strings.Join(topics, ", ")}))
+}
+
+// Hashtag sends the answer on the topic of the #topic message.
+func (f *faq) Hashtag(ctx context.Context, u *telegram.Update) {
+	f.reply(ctx, u.Message, normalizeTopic(u.Message.Text))
+}
+
+// reply sends the answer on the topic.
+func (f *faq) reply(ctx context.Context, m *tbot.Message, topic string) {
+	answer, ok := f.answer(topic)
+	if !ok {
+		f.bot.Reply(ctx, m, f.bot.T(ctx, "faq.unknown", i18n.Params{"Topic": topic}))
+		return
+	}
+	f.bot.Reply(ctx, m, answer)
+}
+
+// SetFAQ saves the answer on the topic.
+func (f *faq) SetFAQ(ctx context.Context, m *tbot.Message, args tgbot.Args) {
+	topic, answer := normalizeTopic(args.Get("topic")), args.Get("answer")
+	if !topicRx.MatchString(topic) {
+		f.bot.Reply(ctx, m, f.bot.T(ctx, "faq.invalid_topic", nil))
+		return
+	}
+	if f.config.MaxAnswerLength > 0 && utf8.RuneCountInString(answer) > f.config.MaxAnswerLength {
+		f.bot.Reply(ctx, m, f.bot.T(ctx, "faq.too_long", i18n.Params{"Limit": f.config.MaxAnswerLength}))
+		return
+	}
+
+	if err := f.set(ctx, topic, answer, m.From); err != nil {
+		logging.FromContext(ctx).Errorw("set faq entry", "topic", topic, zap.Error(err))
+		f.bot.Reply(ctx, m, f.bot.T(ctx, "error.try_again", nil))
+		return
+	}
+	logging.FromContext(ctx).Infow("faq entry set", "topic", topic)
+
+	f.bot.Reply(ctx, m, f.bot.T(ctx, "faq.saved", i18n.Params{"Topic": topic}))
+}
+
+func (f *faq) set(ctx context.Context, topic, answer string, by *tbot.User) error {
+	if f.db == nil {
+		return errNoDatabase
+	}
+
+	var updatedBy int64
+	if by != nil {
+		updatedBy = int64(by.ID)
+	}
+	if err := f.db.set(ctx, topic, answer, updatedBy); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.entries[topic] = answer
+	f.mu.Unlock()
+
+	return nil
+}
+
+// UnFAQ deletes the saved answer on the topic. The configured answer, if
+// any, is given again then.
+func (f *faq) UnFAQ(ctx context.Context, m *tbot.Message, args tgbot.Args) {
+	topic := normalizeTopic(args.Get("topic"))
+
+	err := f.delete(ctx, topic)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		f.bot.Reply(ctx, m, f.bot.T(ctx, "faq.unknown", i18n.Params{"Topic": topic}))
+	case err != nil:
+		logging.FromContext(ctx).Errorw("delete faq entry", "topic", topic, zap.Error(err))
+		f.bot.Reply(ctx, m, f.bot.T(ctx, "error.try_again", nil))
+	default:
+		logging.FromContext(ctx).Infow("faq entry deleted", "topic", topic)
+		f.bot.Reply(ctx, m, f.bot.T(ctx, "faq.deleted", i18n.Params{"Topic": topic}))
+	}
+}
+
+func (f *faq) delete(ctx context.Context, topic string) error {
+	if f.db == nil {
+		return errNoDatabase
+	}
+	if err := f.db.delete(ctx, topic); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.entries, topic)
+	if answer, ok := f.defaults[topic]; ok {
+		f.entries[topic] = answer
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package faq_test
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/modules/faq"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/tgbottest"
+	"github.com/golang-migrate/migrate/v4/source/httpfs"
+)
+
+func TestModule(t *testing.T) {
+	t.Parallel()
+
+	const ownerID = 7
+
+	tests := []struct {
+		name     string
+		userID   int
+		text     string
+		wantText string
+	}{
+		{
+			name:     "topics",
+			text:     "/faq",
+			wantText: "Темы: #contacts, #rules",
+		},
+		{
+			name:     "answer",
+			text:     "/faq rules",
+			wantText: "Be nice.",
+		},
+		{
+			name:     "hashtag",
+			text:     "#Rules",
+			wantText: "Be nice.",
+		},
+		{
+			name:     "unknown topic",
+			text:     "/faq weather",
+			wantText: "У меня нет ответа по теме «weather».",
+		},
+		{
+			name:     "help",
+			text:     "/help",
+			wantText: "/faq — Ответы на частые вопросы",
+		},
+		{
+			name:     "set by user",
+			userID:   42,
+			text:     "/setfaq rules Be kind.",
+			wantText: "Я тебя не понимаю!",
+		},
+		{
+			name:     "set invalid topic",
+			userID:   ownerID,
+			text:     "/setfaq house-rules Be kind.",
+			wantText: "Тема — одно слово из букв, цифр и подчёркиваний.",
+		},
+		{
+			name:     "set too long",
+			userID:   ownerID,
+			text:     "/setfaq rules " + strings.Repeat("a", 11),
+			wantText: "Ответ слишком длинный, максимум 10 символов.",
+		},
+		{
+			name:     "set without database",
+			userID:   ownerID,
+			text:     "/setfaq rules Be kind.",
+			wantText: "Не получилось, попробуй ещё раз.",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				srv := tgbottest.Serve(t, func(c *tgbot.Config) {
+					c.Modules = []string{faq.Name}
+					c.OwnerIDs = []int64{ownerID}
+					c.ModuleConfigs = map[string]interface{}{
+						faq.Name: &faq.Config{
+							Entries:         faq.Entries{"Rules": "Be nice.", "contacts": "@support"},
+							MaxAnswerLength: 10,
+						},
+					}
+				}, nil)
+
+				userID := tc.userID
+				if userID == 0 {
+					userID = 42
+				}
+				srv.AddMessage(int64(userID), userID, tc.text)
+
+				sent, err := srv.WaitForSentMessages(1, 5*time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := sent[0].Text; !strings.Contains(got, tc.wantText) {
+					t.Errorf("reply text %q does not contain %q", got, tc.wantText)
+				}
+			},
+		)
+	}
+}
+
+func TestModule_DefaultConfig(t *testing.T) {
+	t.Parallel()
+
+	srv := tgbottest.Serve(t, func(c *tgbot.Config) { c.Modules = []string{faq.Name} }, nil)
+
+	srv.AddMessage(42, 42, "/faq")
+	sent, err := srv.WaitForSentMessages(1, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sent[0].Text, "Ответов пока нет."; got != want {
+		t.Errorf("reply text does not match, got = %q, want = %q", got, want)
+	}
+}
+
+func TestEntries_EnvDecode(t *testing.T) {
+	t.Parallel()
+
+	var e faq.Entries
+	if err := e.EnvDecode(`{"rules": "Be nice, please."}`); err != nil {
+		t.Fatal(err)
+	}
+	if want := (faq.Entries{"rules": "Be nice, please."}); !reflect.DeepEqual(e, want) {
+		t.Errorf("entries do not match, got = %v, want = %v", e, want)
+	}
+
+	if err := e.EnvDecode("rules"); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	t.Parallel()
+
+	// The files are read from the disk, Module.Migrations needs the pkger
+	// bundle of cmd/migrate.
+	src, err := httpfs.New(http.Dir("migrations"), "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.First(); err != nil {
+		t.Errorf("no migrations: %v", err)
+	}
+}
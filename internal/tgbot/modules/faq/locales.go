@@ -0,0 +1,41 @@
+package faq
+
+import (
+	"github.com/alienvspredator/simple-tgbot/internal/i18n"
+)
+
+// locales are the messages of the module.
+var locales = []*i18n.Locale{
+	{
+		Tag: "en",
+		Messages: map[string]i18n.Message{
+			"command.faq.description":    {Other: "Answers to the frequent questions"},
+			"command.faq.help":           {Other: "Without an argument lists the topics. A #topic message gets the answer too."},
+			"command.setfaq.description": {Other: "Set the answer on a topic"},
+			"command.unfaq.description":  {Other: "Delete the answer on a topic"},
+			"faq.topics":                 {Other: "Topics: {{.Topics}}"},
+			"faq.empty":                  {Other: "There are no answers yet."},
+			"faq.unknown":                {Other: "I have no answer on «{{.Topic}}». Send /faq for the topics."},
+			"faq.invalid_topic":          {Other: "A topic is one word of letters, digits and underscores."},
+			"faq.too_long":               {Other: "The answer is too long, the limit is {{.Limit}} characters."},
+			"faq.saved":                  {Other: "The answer on «{{.Topic}}» is saved."},
+			"faq.deleted":                {Other: "The answer on «{{.Topic}}» is deleted."},
+		},
+	},
+	{
+		Tag: "ru",
+		Messages: map[string]i18n.Message{
+			"command.faq.description":    {Other: "Ответы на частые вопросы"},
+			"command.faq.help":           {Other: "Без аргумента показывает список тем. Ответ можно получить и сообщением #тема."},
+			"command.setfaq.description": {Other: "Задать ответ по теме"},
+			"command.unfaq.description":  {Other: "Удалить ответ по теме"},
+			"faq.topics":                 {Other: "Темы: {{.Topics}}"},
+			"faq.empty":                  {Other: "Ответов пока нет."},
+			"faq.unknown":                {Other: "У меня нет ответа по теме «{{.Topic}}». Список тем — /faq."},
+			"faq.invalid_topic":          {Other: "Тема — одно слово из букв, цифр и подчёркиваний."},
+			"faq.too_long":               {Other: "Ответ слишком длинный, максимум {{.Limit}} символов."},
+			"faq.saved":                  {Other: "Ответ по теме «{{.Topic}}» сохранён."},
+			"faq.deleted":                {Other: "Ответ по теме «{{.Topic}}» удалён."},
+		},
+	},
+}
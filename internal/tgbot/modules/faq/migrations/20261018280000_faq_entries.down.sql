@@ -0,0 +1,3 @@
+BEGIN;
+DROP TABLE faq_entries;
+END;
\ No newline at end of file
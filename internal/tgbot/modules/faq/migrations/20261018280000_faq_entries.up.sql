@@ -0,0 +1,10 @@
+BEGIN;
+CREATE TABLE faq_entries (
+	bot_name   text        NOT NULL,
+	topic      text        NOT NULL,
+	answer     text        NOT NULL,
+	updated_by int8        NOT NULL DEFAULT 0,
+	updated_at timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (bot_name, topic)
+);
+END;
\ No newline at end of file
@@ -0,0 +1,9 @@
+// Package modules links the optional bot modules into the binaries. A module
+// registers itself with tgbot.RegisterModule on import and is enabled per
+// deployment with MODULES.
+package modules
+
+import (
+	// The optional modules.
+	_ "github.com/alienvspredator/simple-tgbot/internal/tgbot/modules/faq"
+)
@@ -0,0 +1,239 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	tgbotdb "github.com/alienvspredator/simple-tgbot/internal/tgbot/database"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"go.uber.org/zap"
+)
+
+const (
+	// recentUpdatesSize is how many received update IDs are kept in memory,
+	// so the redelivered updates are skipped even without the database.
+	recentUpdatesSize = 10000
+
+	// updateOffsetMaxAge is the age of the saved offset after which it is
+	// ignored: Telegram picks the update IDs at random after a week without
+	// updates.
+	updateOffsetMaxAge = 7 * 24 * time.Hour
+
+	// processedUpdatesCleanupInterval is how often the expired processed
+	// update IDs are deleted.
+	processedUpdatesCleanupInterval = time.Hour
+)
+
+// updateLog tracks the received updates. It skips the updates received
+// before and keeps the offset: the last update handled together with all
+// the earlier ones. With the database the offset is saved, so long polling
+// resumes after it on restart, and the handled update IDs are recorded, so
+// the updates redelivered after a restart or to another replica are not
+// handled twice.
+type updateLog struct {
+	db        *tgbotdb.TgBotDB
+	botID     int64
+	interval  time.Duration
+	retention time.Duration
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	mu sync.Mutex
+	// recent are the IDs of the last received updates, ring is their order
+	// of arrival and next is the ring slot to reuse.
+	recent map[int]struct{}
+	ring   []int
+	next   int
+	// pending are the IDs of the received updates not handled yet.
+	pending map[int]struct{}
+	// last is the greatest received update ID and saved is the last saved
+	// offset.
+	last  int
+	saved int
+}
+
+func newUpdateLog(config *Config) *updateLog {
+	return &updateLog{
+		interval:  config.UpdateOffsetInterval,
+		retention: config.ProcessedUpdatesRetention,
+		closing:   make(chan struct{}),
+		recent:    make(map[int]struct{}),
+		ring:      make([]int, 0, recentUpdatesSize),
+		pending:   make(map[int]struct{}),
+	}
+}
+
+// received records the update as waiting for the handling. It reports false
+// if the update was received recently.
+func (l *updateLog) received(id int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.recent[id]; ok {
+		return false
+	}
+	if len(l.ring) < recentUpdatesSize {
+		l.ring = append(l.ring, id)
+	} else {
+		delete(l.recent, l.ring[l.next])
+		l.ring[l.next] = id
+		l.next = (l.next + 1) % recentUpdatesSize
+	}
+	l.recent[id] = struct{}{}
+	l.pending[id] = struct{}{}
+	if id > l.last {
+		l.last = id
+	}
+	return true
+}
+
+// done records the update as handled.
+func (l *updateLog) done(id int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.pending, id)
+}
+
+// offset returns the last update handled together with all the earlier
+// ones, or 0 if there is none.
+func (l *updateLog) offset() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	offset := l.last
+	for id := range l.pending {
+		if id <= offset {
+			offset = id - 1
+		}
+	}
+	if offset < l.saved {
+		offset = l.saved
+	}
+	return offset
+}
+
+// claim records the update as processed in the database. It reports false
+// if the update was processed before. The update is claimed before it is
+// handled, so a crash in the middle of the handling loses it rather than
+// handles it twice.
+func (l *updateLog) claim(ctx context.Context, id int) bool {
+	if l.db == nil {
+		return true
+	}
+
+	claimed, err := l.db.ClaimUpdate(ctx, l.botID, int64(id))
+	if err != nil {
+		logging.FromContext(ctx).Errorw("claim update", "update_id", id, zap.Error(err))
+		return true
+	}
+	return claimed
+}
+
+// load reads the saved offset. The offset older than updateOffsetMaxAge is
+// ignored.
+func (l *updateLog) load(ctx context.Context) error {
+	o, err := l.db.GetUpdateOffset(ctx, l.botID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if time.Since(o.UpdatedAt) > updateOffsetMaxAge {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.saved = int(o.LastUpdateID)
+	if l.saved > l.last {
+		l.last = l.saved
+	}
+	return nil
+}
+
+// run saves the offset every interval and deletes the expired processed
+// update IDs. After close it saves the offset and returns.
+func (l *updateLog) run(ctx context.Context) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	var lastCleanup time.Time
+	for {
+		select {
+		case <-l.closing:
+			l.flush(ctx)
+			return
+		case now := <-ticker.C:
+			l.flush(ctx)
+
+			if l.retention > 0 && now.Sub(lastCleanup) >= processedUpdatesCleanupInterval {
+				lastCleanup = now
+				if err := l.db.DeleteProcessedUpdates(ctx, now.Add(-l.retention)); err != nil {
+					logging.FromContext(ctx).Errorw("delete processed updates", zap.Error(err))
+				}
+			}
+		}
+	}
+}
+
+// close makes run save the offset and return.
+func (l *updateLog) close() {
+	l.closeOnce.Do(func() { close(l.closing) })
+}
+
+// flush saves the offset if it moved.
+func (l *updateLog) flush(ctx context.Context) {
+	offset := l.offset()
+
+	l.mu.Lock()
+	saved := l.saved
+	l.mu.Unlock()
+	if offset <= saved {
+		return
+	}
+
+	if err := l.db.SetUpdateOffset(ctx, l.botID, int64(offset)); err != nil {
+		logging.FromContext(ctx).Errorw("save update offset", "offset", offset, zap.Error(err))
+		return
+	}
+
+	l.mu.Lock()
+	if offset > l.saved {
+		l.saved = offset
+	}
+	l.mu.Unlock()
+}
+
+// receiveUpdate queues the update for the workers. The updates received
+// recently are acknowledged without the handling.
+func (b *Bot) receiveUpdate(ctx context.Context, u *telegram.Update) error {
+	if !b.updateLog.received(u.UpdateID) {
+		metricsware.NewMiddleware().RecordDuplicateUpdate(ctx)
+		logging.FromContext(ctx).Infow("skipping a redelivered update", "update_id", u.UpdateID)
+		return nil
+	}
+
+	return b.workers.submit(ctx, u)
+}
+
+// handleUpdateOnce handles the update unless it was processed before, e.g.
+// by the previous run of the bot or by another replica.
+func (b *Bot) handleUpdateOnce(ctx context.Context, u *telegram.Update) {
+	defer b.updateLog.done(u.UpdateID)
+
+	if !b.updateLog.claim(ctx, u.UpdateID) {
+		metricsware.NewMiddleware().RecordDuplicateUpdate(ctx)
+		logging.FromContext(ctx).Infow("skipping a processed update", "update_id", u.UpdateID)
+		return
+	}
+
+	b.handleUpdate(ctx, u)
+}
@@ -0,0 +1,104 @@
+package tgbot
+
+import (
+	"testing"
+)
+
+func TestUpdateLog_Received(t *testing.T) {
+	t.Parallel()
+
+	l := newUpdateLog(&Config{})
+
+	if !l.received(1) {
+		t.Fatalf("the first update is skipped")
+	}
+	if l.received(1) {
+		t.Errorf("the redelivered update is not skipped")
+	}
+	l.done(1)
+	if l.received(1) {
+		t.Errorf("the handled update is not skipped")
+	}
+
+	for id := 2; id <= recentUpdatesSize+1; id++ {
+		l.received(id)
+	}
+	if !l.received(1) {
+		t.Errorf("the forgotten update is skipped")
+	}
+	if l.received(recentUpdatesSize + 1) {
+		t.Errorf("the recent update is not skipped")
+	}
+}
+
+func TestUpdateLog_Offset(t *testing.T) {
+	t.Parallel()
+
+	type step struct {
+		received []int
+		done     []int
+		want     int
+	}
+
+	tests := []struct {
+		name  string
+		saved int
+		steps []step
+	}{
+		{
+			name:  "nothing received",
+			steps: []step{{want: 0}},
+		},
+		{
+			name: "in order",
+			steps: []step{
+				{received: []int{10, 11, 12}, want: 9},
+				{done: []int{10}, want: 10},
+				{done: []int{11, 12}, want: 12},
+			},
+		},
+		{
+			name: "out of order",
+			steps: []step{
+				{received: []int{10, 11, 12}, done: []int{11, 12}, want: 9},
+				{received: []int{13}, done: []int{13}, want: 9},
+				{done: []int{10}, want: 13},
+			},
+		},
+		{
+			name:  "never behind the saved offset",
+			saved: 20,
+			steps: []step{
+				{received: []int{15}, want: 20},
+				{received: []int{21}, want: 20},
+				{done: []int{21}, want: 20},
+				{done: []int{15}, want: 21},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				l := newUpdateLog(&Config{})
+				l.saved, l.last = tc.saved, tc.saved
+
+				for i, s := range tc.steps {
+					for _, id := range s.received {
+						l.received(id)
+					}
+					for _, id := range s.done {
+						l.done(id)
+					}
+					if got := l.offset(); got != s.want {
+						t.Errorf("offset after step %d does not match, got = %d, want = %d", i, got, s.want)
+					}
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,142 @@
+package tgbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"go.uber.org/zap"
+)
+
+// outboxBatchSize is the number of messages the dispatcher claims at once.
+const outboxBatchSize = 100
+
+// send saves the message to the outbox and delivers it. The messages that
+// fail to be delivered are retried by the outbox dispatcher. Without a
+// database the message is only sent once. It returns the error of the first
+// delivery attempt.
+func (b *Bot) send(ctx context.Context, msg *model.OutboxMessage) error {
+	metricsware.NewMiddleware().RecordOutgoingMessage(ctx)
+
+	if b.db != nil {
+		// The dispatcher does not pick up the message while it is being
+		// delivered here.
+		msg.NextAttemptAt = time.Now().Add(b.config.OutboxLease)
+		if err := b.db.AddOutboxMessage(ctx, msg); err != nil {
+			logging.FromContext(ctx).Errorw("save outbox message", zap.Error(err))
+			msg.ID = 0
+		}
+	}
+
+	return b.deliver(ctx, msg)
+}
+
+// deliver sends the message and updates its outbox record: the delivered
+// messages are removed, the failed ones are scheduled for a retry or moved
+// to the dead letters. It returns the send error.
+func (b *Bot) deliver(ctx context.Context, msg *model.OutboxMessage) error {
+	log := logging.FromContext(ctx)
+	metricsMW := metricsware.NewMiddleware()
+
+	var opts []telegram.SendOption
+	if msg.ReplyToMessageID != 0 {
+		opts = append(opts, telegram.OptReplyToMessageID(int(msg.ReplyToMessageID)))
+	}
+	if msg.ReplyMarkup != "" {
+		opts = append(opts, telegram.OptReplyMarkup(json.RawMessage(msg.ReplyMarkup)))
+	}
+
+	answer, err := b.api.SendMessage(ctx, msg.ChatID, msg.Text, opts...)
+	if err == nil {
+		log.Infow("send answer", "answer:message_id", answer.MessageID, "text", answer.Text)
+		if msg.ID != 0 {
+			if err := b.db.DeleteOutboxMessage(ctx, msg.ID); err != nil {
+				log.Errorw("delete outbox message", "outbox_id", msg.ID, zap.Error(err))
+			}
+		}
+		return nil
+	}
+
+	metricsMW.RecordSendFailure(ctx)
+	log.Errorw("send answer", "outbox_id", msg.ID, "attempt", msg.Attempts+1, zap.Error(err))
+	if telegram.IsForbidden(err) {
+		b.markBlocked(ctx, msg.ChatID)
+	}
+	if msg.ID == 0 {
+		return err
+	}
+
+	if telegram.IsPermanent(err) || msg.Attempts+1 >= b.config.OutboxMaxAttempts {
+		metricsMW.RecordOutboxDeadLetter(ctx)
+		if err := b.db.DeadLetterOutboxMessage(ctx, msg.ID, err.Error()); err != nil {
+			log.Errorw("move outbox message to dead letters", "outbox_id", msg.ID, zap.Error(err))
+		}
+		return err
+	}
+
+	next := time.Now().Add(b.outboxBackoff(msg.Attempts, err))
+	if err := b.db.RetryOutboxMessage(ctx, msg.ID, next, err.Error()); err != nil {
+		log.Errorw("schedule outbox message retry", "outbox_id", msg.ID, zap.Error(err))
+	}
+	return err
+}
+
+// outboxBackoff returns the delay before the next delivery attempt. It grows
+// exponentially with the number of attempts, but is never shorter than the
+// delay requested by Telegram.
+func (b *Bot) outboxBackoff(attempts int, err error) time.Duration {
+	d := b.config.OutboxBackoff
+	for i := 0; i < attempts && d < b.config.OutboxMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > b.config.OutboxMaxBackoff {
+		d = b.config.OutboxMaxBackoff
+	}
+
+	var apiErr *telegram.Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > d {
+		d = apiErr.RetryAfter
+	}
+
+	return d
+}
+
+// dispatchOutbox delivers the due outbox messages until stop is closed. The
+// batch being delivered is finished unless the context is done.
+func (b *Bot) dispatchOutbox(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(b.config.OutboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.flushOutbox(ctx)
+		}
+	}
+}
+
+// flushOutbox claims the due outbox messages and delivers them.
+func (b *Bot) flushOutbox(ctx context.Context) {
+	log := logging.FromContext(ctx)
+
+	msgs, err := b.db.ClaimOutboxMessages(
+		ctx, outboxBatchSize, time.Now().Add(b.config.OutboxLease),
+	)
+	if err != nil {
+		log.Errorw("claim outbox messages", zap.Error(err))
+		return
+	}
+
+	for _, msg := range msgs {
+		b.deliver(logging.WithLogger(ctx, log.With("chat_id", msg.ChatID)), msg)
+	}
+}
@@ -0,0 +1,60 @@
+package tgbot
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+)
+
+func TestBot_outboxBackoff(t *testing.T) {
+	t.Parallel()
+
+	b := &Bot{config: &Config{OutboxBackoff: time.Second, OutboxMaxBackoff: time.Minute}}
+
+	tests := []struct {
+		name     string
+		attempts int
+		err      error
+		want     time.Duration
+	}{
+		{
+			name: "first retry",
+			err:  errors.New("timeout"),
+			want: time.Second,
+		},
+		{
+			name:     "exponential",
+			attempts: 3,
+			err:      errors.New("timeout"),
+			want:     8 * time.Second,
+		},
+		{
+			name:     "capped",
+			attempts: 20,
+			err:      errors.New("timeout"),
+			want:     time.Minute,
+		},
+		{
+			name: "retry after",
+			err:  &telegram.Error{Code: http.StatusTooManyRequests, RetryAfter: 30 * time.Second},
+			want: 30 * time.Second,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				if got := b.outboxBackoff(tc.attempts, tc.err); got != tc.want {
+					t.Errorf("backoff does not match, got = %v, want = %v", got, tc.want)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,288 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/i18n"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// jobTimeFormat is the format of the job times shown to the users.
+const jobTimeFormat = "2006-01-02 15:04 MST"
+
+// remindersModule handles the reminders and the scheduled posts.
+type remindersModule struct{}
+
+func (remindersModule) Name() string {
+	return moduleReminders
+}
+
+func (remindersModule) Attach(b *Bot, _ interface{}) error {
+	if b.db != nil {
+		b.AddWorker(b.dispatchJobs)
+	}
+
+	b.AddCommand(&Command{
+		Name:        "remind",
+		Description: "command.remind.description",
+		Help:        "command.remind.help",
+		Args:        []Arg{{Name: "when"}, {Name: "text", Rest: true}},
+		Run:         b.Remind,
+	})
+	b.AddCommand(&Command{
+		Name:        "reminders",
+		Description: "command.reminders.description",
+		Run:         b.Reminders,
+	})
+	b.AddCommand(&Command{
+		Name:        "unremind",
+		Description: "command.unremind.description",
+		Args:        []Arg{{Name: "id"}},
+		Run:         b.Unremind,
+	})
+	b.AddCommand(&Command{
+		Name:        "timezone",
+		Description: "command.timezone.description",
+		Help:        "command.timezone.help",
+		Args:        []Arg{{Name: "zone", Optional: true}},
+		Run:         b.TimeZone,
+	})
+	b.AddCommand(&Command{
+		Name:        "schedule",
+		Description: "command.schedule.description",
+		Help:        "command.schedule.help",
+		Args:        []Arg{{Name: "chat_id"}, {Name: "when"}, {Name: "text", Rest: true}},
+		Hidden:      true,
+		Role:        RoleAdmin,
+		Run:         b.SchedulePost,
+	})
+
+	return nil
+}
+
+// Remind schedules a reminder to the chat, see parseJobTime for the times.
+func (b *Bot) Remind(ctx context.Context, m *tbot.Message, args Args) {
+	loc := b.userTimeZone(ctx, m.From)
+
+	jt, text, ok := b.parseJobArgs(ctx, m, "remind", args, loc)
+	if !ok {
+		return
+	}
+
+	job := &model.ScheduledJob{
+		Kind:     jobReminder,
+		ChatID:   m.Chat.ID,
+		Text:     b.t(ctx, "remind.text", i18n.Params{"Text": text}),
+		Schedule: jt.schedule,
+		TimeZone: loc.String(),
+	}
+	if id, ok := b.addJob(ctx, m, job, jt); ok {
+		b.reply(ctx, m, b.t(ctx, "remind.created", i18n.Params{
+			"ID": id,
+			"At": jt.at.In(loc).Format(jobTimeFormat),
+		}))
+	}
+}
+
+// SchedulePost schedules a message to the chat.
+func (b *Bot) SchedulePost(ctx context.Context, m *tbot.Message, args Args) {
+	chatID := args.Get("chat_id")
+	if _, err := strconv.ParseInt(chatID, 10, 64); err != nil {
+		b.reply(ctx, m, b.t(ctx, "schedule.bad_chat", nil))
+		return
+	}
+
+	loc := b.userTimeZone(ctx, m.From)
+	jt, text, ok := b.parseJobArgs(ctx, m, "schedule", args, loc)
+	if !ok {
+		return
+	}
+
+	job := &model.ScheduledJob{
+		Kind:     jobPost,
+		ChatID:   chatID,
+		Text:     text,
+		Schedule: jt.schedule,
+		TimeZone: loc.String(),
+	}
+	if id, ok := b.addJob(ctx, m, job, jt); ok {
+		b.reply(ctx, m, b.t(ctx, "schedule.created", i18n.Params{
+			"ID": id,
+			"At": jt.at.In(loc).Format(jobTimeFormat),
+		}))
+	}
+}
+
+// parseJobArgs parses the time and the text of the job command. It replies
+// and reports false if they are invalid.
+func (b *Bot) parseJobArgs(
+	ctx context.Context, m *tbot.Message, command string, args Args, loc *time.Location,
+) (jobTime, string, bool) {
+	now := time.Now().In(loc)
+
+	jt, text, err := parseJobTime(args.Get("when")+" "+args.Get("text"), now)
+	if err != nil {
+		b.reply(ctx, m, b.t(ctx, "remind.bad_time", nil))
+		return jobTime{}, "", false
+	}
+	if text == "" {
+		cmd, _ := b.commands.lookup(command)
+		b.reply(ctx, m, b.t(ctx, "command.usage", i18n.Params{"Usage": cmd.Usage()}))
+		return jobTime{}, "", false
+	}
+	if !jt.at.After(now) {
+		b.reply(ctx, m, b.t(ctx, "remind.past", nil))
+		return jobTime{}, "", false
+	}
+
+	return jt, text, true
+}
+
+// addJob saves the job of the message author. The users without a role keep
+// up to MaxJobsPerUser jobs. It replies and reports false on a failure.
+func (b *Bot) addJob(ctx context.Context, m *tbot.Message, job *model.ScheduledJob, jt jobTime) (int64, bool) {
+	log := logging.FromContext(ctx)
+
+	if b.db == nil || m.From == nil {
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return 0, false
+	}
+	job.CreatedBy = int64(m.From.ID)
+	job.NextRunAt = jt.at
+
+	if b.config.MaxJobsPerUser > 0 && b.userRole(ctx, m.From) == RoleNone {
+		jobs, err := b.db.ListScheduledJobs(ctx, job.CreatedBy)
+		if err != nil {
+			log.Errorw("list scheduled jobs", zap.Error(err))
+			b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+			return 0, false
+		}
+		if len(jobs) >= b.config.MaxJobsPerUser {
+			b.reply(ctx, m, b.t(ctx, "remind.limit", nil))
+			return 0, false
+		}
+	}
+
+	if err := b.db.AddScheduledJob(ctx, job); err != nil {
+		log.Errorw("add scheduled job", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return 0, false
+	}
+	log.Infow(
+		"job scheduled", "job_id", job.ID, "kind", job.Kind, "target_chat_id", job.ChatID,
+		"next_run_at", job.NextRunAt, "schedule", job.Schedule,
+	)
+
+	return job.ID, true
+}
+
+// Reminders lists the jobs scheduled by the user.
+func (b *Bot) Reminders(ctx context.Context, m *tbot.Message, _ Args) {
+	if b.db == nil || m.From == nil {
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+
+	jobs, err := b.db.ListScheduledJobs(ctx, int64(m.From.ID))
+	if err != nil {
+		logging.FromContext(ctx).Errorw("list scheduled jobs", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	if len(jobs) == 0 {
+		b.reply(ctx, m, b.t(ctx, "reminders.empty", nil))
+		return
+	}
+
+	loc := b.userTimeZone(ctx, m.From)
+	lines := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		params := i18n.Params{
+			"ID":       job.ID,
+			"At":       job.NextRunAt.In(loc).Format(jobTimeFormat),
+			"Schedule": job.Schedule,
+			"Text":     job.Text,
+		}
+		if job.Schedule != "" {
+			lines = append(lines, b.t(ctx, "reminders.recurring", params))
+		} else {
+			lines = append(lines, b.t(ctx, "reminders.item", params))
+		}
+	}
+	b.reply(ctx, m, strings.Join(lines, "\n"))
+}
+
+// Unremind deletes the job. The users delete their own jobs, the admins
+// delete any.
+func (b *Bot) Unremind(ctx context.Context, m *tbot.Message, args Args) {
+	log := logging.FromContext(ctx)
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(args.Get("id"), "#"), 10, 64)
+	if err != nil {
+		cmd, _ := b.commands.lookup("unremind")
+		b.reply(ctx, m, b.t(ctx, "command.usage", i18n.Params{"Usage": cmd.Usage()}))
+		return
+	}
+	if b.db == nil || m.From == nil {
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+
+	job, err := b.db.GetScheduledJob(ctx, id)
+	if err == nil && job.CreatedBy != int64(m.From.ID) && b.userRole(ctx, m.From) < RoleAdmin {
+		err = database.ErrNotFound
+	}
+	if err == nil {
+		err = b.db.DeleteScheduledJob(ctx, id)
+	}
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		b.reply(ctx, m, b.t(ctx, "remind.not_found", nil))
+		return
+	case err != nil:
+		log.Errorw("delete scheduled job", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	log.Infow("job deleted", "job_id", id)
+
+	b.reply(ctx, m, b.t(ctx, "remind.deleted", i18n.Params{"ID": id}))
+}
+
+// TimeZone shows or changes the time zone of the user.
+func (b *Bot) TimeZone(ctx context.Context, m *tbot.Message, args Args) {
+	name := args.Get("zone")
+	if name == "" {
+		b.reply(ctx, m, b.t(ctx, "timezone.current", i18n.Params{"TimeZone": b.userTimeZone(ctx, m.From).String()}))
+		return
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		b.reply(ctx, m, b.t(ctx, "timezone.unknown", nil))
+		return
+	}
+
+	if b.db == nil || m.From == nil {
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	if err := b.db.SetUserTimeZone(ctx, int64(m.From.ID), loc.String()); err != nil {
+		logging.FromContext(ctx).Errorw("set user time zone", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+	if err := b.timeZoneCache.Set(strconv.Itoa(m.From.ID), loc.String()); err != nil {
+		logging.FromContext(ctx).Errorw("cache user time zone", zap.Error(err))
+	}
+
+	b.reply(ctx, m, b.t(ctx, "timezone.changed", i18n.Params{"TimeZone": loc.String()}))
+}
@@ -0,0 +1,153 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// Role is the operator role of a user. A higher role can do everything a
+// lower one can.
+type Role int
+
+// The operator roles from the lowest to the highest.
+const (
+	RoleNone Role = iota
+	RoleModerator
+	RoleAdmin
+	RoleOwner
+)
+
+var roleNames = map[Role]string{
+	RoleNone:      "none",
+	RoleModerator: "moderator",
+	RoleAdmin:     "admin",
+	RoleOwner:     "owner",
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// parseRole returns the role with the name. It reports false for an unknown
+// name and for "none".
+func parseRole(name string) (Role, bool) {
+	for r, n := range roleNames {
+		if n == name && r != RoleNone {
+			return r, true
+		}
+	}
+	return RoleNone, false
+}
+
+// mayManage reports whether the operator with the role may grant or take
+// away the target role. The owners manage the admins, the others manage the
+// roles below their own. The owner role is given only by the configuration.
+func mayManage(actor, target Role) bool {
+	return target < RoleOwner && (actor == RoleOwner || target < actor)
+}
+
+// isConfigOwner reports whether the user is an owner from the configuration.
+// They are the only owners, so removing the user from the configuration
+// takes the role away. Their role can not be changed with the commands.
+func (b *Bot) isConfigOwner(userID int64) bool {
+	for _, id := range b.config.OwnerIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// userRole returns the role of the user. The users without a role and all
+// the users but the configured owners without the database have RoleNone.
+func (b *Bot) userRole(ctx context.Context, user *tbot.User) Role {
+	if user == nil {
+		return RoleNone
+	}
+	return b.roleOf(ctx, int64(user.ID))
+}
+
+func (b *Bot) roleOf(ctx context.Context, userID int64) Role {
+	if b.isConfigOwner(userID) {
+		return RoleOwner
+	}
+	if b.db == nil {
+		return RoleNone
+	}
+
+	v, err := b.roleCache.WriteThruLookup(
+		strconv.FormatInt(userID, 10), func() (interface{}, error) {
+			r, err := b.db.GetUserRole(ctx, userID)
+			if errors.Is(err, database.ErrNotFound) {
+				return RoleNone, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			role, _ := parseRole(r.Role)
+			if role == RoleOwner {
+				// The owner role is not taken from the database, only from
+				// the configuration.
+				return RoleNone, nil
+			}
+			return role, nil
+		},
+	)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("get user role", zap.Error(err))
+		return RoleNone
+	}
+
+	return v.(Role)
+}
+
+// setRole saves the role of the user. RoleNone takes the role away.
+func (b *Bot) setRole(ctx context.Context, userID int64, role Role, grantedBy int64) error {
+	if b.db == nil {
+		return errNoDatabase
+	}
+
+	var err error
+	if role == RoleNone {
+		err = b.db.DeleteUserRole(ctx, userID)
+	} else {
+		err = b.db.SetUserRole(ctx, &model.UserRole{UserID: userID, Role: role.String(), GrantedBy: grantedBy})
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := b.roleCache.Set(strconv.FormatInt(userID, 10), role); err != nil {
+		logging.FromContext(ctx).Errorw("cache user role", zap.Error(err))
+	}
+	return nil
+}
+
+// authorized reports whether the author of the message may run the command.
+// The rejected attempts are logged and counted and answered like an unknown
+// command, so the operator commands are not revealed.
+func (b *Bot) authorized(ctx context.Context, m *tbot.Message, cmd *Command) bool {
+	if cmd.Role == RoleNone || b.userRole(ctx, m.From) >= cmd.Role {
+		return true
+	}
+
+	var userID int
+	if m.From != nil {
+		userID = m.From.ID
+	}
+	metricsware.NewMiddleware().RecordUnauthorizedCommand(ctx)
+	logging.FromContext(ctx).Warnw(
+		"unauthorized command", "command", cmd.Name, "user_id", userID, "required_role", cmd.Role.String(),
+	)
+
+	b.unknownCommand(ctx, m, commandCall{name: cmd.Name})
+	return false
+}
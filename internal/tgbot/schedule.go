@@ -0,0 +1,291 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// The time zones of the users are loaded without the system database.
+	_ "time/tzdata"
+
+	"github.com/alienvspredator/simple-tgbot/internal/cron"
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// The kinds of the scheduled jobs.
+const (
+	jobReminder = "reminder"
+	jobPost     = "post"
+)
+
+// defaultJobHour is the hour of the jobs scheduled for a day without the
+// time, e.g. "tomorrow".
+const defaultJobHour = 9
+
+// errBadJobTime is returned for the job times that can not be parsed.
+var errBadJobTime = errors.New("bad job time")
+
+// weekdays maps the day names accepted after "every" to the cron days.
+var weekdays = map[string]string{
+	"day":      "*",
+	"weekday":  "1-5",
+	"weekdays": "1-5",
+	"weekend":  "0,6",
+	"mon":      "1", "monday": "1",
+	"tue": "2", "tuesday": "2",
+	"wed": "3", "wednesday": "3",
+	"thu": "4", "thursday": "4",
+	"fri": "5", "friday": "5",
+	"sat": "6", "saturday": "6",
+	"sun": "0", "sunday": "0",
+}
+
+// jobTime is the time of a job parsed from a command.
+type jobTime struct {
+	// at is the first run.
+	at time.Time
+	// schedule is the cron expression of a recurring job.
+	schedule string
+}
+
+// parseJobTime parses the time at the start of the text and returns the rest
+// of it. The times are relative to now and in its location:
+//
+//	2h, 1h30m, 3d           after the period
+//	9:00                    today, or tomorrow if it has passed
+//	today 18:00
+//	tomorrow [9:00]
+//	2026-12-31 [18:00]
+//	every <day> 9:00        day, weekday, weekend or a day of the week
+//	cron <expression>       the five fields or a macro, e.g. @daily
+func parseJobTime(text string, now time.Time) (jobTime, string, error) {
+	fields, _ := cutFields(text, 1)
+	if len(fields) == 0 {
+		return jobTime{}, "", errBadJobTime
+	}
+	loc := now.Location()
+
+	var (
+		jt           jobTime
+		n            int
+		hour, minute int
+	)
+	switch first := strings.ToLower(fields[0]); {
+	case first == "today" || first == "tomorrow":
+		day := now
+		if first == "tomorrow" {
+			day = now.AddDate(0, 0, 1)
+		}
+		hour, minute, n = defaultJobHour, 0, 1
+		if fields, _ = cutFields(text, 2); len(fields) == 2 {
+			if h, m, ok := parseClock(fields[1]); ok {
+				hour, minute, n = h, m, 2
+			}
+		}
+		if first == "today" && n == 1 {
+			return jobTime{}, "", errBadJobTime
+		}
+		jt.at = time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+
+	case first == "every":
+		fields, _ = cutFields(text, 3)
+		if len(fields) < 3 {
+			return jobTime{}, "", errBadJobTime
+		}
+		dow := weekdays[strings.ToLower(fields[1])]
+		var ok bool
+		if hour, minute, ok = parseClock(fields[2]); !ok || dow == "" {
+			return jobTime{}, "", errBadJobTime
+		}
+		jt.schedule, n = fmt.Sprintf("%d %d * * %s", minute, hour, dow), 3
+
+	case first == "cron":
+		fields, _ = cutFields(text, 6)
+		switch {
+		case len(fields) >= 2 && strings.HasPrefix(fields[1], "@"):
+			jt.schedule, n = strings.ToLower(fields[1]), 2
+		case len(fields) == 6:
+			jt.schedule, n = strings.Join(fields[1:], " "), 6
+		default:
+			return jobTime{}, "", errBadJobTime
+		}
+
+	default:
+		var ok bool
+		if d, err := parsePeriod(first); err == nil {
+			jt.at, n = now.Add(d), 1
+			break
+		}
+		if hour, minute, ok = parseClock(first); ok {
+			jt.at, n = time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, loc), 1
+			if !jt.at.After(now) {
+				jt.at = jt.at.AddDate(0, 0, 1)
+			}
+			break
+		}
+		day, err := time.ParseInLocation("2006-01-02", first, loc)
+		if err != nil {
+			return jobTime{}, "", errBadJobTime
+		}
+		hour, minute, n = defaultJobHour, 0, 1
+		if fields, _ = cutFields(text, 2); len(fields) == 2 {
+			if h, m, ok := parseClock(fields[1]); ok {
+				hour, minute, n = h, m, 2
+			}
+		}
+		jt.at = time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+	}
+
+	if jt.schedule != "" {
+		s, err := cron.Parse(jt.schedule)
+		if err != nil {
+			return jobTime{}, "", fmt.Errorf("%w: %v", errBadJobTime, err)
+		}
+		if jt.at = s.Next(now); jt.at.IsZero() {
+			return jobTime{}, "", errBadJobTime
+		}
+	}
+
+	_, rest := cutFields(text, n)
+	return jt, rest, nil
+}
+
+// parseClock parses the time of day in the "15:04" form.
+func parseClock(s string) (hour, minute int, ok bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, false
+	}
+	return t.Hour(), t.Minute(), true
+}
+
+// cutFields returns up to n first fields of the text separated by the white
+// space and the text after them as is.
+func cutFields(text string, n int) ([]string, string) {
+	var fields []string
+	rest := strings.TrimSpace(text)
+	for len(fields) < n && rest != "" {
+		i := strings.IndexAny(rest, " \n\t")
+		if i < 0 {
+			fields, rest = append(fields, rest), ""
+			break
+		}
+		fields, rest = append(fields, rest[:i]), strings.TrimSpace(rest[i+1:])
+	}
+	return fields, rest
+}
+
+// loadLocation returns the time zone with the name. The zone from the
+// configuration and then UTC are used for an unknown name.
+func (b *Bot) loadLocation(name string) *time.Location {
+	for _, n := range []string{name, b.config.DefaultTimeZone} {
+		if n == "" {
+			continue
+		}
+		if loc, err := time.LoadLocation(n); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// userTimeZone returns the time zone of the user: the one chosen with
+// /timezone or the default one.
+func (b *Bot) userTimeZone(ctx context.Context, user *tbot.User) *time.Location {
+	if user == nil || b.db == nil {
+		return b.loadLocation("")
+	}
+
+	v, err := b.timeZoneCache.WriteThruLookup(
+		strconv.Itoa(user.ID), func() (interface{}, error) {
+			tz, err := b.db.GetUserTimeZone(ctx, int64(user.ID))
+			if errors.Is(err, database.ErrNotFound) {
+				return "", nil
+			}
+			return tz, err
+		},
+	)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("get user time zone", zap.Error(err))
+		return b.loadLocation("")
+	}
+
+	return b.loadLocation(v.(string))
+}
+
+// dispatchJobs runs the due scheduled jobs until stop is closed.
+func (b *Bot) dispatchJobs(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(b.config.SchedulerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.flushJobs(ctx)
+		}
+	}
+}
+
+// flushJobs claims the due jobs and runs them.
+func (b *Bot) flushJobs(ctx context.Context) {
+	log := logging.FromContext(ctx)
+
+	jobs, err := b.db.ClaimScheduledJobs(
+		ctx, b.config.SchedulerBatchSize, time.Now().Add(b.config.SchedulerLease),
+	)
+	if err != nil {
+		log.Errorw("claim scheduled jobs", zap.Error(err))
+		return
+	}
+
+	for _, job := range jobs {
+		b.runJob(logging.WithLogger(ctx, log.With("job_id", job.ID, "chat_id", job.ChatID)), job)
+	}
+}
+
+// runJob sends the job message through the outbox, so the failed deliveries
+// are retried, and schedules the next run of a recurring job. The one-time
+// jobs are deleted, and so are the recurring ones that can never be
+// delivered, e.g. to a chat the bot was removed from. If the bot stops
+// before the job is updated, the job runs again after the lease.
+func (b *Bot) runJob(ctx context.Context, job *model.ScheduledJob) {
+	log := logging.FromContext(ctx)
+
+	metricsware.NewMiddleware().RecordScheduledJobRun(ctx)
+	err := b.send(ctx, &model.OutboxMessage{ChatID: job.ChatID, Text: job.Text})
+
+	var next time.Time
+	if telegram.IsPermanent(err) {
+		log.Warnw("scheduled job can not be delivered, deleting it", zap.Error(err))
+	} else if job.Schedule != "" {
+		s, err := cron.Parse(job.Schedule)
+		if err != nil {
+			log.Errorw("parse job schedule", "schedule", job.Schedule, zap.Error(err))
+		} else {
+			next = s.Next(time.Now().In(b.loadLocation(job.TimeZone)))
+		}
+	}
+
+	if next.IsZero() {
+		if err := b.db.DeleteScheduledJob(ctx, job.ID); err != nil && !errors.Is(err, database.ErrNotFound) {
+			log.Errorw("delete scheduled job", zap.Error(err))
+		}
+		return
+	}
+	if err := b.db.RescheduleJob(ctx, job.ID, next); err != nil {
+		log.Errorw("reschedule job", zap.Error(err))
+	}
+}
@@ -0,0 +1,140 @@
+package tgbot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseJobTime(t *testing.T) {
+	t.Parallel()
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sunday.
+	now := time.Date(2026, 10, 18, 12, 30, 0, 0, loc)
+
+	tests := []struct {
+		name         string
+		text         string
+		wantAt       time.Time
+		wantSchedule string
+		wantRest     string
+		wantErr      bool
+	}{
+		{
+			name:     "period",
+			text:     "2h call mom",
+			wantAt:   now.Add(2 * time.Hour),
+			wantRest: "call mom",
+		},
+		{
+			name:     "days",
+			text:     "3d pay rent",
+			wantAt:   now.Add(72 * time.Hour),
+			wantRest: "pay rent",
+		},
+		{
+			name:     "clock later today",
+			text:     "18:00 dinner",
+			wantAt:   time.Date(2026, 10, 18, 18, 0, 0, 0, loc),
+			wantRest: "dinner",
+		},
+		{
+			name:     "clock passed",
+			text:     "9:00 standup",
+			wantAt:   time.Date(2026, 10, 19, 9, 0, 0, 0, loc),
+			wantRest: "standup",
+		},
+		{
+			name:     "tomorrow",
+			text:     "tomorrow 9:00 standup\nin the big room",
+			wantAt:   time.Date(2026, 10, 19, 9, 0, 0, 0, loc),
+			wantRest: "standup\nin the big room",
+		},
+		{
+			name:     "tomorrow without time",
+			text:     "Tomorrow standup",
+			wantAt:   time.Date(2026, 10, 19, defaultJobHour, 0, 0, 0, loc),
+			wantRest: "standup",
+		},
+		{
+			name:     "date",
+			text:     "2026-12-31 23:30 happy new year",
+			wantAt:   time.Date(2026, 12, 31, 23, 30, 0, 0, loc),
+			wantRest: "happy new year",
+		},
+		{
+			name:         "every weekday",
+			text:         "every weekday 9:15 standup",
+			wantAt:       time.Date(2026, 10, 19, 9, 15, 0, 0, loc),
+			wantSchedule: "15 9 * * 1-5",
+			wantRest:     "standup",
+		},
+		{
+			name:         "cron",
+			text:         "cron 0 20 * * fri beer",
+			wantAt:       time.Date(2026, 10, 23, 20, 0, 0, 0, loc),
+			wantSchedule: "0 20 * * fri",
+			wantRest:     "beer",
+		},
+		{
+			name:         "cron macro",
+			text:         "cron @daily backup",
+			wantAt:       time.Date(2026, 10, 19, 0, 0, 0, 0, loc),
+			wantSchedule: "@daily",
+			wantRest:     "backup",
+		},
+		{
+			name:    "today without time",
+			text:    "today standup",
+			wantErr: true,
+		},
+		{
+			name:    "unknown day",
+			text:    "every someday 9:00 standup",
+			wantErr: true,
+		},
+		{
+			name:    "bad cron",
+			text:    "cron 0 25 * * * standup",
+			wantErr: true,
+		},
+		{
+			name:    "not a time",
+			text:    "soon standup",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			jt, rest, err := parseJobTime(tc.text, now)
+			if tc.wantErr {
+				if !errors.Is(err, errBadJobTime) {
+					t.Fatalf("error does not match, got = %v, want = %v", err, errBadJobTime)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !jt.at.Equal(tc.wantAt) {
+				t.Errorf("time does not match, got = %s, want = %s", jt.at, tc.wantAt)
+			}
+			if jt.schedule != tc.wantSchedule {
+				t.Errorf("schedule does not match, got = %q, want = %q", jt.schedule, tc.wantSchedule)
+			}
+			if rest != tc.wantRest {
+				t.Errorf("rest does not match, got = %q, want = %q", rest, tc.wantRest)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package tgbot
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+)
+
+// canceledWorkTimeout limits the wait for the work canceled after the
+// shutdown timeout, e.g. the last flushes failing with the canceled context.
+const canceledWorkTimeout = 5 * time.Second
+
+// shutdown waits for the workers to handle the received updates and for the
+// background jobs to stop. After the shutdown timeout the remaining work is
+// canceled with cancel and given canceledWorkTimeout to stop, so it is done
+// when the caller releases the resources, e.g. closes the database.
+func (b *Bot) shutdown(ctx context.Context, cancel context.CancelFunc, background *sync.WaitGroup) {
+	log := logging.FromContext(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		b.workers.stop()
+		// The users seen in the last updates are saved once they are handled.
+		if b.users != nil {
+			b.users.close()
+		}
+		if b.flood != nil {
+			b.flood.close()
+		}
+		// The offset is saved once the received updates are handled.
+		if b.updateLog != nil {
+			b.updateLog.close()
+		}
+		background.Wait()
+		close(done)
+	}()
+
+	log.Infow("waiting for the received updates", "pending_updates", b.workers.pending())
+
+	timer := time.NewTimer(b.config.ShutdownTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		log.Info("the received updates are handled")
+	case <-timer.C:
+		abandoned := b.workers.pending()
+		metricsware.NewMiddleware().RecordAbandonedUpdates(ctx, abandoned)
+		log.Warnw("shutdown timed out, abandoning the unfinished work", "abandoned_updates", abandoned)
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(canceledWorkTimeout):
+			log.Error("the canceled work did not stop")
+		}
+	}
+}
+
+// detachedContext carries the values of the parent context, but is never
+// canceled and has no deadline.
+type detachedContext struct {
+	parent context.Context
+}
+
+// detach returns the context with the values of ctx that is not canceled
+// with it.
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
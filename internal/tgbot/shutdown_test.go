@@ -0,0 +1,100 @@
+package tgbot
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+)
+
+func TestBot_shutdown(t *testing.T) {
+	t.Parallel()
+
+	t.Run(
+		"drained", func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var handled int
+			b := &Bot{config: &Config{ShutdownTimeout: 5 * time.Second}}
+			b.workers = newWorkerPool(ctx, 1, 10, func(ctx context.Context, u *telegram.Update) {
+				time.Sleep(10 * time.Millisecond)
+				handled++
+			})
+			for i := 0; i < 3; i++ {
+				if err := b.workers.submit(ctx, chatUpdate(i, "1")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			b.shutdown(ctx, cancel, &sync.WaitGroup{})
+
+			if handled != 3 {
+				t.Errorf("number of handled updates does not match, got = %d, want = 3", handled)
+			}
+			if ctx.Err() != nil {
+				t.Error("handlers were canceled")
+			}
+		},
+	)
+
+	t.Run(
+		"timed out", func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			b := &Bot{config: &Config{ShutdownTimeout: 50 * time.Millisecond}}
+			b.workers = newWorkerPool(ctx, 1, 10, func(ctx context.Context, u *telegram.Update) {
+				<-ctx.Done()
+			})
+			for i := 0; i < 3; i++ {
+				if err := b.workers.submit(ctx, chatUpdate(i, "1")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// The background job finishes its work once canceled.
+			var background sync.WaitGroup
+			var stopped int32
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				<-ctx.Done()
+				time.Sleep(20 * time.Millisecond)
+				atomic.StoreInt32(&stopped, 1)
+			}()
+
+			b.shutdown(ctx, cancel, &background)
+
+			if ctx.Err() == nil {
+				t.Error("handlers were not canceled")
+			}
+			if atomic.LoadInt32(&stopped) == 0 {
+				t.Error("shutdown returned before the canceled work stopped")
+			}
+		},
+	)
+}
+
+func TestDetach(t *testing.T) {
+	t.Parallel()
+
+	type key struct{}
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	cancel()
+
+	ctx := detach(parent)
+	if ctx.Err() != nil {
+		t.Errorf("detached context is canceled: %v", ctx.Err())
+	}
+	if got := ctx.Value(key{}); got != "value" {
+		t.Errorf("value does not match, got = %v, want = value", got)
+	}
+}
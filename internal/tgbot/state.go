@@ -0,0 +1,107 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/database"
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// errNoDatabase is returned by the operations that need the database when
+// the bot runs without one.
+var errNoDatabase = errors.New("no database configured")
+
+// StateFunc handles a message in a chat that is in the state.
+type StateFunc func(ctx context.Context, m *tbot.Message, state *model.ChatState)
+
+// HandleState registers the handler for the messages in chats that are in
+// the named state. It must be called before Serve.
+func (b *Bot) HandleState(name string, f StateFunc) {
+	if _, ok := b.stateHandlers[name]; ok {
+		panic(fmt.Sprintf("state %q registered twice", name))
+	}
+	b.stateHandlers[name] = f
+}
+
+// SetState moves the chat to the named state with the attached data. The
+// next messages in the chat are routed to the state handler until the state
+// is changed, cleared or expires.
+func (b *Bot) SetState(ctx context.Context, chatID, name string, data map[string]string) error {
+	if _, ok := b.stateHandlers[name]; !ok {
+		return fmt.Errorf("unknown state %q", name)
+	}
+	if b.db == nil {
+		return errNoDatabase
+	}
+
+	return b.db.SetChatState(
+		ctx, &model.ChatState{
+			ChatID:    chatID,
+			State:     name,
+			Data:      data,
+			ExpiresAt: time.Now().Add(b.config.ChatStateTTL),
+		},
+	)
+}
+
+// ClearState returns the chat to the default handlers.
+func (b *Bot) ClearState(ctx context.Context, chatID string) error {
+	if b.db == nil {
+		return errNoDatabase
+	}
+	return b.db.DeleteChatState(ctx, chatID)
+}
+
+// withState routes the message to the handler of the chat state. Messages
+// in the chats without a state are passed to next.
+func (b *Bot) withState(next Handler) Handler {
+	return func(ctx context.Context, u *telegram.Update) {
+		// No dialogs are registered, so there is no state to look up.
+		if len(b.stateHandlers) == 0 || b.db == nil {
+			next(ctx, u)
+			return
+		}
+
+		log := logging.FromContext(ctx)
+		m := u.Message
+
+		state, err := b.db.GetChatState(ctx, m.Chat.ID)
+		if err != nil {
+			if !errors.Is(err, database.ErrNotFound) {
+				log.Errorw("get chat state", zap.Error(err))
+			}
+			next(ctx, u)
+			return
+		}
+
+		f, ok := b.stateHandlers[state.State]
+		if !ok {
+			log.Warnw("chat is in unknown state", "state", state.State)
+			if err := b.ClearState(ctx, m.Chat.ID); err != nil {
+				log.Errorw("clear chat state", zap.Error(err))
+			}
+			next(ctx, u)
+			return
+		}
+
+		f(logging.WithLogger(ctx, log.With("state", state.State)), m, state)
+	}
+}
+
+// Cancel leaves the current dialog.
+func (b *Bot) Cancel(ctx context.Context, m *tbot.Message, _ Args) {
+	if err := b.ClearState(ctx, m.Chat.ID); err != nil {
+		logging.FromContext(ctx).Errorw("clear chat state", zap.Error(err))
+		b.reply(ctx, m, b.t(ctx, "error.try_again", nil))
+		return
+	}
+
+	b.reply(ctx, m, b.t(ctx, "state.cancelled", nil))
+}
@@ -0,0 +1,489 @@
+// Package telegram is a thin client for the Telegram Bot API methods the bot
+// uses. The API types are shared with the tbot package.
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yanzay/tbot/v2"
+)
+
+// DefaultBaseURL is the address of the public Telegram Bot API.
+const DefaultBaseURL = "https://api.telegram.org"
+
+// API is the set of the Telegram Bot API operations the bot uses.
+type API interface {
+	GetMe(ctx context.Context) (*tbot.User, error)
+	GetUpdates(ctx context.Context, offset int, timeout time.Duration) ([]*Update, error)
+	SetWebhook(ctx context.Context, webhookURL, secretToken string) error
+	DeleteWebhook(ctx context.Context) error
+	SetMyCommands(ctx context.Context, commands []tbot.BotCommand, languageCode string) error
+	SendMessage(ctx context.Context, chatID, text string, opts ...SendOption) (*tbot.Message, error)
+	SendFile(ctx context.Context, chatID string, kind FileKind, fileID, caption string, opts ...SendOption) (*tbot.Message, error)
+	SendChatAction(ctx context.Context, chatID string, action ChatAction) error
+	EditMessageText(ctx context.Context, chatID string, messageID int, text string, opts ...SendOption) (*tbot.Message, error)
+	AnswerCallbackQuery(ctx context.Context, callbackQueryID, text string, showAlert bool) error
+	AnswerInlineQuery(ctx context.Context, inlineQueryID string, answer *InlineAnswer) error
+	GetFile(ctx context.Context, fileID string) (*tbot.File, error)
+	GetChatMember(ctx context.Context, chatID string, userID int) (*tbot.ChatMember, error)
+	DownloadFile(ctx context.Context, filePath string, maxSize int64) ([]byte, error)
+}
+
+var _ API = (*Client)(nil)
+
+// Client calls the Telegram Bot API.
+type Client struct {
+	token      string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// Option defines function types to modify the Client on creation.
+type Option func(c *Client) *Client
+
+// WithBaseURL sets the Bot API address. It is useful for local Bot API
+// servers and tests. An empty baseURL keeps the default.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) *Client {
+		if baseURL != "" {
+			c.baseURL = strings.TrimSuffix(baseURL, "/")
+		}
+		return c
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for the API calls.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) *Client {
+		c.httpClient = httpClient
+		return c
+	}
+}
+
+// New creates a new Client for the bot with the given token.
+func New(token string, opts ...Option) *Client {
+	c := &Client{
+		token:      token,
+		baseURL:    DefaultBaseURL,
+		httpClient: http.DefaultClient,
+	}
+
+	for _, f := range opts {
+		c = f(c)
+	}
+
+	return c
+}
+
+// ErrFileTooLarge is returned when the downloaded file exceeds the size
+// limit.
+var ErrFileTooLarge = errors.New("telegram: file is too large")
+
+// Error is returned when the Bot API answers with an unsuccessful response.
+type Error struct {
+	Code        int
+	Description string
+	// RetryAfter is set when the request was rejected because of flood
+	// control.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("telegram: %d %s", e.Code, e.Description)
+}
+
+// IsPermanent reports whether the request failed in a way that resending it
+// cannot fix, e.g. the bot was blocked by the user or the chat does not
+// exist. Network errors, flood control and server errors are temporary.
+func IsPermanent(err error) bool {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == http.StatusBadRequest || apiErr.Code == http.StatusForbidden
+}
+
+// IsForbidden reports whether the bot may not write to the chat, e.g. the user
+// blocked the bot or the bot was kicked from the group.
+func IsForbidden(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden
+}
+
+type response struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	Description string          `json:"description"`
+	ErrorCode   int             `json:"error_code"`
+	Parameters  *struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// redactURL replaces the request URL in the error with name, as the URL
+// contains the bot token and the errors are logged.
+func redactURL(err error, name string) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	redacted := *urlErr
+	redacted.URL = name
+	return &redacted
+}
+
+// do calls the Bot API method with the given params and decodes the result
+// into result, if it is not nil.
+func (c *Client) do(ctx context.Context, method string, params url.Values, result interface{}) error {
+	endpoint := fmt.Sprintf("%s/bot%s/%s", c.baseURL, c.token, method)
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, endpoint, strings.NewReader(params.Encode()),
+	)
+	if err != nil {
+		return fmt.Errorf("create %s request: %w", method, redactURL(err, method))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("call %s: %w", method, redactURL(err, method))
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("decode %s response: %w", method, err)
+	}
+
+	if !r.OK {
+		apiErr := &Error{Code: r.ErrorCode, Description: r.Description}
+		if r.Parameters != nil {
+			apiErr.RetryAfter = time.Duration(r.Parameters.RetryAfter) * time.Second
+		}
+		return apiErr
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(r.Result, result); err != nil {
+		return fmt.Errorf("decode %s result: %w", method, err)
+	}
+
+	return nil
+}
+
+// SetWebhook registers the URL Telegram delivers updates to. If secretToken
+// is not empty, Telegram sends it in the X-Telegram-Bot-Api-Secret-Token
+// header of every request.
+func (c *Client) SetWebhook(ctx context.Context, webhookURL, secretToken string) error {
+	params := url.Values{}
+	params.Set("url", webhookURL)
+	if secretToken != "" {
+		params.Set("secret_token", secretToken)
+	}
+
+	return c.do(ctx, "setWebhook", params, nil)
+}
+
+// DeleteWebhook removes the webhook integration so updates can be received
+// with GetUpdates.
+func (c *Client) DeleteWebhook(ctx context.Context) error {
+	return c.do(ctx, "deleteWebhook", url.Values{}, nil)
+}
+
+// GetUpdates long polls for the updates starting from offset. It waits for
+// new updates up to timeout.
+func (c *Client) GetUpdates(ctx context.Context, offset int, timeout time.Duration) ([]*Update, error) {
+	params := url.Values{}
+	params.Set("offset", strconv.Itoa(offset))
+	params.Set("timeout", strconv.Itoa(int(timeout.Seconds())))
+
+	var updates []*Update
+	if err := c.do(ctx, "getUpdates", params, &updates); err != nil {
+		return nil, err
+	}
+
+	return updates, nil
+}
+
+// GetMe returns the bot's own user.
+func (c *Client) GetMe(ctx context.Context) (*tbot.User, error) {
+	var me tbot.User
+	if err := c.do(ctx, "getMe", url.Values{}, &me); err != nil {
+		return nil, err
+	}
+
+	return &me, nil
+}
+
+// SetMyCommands replaces the list of the commands shown in the bot menu to the
+// users with the language code. The empty code sets the menu for all the users
+// without a dedicated one.
+func (c *Client) SetMyCommands(ctx context.Context, commands []tbot.BotCommand, languageCode string) error {
+	b, err := json.Marshal(commands)
+	if err != nil {
+		return fmt.Errorf("encode commands: %w", err)
+	}
+
+	params := url.Values{}
+	params.Set("commands", string(b))
+	if languageCode != "" {
+		params.Set("language_code", languageCode)
+	}
+
+	return c.do(ctx, "setMyCommands", params, nil)
+}
+
+// SendOption sets an optional parameter of a send request.
+type SendOption func(params url.Values)
+
+// OptReplyToMessageID sends the message as a reply to the given message.
+func OptReplyToMessageID(messageID int) SendOption {
+	return func(params url.Values) {
+		params.Set("reply_to_message_id", strconv.Itoa(messageID))
+	}
+}
+
+// OptReplyMarkup attaches the keyboard to the message. The markup is one of
+// tbot.InlineKeyboardMarkup, tbot.ReplyKeyboardMarkup or ReplyKeyboardRemove.
+func OptReplyMarkup(markup interface{}) SendOption {
+	return func(params url.Values) {
+		// The keyboard types are plain structs, they are always encoded.
+		b, _ := json.Marshal(markup)
+		params.Set("reply_markup", string(b))
+	}
+}
+
+// ReplyKeyboardRemove hides the reply keyboard shown by the bot.
+type ReplyKeyboardRemove struct {
+	RemoveKeyboard bool `json:"remove_keyboard"`
+	Selective      bool `json:"selective,omitempty"`
+}
+
+// SendMessage sends the text message to the chat.
+func (c *Client) SendMessage(ctx context.Context, chatID, text string, opts ...SendOption) (*tbot.Message, error) {
+	params := url.Values{}
+	params.Set("chat_id", chatID)
+	params.Set("text", text)
+	for _, opt := range opts {
+		opt(params)
+	}
+
+	var msg tbot.Message
+	if err := c.do(ctx, "sendMessage", params, &msg); err != nil {
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+// FileKind is the kind of the file sent with SendFile.
+type FileKind string
+
+// The kinds of the files the bot sends.
+const (
+	FilePhoto    FileKind = "photo"
+	FileDocument FileKind = "document"
+	FileVoice    FileKind = "voice"
+	FileSticker  FileKind = "sticker"
+)
+
+// sendFileMethods are the Bot API methods sending the files by kind.
+var sendFileMethods = map[FileKind]string{
+	FilePhoto:    "sendPhoto",
+	FileDocument: "sendDocument",
+	FileVoice:    "sendVoice",
+	FileSticker:  "sendSticker",
+}
+
+// SendFile sends the file already uploaded to Telegram by its file ID. The
+// stickers have no caption.
+func (c *Client) SendFile(
+	ctx context.Context, chatID string, kind FileKind, fileID, caption string, opts ...SendOption,
+) (*tbot.Message, error) {
+	method, ok := sendFileMethods[kind]
+	if !ok {
+		return nil, fmt.Errorf("telegram: unknown file kind %q", kind)
+	}
+
+	params := url.Values{}
+	params.Set("chat_id", chatID)
+	params.Set(string(kind), fileID)
+	if caption != "" && kind != FileSticker {
+		params.Set("caption", caption)
+	}
+	for _, opt := range opts {
+		opt(params)
+	}
+
+	var msg tbot.Message
+	if err := c.do(ctx, method, params, &msg); err != nil {
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+// ChatAction is the bot status shown to the users in the chat.
+type ChatAction string
+
+// ActionTyping tells the users that the bot is typing a reply.
+const ActionTyping ChatAction = "typing"
+
+// SendChatAction shows the action in the chat for a few seconds or until
+// the bot sends a message.
+func (c *Client) SendChatAction(ctx context.Context, chatID string, action ChatAction) error {
+	params := url.Values{}
+	params.Set("chat_id", chatID)
+	params.Set("action", string(action))
+
+	return c.do(ctx, "sendChatAction", params, nil)
+}
+
+// EditMessageText replaces the text of the message sent by the bot. The
+// inline keyboard is removed unless it is passed with OptReplyMarkup.
+func (c *Client) EditMessageText(
+	ctx context.Context, chatID string, messageID int, text string, opts ...SendOption,
+) (*tbot.Message, error) {
+	params := url.Values{}
+	params.Set("chat_id", chatID)
+	params.Set("message_id", strconv.Itoa(messageID))
+	params.Set("text", text)
+	for _, opt := range opts {
+		opt(params)
+	}
+
+	var msg tbot.Message
+	if err := c.do(ctx, "editMessageText", params, &msg); err != nil {
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+// GetChatMember returns the membership of the user in the chat.
+func (c *Client) GetChatMember(ctx context.Context, chatID string, userID int) (*tbot.ChatMember, error) {
+	params := url.Values{}
+	params.Set("chat_id", chatID)
+	params.Set("user_id", strconv.Itoa(userID))
+
+	var member tbot.ChatMember
+	if err := c.do(ctx, "getChatMember", params, &member); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetFile prepares the file for downloading. The returned file path is valid
+// for at least an hour.
+func (c *Client) GetFile(ctx context.Context, fileID string) (*tbot.File, error) {
+	params := url.Values{}
+	params.Set("file_id", fileID)
+
+	var f tbot.File
+	if err := c.do(ctx, "getFile", params, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// DownloadFile returns the contents of the file with the path returned by
+// GetFile. It returns ErrFileTooLarge if the file is larger than maxSize
+// bytes.
+func (c *Client) DownloadFile(ctx context.Context, filePath string, maxSize int64) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/file/bot%s/%s", c.baseURL, c.token, filePath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create download request: %w", redactURL(err, filePath))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", redactURL(err, filePath))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &Error{Code: resp.StatusCode, Description: http.StatusText(resp.StatusCode)}
+	}
+	if resp.ContentLength > maxSize {
+		return nil, ErrFileTooLarge
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", err)
+	}
+	if int64(len(b)) > maxSize {
+		return nil, ErrFileTooLarge
+	}
+	return b, nil
+}
+
+// AnswerCallbackQuery stops the progress indicator on the pressed button. A
+// non-empty text is shown to the user as a notification or, if showAlert is
+// set, as an alert.
+func (c *Client) AnswerCallbackQuery(ctx context.Context, callbackQueryID, text string, showAlert bool) error {
+	params := url.Values{}
+	params.Set("callback_query_id", callbackQueryID)
+	if text != "" {
+		params.Set("text", text)
+	}
+	if showAlert {
+		params.Set("show_alert", "true")
+	}
+
+	return c.do(ctx, "answerCallbackQuery", params, nil)
+}
+
+// InlineAnswer is the answer to an inline query.
+type InlineAnswer struct {
+	Results []tbot.InlineQueryResult
+	// NextOffset is sent back by Telegram when the user scrolls to the end
+	// of the results. The empty offset means there are no more results.
+	NextOffset string
+	// CacheTime is how long Telegram may return the results without asking
+	// the bot again.
+	CacheTime time.Duration
+	// Personal results are cached by Telegram only for the user who sent the
+	// query.
+	Personal bool
+}
+
+// AnswerInlineQuery sends the results of the inline query.
+func (c *Client) AnswerInlineQuery(ctx context.Context, inlineQueryID string, answer *InlineAnswer) error {
+	results := answer.Results
+	if results == nil {
+		results = []tbot.InlineQueryResult{}
+	}
+	b, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("encode inline query results: %w", err)
+	}
+
+	params := url.Values{}
+	params.Set("inline_query_id", inlineQueryID)
+	params.Set("results", string(b))
+	params.Set("cache_time", strconv.Itoa(int(answer.CacheTime.Seconds())))
+	if answer.NextOffset != "" {
+		params.Set("next_offset", answer.NextOffset)
+	}
+	if answer.Personal {
+		params.Set("is_personal", "true")
+	}
+
+	return c.do(ctx, "answerInlineQuery", params, nil)
+}
@@ -0,0 +1,275 @@
+package telegram_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+)
+
+func TestClient_SetWebhook(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/botTOKEN/setWebhook" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.FormValue("secret_token"); got != "secret" {
+			t.Errorf("secret_token does not match, got = %s, want = secret", got)
+		}
+		_, _ = w.Write([]byte(`{"ok": true, "result": true}`))
+	}))
+	defer srv.Close()
+
+	c := telegram.New("TOKEN", telegram.WithBaseURL(srv.URL))
+	if err := c.SetWebhook(context.Background(), "https://example.com/webhook", "secret"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_GetUpdates(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok": true, "result": [
+			{"update_id": 1, "message": {"message_id": 1, "chat": {"id": 7, "type": "private"}, "text": "hi"}},
+			{"update_id": 2, "my_chat_member": {
+				"chat": {"id": -100, "type": "supergroup", "title": "Group"},
+				"from": {"id": 7, "first_name": "User"},
+				"date": 1,
+				"old_chat_member": {"user": {"id": 1}, "status": "left"},
+				"new_chat_member": {"user": {"id": 1}, "status": "administrator"}
+			}}
+		]}`))
+	}))
+	defer srv.Close()
+
+	c := telegram.New("TOKEN", telegram.WithBaseURL(srv.URL))
+	updates, err := c.GetUpdates(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 2 {
+		t.Fatalf("number of updates does not match, got = %d, want = 2", len(updates))
+	}
+
+	if m := updates[0].Message; m == nil || m.Text != "hi" || m.Chat.ID != "7" {
+		t.Errorf("message does not match, got = %+v", m)
+	}
+
+	cm := updates[1].MyChatMember
+	if cm == nil {
+		t.Fatal("my_chat_member is not decoded")
+	}
+	if cm.Chat.ID != "-100" || cm.Chat.Type != "supergroup" {
+		t.Errorf("chat does not match, got = %+v", cm.Chat)
+	}
+	if got := cm.NewChatMember.Status; got != "administrator" {
+		t.Errorf("new status does not match, got = %s, want = administrator", got)
+	}
+}
+
+func TestClient_SendFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		kind        telegram.FileKind
+		wantPath    string
+		wantParam   string
+		wantCaption string
+	}{
+		{kind: telegram.FilePhoto, wantPath: "/botTOKEN/sendPhoto", wantParam: "photo", wantCaption: "caption"},
+		{kind: telegram.FileDocument, wantPath: "/botTOKEN/sendDocument", wantParam: "document", wantCaption: "caption"},
+		{kind: telegram.FileVoice, wantPath: "/botTOKEN/sendVoice", wantParam: "voice", wantCaption: "caption"},
+		{kind: telegram.FileSticker, wantPath: "/botTOKEN/sendSticker", wantParam: "sticker"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(string(tc.kind), func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tc.wantPath {
+					t.Errorf("path does not match, got = %s, want = %s", r.URL.Path, tc.wantPath)
+				}
+				if got := r.FormValue(tc.wantParam); got != "FILE" {
+					t.Errorf("%s does not match, got = %s, want = FILE", tc.wantParam, got)
+				}
+				if got := r.FormValue("caption"); got != tc.wantCaption {
+					t.Errorf("caption does not match, got = %s, want = %s", got, tc.wantCaption)
+				}
+				_, _ = w.Write([]byte(`{"ok": true, "result": {"message_id": 1, "chat": {"id": 7, "type": "private"}}}`))
+			}))
+			defer srv.Close()
+
+			c := telegram.New("TOKEN", telegram.WithBaseURL(srv.URL))
+			if _, err := c.SendFile(context.Background(), "7", tc.kind, "FILE", "caption"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestClient_Error(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{
+			"ok": false,
+			"error_code": 429,
+			"description": "Too Many Requests: retry after 5",
+			"parameters": {"retry_after": 5}
+		}`))
+	}))
+	defer srv.Close()
+
+	c := telegram.New("TOKEN", telegram.WithBaseURL(srv.URL))
+	_, err := c.GetUpdates(context.Background(), 0, 0)
+
+	var apiErr *telegram.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *telegram.Error, got = %v", err)
+	}
+	if apiErr.Code != http.StatusTooManyRequests {
+		t.Errorf("code does not match, got = %d, want = %d", apiErr.Code, http.StatusTooManyRequests)
+	}
+	if apiErr.RetryAfter != 5*time.Second {
+		t.Errorf("retry after does not match, got = %s, want = 5s", apiErr.RetryAfter)
+	}
+}
+
+func TestClient_NetworkErrorHidesToken(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	c := telegram.New("SECRET_TOKEN", telegram.WithBaseURL(srv.URL))
+	_, err := c.GetUpdates(context.Background(), 0, 0)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if strings.Contains(err.Error(), "SECRET_TOKEN") {
+		t.Errorf("error contains the token, got = %v", err)
+	}
+
+	_, err = c.DownloadFile(context.Background(), "photos/file_1.jpg", 1024)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if strings.Contains(err.Error(), "SECRET_TOKEN") {
+		t.Errorf("error contains the token, got = %v", err)
+	}
+}
+
+func TestClient_DownloadFile(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file/botTOKEN/photos/file_1.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("12345"))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := telegram.New("TOKEN", telegram.WithBaseURL(srv.URL))
+
+	tests := []struct {
+		name     string
+		path     string
+		maxSize  int64
+		want     string
+		wantCode int
+		wantErr  error
+	}{
+		{name: "within limit", path: "photos/file_1.jpg", maxSize: 5, want: "12345"},
+		{name: "too large", path: "photos/file_1.jpg", maxSize: 4, wantErr: telegram.ErrFileTooLarge},
+		{name: "not found", path: "photos/file_2.jpg", maxSize: 5, wantCode: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				got, err := c.DownloadFile(context.Background(), tc.path, tc.maxSize)
+
+				var apiErr *telegram.Error
+				switch {
+				case tc.wantCode != 0:
+					if !errors.As(err, &apiErr) || apiErr.Code != tc.wantCode {
+						t.Errorf("error does not match, got = %v, want code = %d", err, tc.wantCode)
+					}
+				case tc.wantErr != nil:
+					if !errors.Is(err, tc.wantErr) {
+						t.Errorf("error does not match, got = %v, want = %v", err, tc.wantErr)
+					}
+				case err != nil:
+					t.Fatal(err)
+				case string(got) != tc.want:
+					t.Errorf("contents do not match, got = %q, want = %q", got, tc.want)
+				}
+			},
+		)
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "blocked",
+			err:  &telegram.Error{Code: http.StatusForbidden, Description: "Forbidden: bot was blocked by the user"},
+			want: true,
+		},
+		{
+			name: "chat not found",
+			err:  fmt.Errorf("send: %w", &telegram.Error{Code: http.StatusBadRequest, Description: "Bad Request: chat not found"}),
+			want: true,
+		},
+		{
+			name: "flood control",
+			err:  &telegram.Error{Code: http.StatusTooManyRequests, RetryAfter: time.Second},
+		},
+		{
+			name: "server error",
+			err:  &telegram.Error{Code: http.StatusBadGateway},
+		},
+		{
+			name: "network error",
+			err:  errors.New("connection refused"),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				if got := telegram.IsPermanent(tc.err); got != tc.want {
+					t.Errorf("IsPermanent does not match, got = %v, want = %v", got, tc.want)
+				}
+			},
+		)
+	}
+}
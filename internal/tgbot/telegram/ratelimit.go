@@ -0,0 +1,201 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/yanzay/tbot/v2"
+)
+
+// maxTrackedChats is the number of chats the Limiter remembers before it
+// forgets the chats that can already be sent to.
+const maxTrackedChats = 10000
+
+// Limits are the outbound message limits. The zero values disable the
+// corresponding limit.
+type Limits struct {
+	// Rate is the number of messages per second to all chats.
+	Rate float64
+	// Burst is the number of messages that can be sent at once before Rate
+	// applies.
+	Burst int
+	// ChatInterval is the minimal interval between messages to one private
+	// chat.
+	ChatInterval time.Duration
+	// GroupInterval is the minimal interval between messages to one group.
+	GroupInterval time.Duration
+	// MaxRetries is how many times a message rejected with 429 Too Many
+	// Requests is resent after the retry_after delay.
+	MaxRetries int
+}
+
+// Limiter is the API that schedules the sent messages according to the
+// Limits. Messages to one chat are scheduled in the order of the calls. Other
+// methods are passed to the underlying API as is.
+type Limiter struct {
+	API
+	limits Limits
+
+	mu sync.Mutex
+	// next is the time the next message is due at the Rate. The messages
+	// may be sent up to Burst - 1 intervals ahead of it.
+	next time.Time
+	// chatNext is the earliest time the next message can be sent to the chat.
+	chatNext map[string]time.Time
+
+	waiting int64
+}
+
+// NewLimiter wraps the API with the rate limiter.
+func NewLimiter(api API, limits Limits) *Limiter {
+	return &Limiter{
+		API:      api,
+		limits:   limits,
+		chatNext: make(map[string]time.Time),
+	}
+}
+
+// SendMessage sends the message when the limits allow it. A message rejected
+// with 429 Too Many Requests is resent after the delay requested by
+// Telegram, up to MaxRetries times.
+func (l *Limiter) SendMessage(
+	ctx context.Context, chatID, text string, opts ...SendOption,
+) (*tbot.Message, error) {
+	return l.send(ctx, chatID, func() (*tbot.Message, error) {
+		return l.API.SendMessage(ctx, chatID, text, opts...)
+	})
+}
+
+// SendFile sends the file like SendMessage.
+func (l *Limiter) SendFile(
+	ctx context.Context, chatID string, kind FileKind, fileID, caption string, opts ...SendOption,
+) (*tbot.Message, error) {
+	return l.send(ctx, chatID, func() (*tbot.Message, error) {
+		return l.API.SendFile(ctx, chatID, kind, fileID, caption, opts...)
+	})
+}
+
+// send calls the send function when the limits allow it and retries it on
+// 429 Too Many Requests.
+func (l *Limiter) send(ctx context.Context, chatID string, send func() (*tbot.Message, error)) (*tbot.Message, error) {
+	metricsMW := metricsware.NewMiddleware()
+
+	for attempt := 0; ; attempt++ {
+		if err := l.wait(ctx, chatID); err != nil {
+			return nil, err
+		}
+
+		m, err := send()
+
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.Code != http.StatusTooManyRequests {
+			return m, err
+		}
+
+		metricsMW.RecordSendRateLimited(ctx)
+		if attempt >= l.limits.MaxRetries {
+			return nil, err
+		}
+		l.pause(chatID, apiErr.RetryAfter)
+	}
+}
+
+// wait blocks until the message to the chat can be sent.
+func (l *Limiter) wait(ctx context.Context, chatID string) error {
+	metricsMW := metricsware.NewMiddleware()
+
+	at := l.reserve(chatID)
+	delay := time.Until(at)
+	metricsMW.RecordSendThrottleDelay(ctx, delay)
+	if delay <= 0 {
+		return nil
+	}
+
+	metricsMW.RecordSendQueueDepth(ctx, atomic.AddInt64(&l.waiting, 1))
+	defer func() {
+		metricsMW.RecordSendQueueDepth(ctx, atomic.AddInt64(&l.waiting, -1))
+	}()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve returns the time the message to the chat can be sent at and
+// books the slot for it.
+func (l *Limiter) reserve(chatID string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	at := now
+
+	var interval time.Duration
+	if l.limits.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / l.limits.Rate)
+
+		// The slots unused while idle are not saved up, so the credit is
+		// never more than the burst.
+		if l.next.Before(now) {
+			l.next = now
+		}
+		burst := l.limits.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		if earliest := l.next.Add(-time.Duration(burst-1) * interval); earliest.After(at) {
+			at = earliest
+		}
+	}
+
+	if next, ok := l.chatNext[chatID]; ok && next.After(at) {
+		at = next
+	}
+
+	// The slot is taken from the rate even if the chat delays the message,
+	// so the messages queued for one chat do not hold up the others.
+	l.next = l.next.Add(interval)
+	if chatInterval := l.chatInterval(chatID); chatInterval > 0 {
+		l.chatNext[chatID] = at.Add(chatInterval)
+	}
+
+	if len(l.chatNext) > maxTrackedChats {
+		for id, next := range l.chatNext {
+			if next.Before(now) {
+				delete(l.chatNext, id)
+			}
+		}
+	}
+
+	return at
+}
+
+// pause postpones the messages to the chat by d.
+func (l *Limiter) pause(chatID string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := time.Now().Add(d); l.chatNext[chatID].Before(until) {
+		l.chatNext[chatID] = until
+	}
+}
+
+func (l *Limiter) chatInterval(chatID string) time.Duration {
+	// Group and channel IDs are negative.
+	if strings.HasPrefix(chatID, "-") {
+		return l.limits.GroupInterval
+	}
+	return l.limits.ChatInterval
+}
@@ -0,0 +1,166 @@
+package telegram_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram/telegramtest"
+)
+
+func newLimiter(t *testing.T, limits telegram.Limits) (*telegram.Limiter, *telegramtest.Server) {
+	t.Helper()
+
+	srv := telegramtest.NewServer()
+	t.Cleanup(srv.Close)
+
+	api := telegram.New(telegramtest.Token, telegram.WithBaseURL(srv.URL()))
+	return telegram.NewLimiter(api, limits), srv
+}
+
+func TestLimiter_ChatInterval(t *testing.T) {
+	t.Parallel()
+
+	l, srv := newLimiter(t, telegram.Limits{ChatInterval: 100 * time.Millisecond})
+	ctx := context.Background()
+
+	start := time.Now()
+	for _, chatID := range []string{"1", "2", "1", "1"} {
+		if _, err := l.SendMessage(ctx, chatID, "hi"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The second message to another chat is not delayed, the two repeated
+	// messages to the first chat are.
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("messages were sent too fast, elapsed = %v", elapsed)
+	}
+	if got := len(srv.SentMessages()); got != 4 {
+		t.Errorf("number of sent messages does not match, got = %d, want = 4", got)
+	}
+}
+
+func TestLimiter_Rate(t *testing.T) {
+	t.Parallel()
+
+	l, _ := newLimiter(t, telegram.Limits{Rate: 20, Burst: 2})
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if _, err := l.SendMessage(ctx, "1", "hi"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Two messages are sent at once, the other two wait for 50ms each.
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("messages were sent too fast, elapsed = %v", elapsed)
+	}
+}
+
+func TestLimiter_ChatBacklog(t *testing.T) {
+	t.Parallel()
+
+	l, _ := newLimiter(t, telegram.Limits{Rate: 30, Burst: 30, ChatInterval: time.Second})
+
+	// The messages to the first chat are queued for seconds.
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = l.SendMessage(ctx, "1", "hi")
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := l.SendMessage(context.Background(), "2", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("message to another chat was delayed by the backlog, elapsed = %v", elapsed)
+	}
+}
+
+func TestLimiter_RetryAfter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		maxRetries int
+		wantErr    bool
+	}{
+		{
+			name:       "retried",
+			maxRetries: 1,
+		},
+		{
+			name:    "no retries",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				l, srv := newLimiter(t, telegram.Limits{MaxRetries: tc.maxRetries})
+				srv.FailNext("sendMessage", http.StatusTooManyRequests, "Too Many Requests: retry after 1", 1)
+
+				start := time.Now()
+				_, err := l.SendMessage(context.Background(), "1", "hi")
+				if tc.wantErr {
+					var apiErr *telegram.Error
+					if !errors.As(err, &apiErr) || apiErr.Code != http.StatusTooManyRequests {
+						t.Errorf("expected 429 error, got = %v", err)
+					}
+					return
+				}
+
+				if err != nil {
+					t.Fatal(err)
+				}
+				if elapsed := time.Since(start); elapsed < time.Second {
+					t.Errorf("message was resent before retry_after, elapsed = %v", elapsed)
+				}
+				if got := len(srv.Calls("sendMessage")); got != 2 {
+					t.Errorf("number of sendMessage calls does not match, got = %d, want = 2", got)
+				}
+			},
+		)
+	}
+}
+
+func TestLimiter_ContextCanceled(t *testing.T) {
+	t.Parallel()
+
+	l, srv := newLimiter(t, telegram.Limits{ChatInterval: time.Hour})
+
+	if _, err := l.SendMessage(context.Background(), "1", "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.SendMessage(ctx, "1", "hi"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got = %v", err)
+	}
+	if got := len(srv.SentMessages()); got != 1 {
+		t.Errorf("number of sent messages does not match, got = %d, want = 1", got)
+	}
+}
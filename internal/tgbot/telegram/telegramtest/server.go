@@ -0,0 +1,606 @@
+// Package telegramtest provides an in-process fake of the Telegram Bot API
+// for tests. It queues the updates for the bot and records the messages the
+// bot sends.
+package telegramtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yanzay/tbot/v2"
+)
+
+// Token is the bot token the Server accepts.
+const Token = "TESTING_TOKEN"
+
+// BotUsername is the username of the bot returned by getMe.
+const BotUsername = "test_bot"
+
+// SentMessage is a message or a file sent by the bot.
+type SentMessage struct {
+	MessageID int
+	ChatID    string
+	// Text is the text of the message or the caption of the file.
+	Text             string
+	ReplyToMessageID int
+	// Params holds all the request parameters.
+	Params url.Values
+}
+
+// Call is a recorded Bot API request.
+type Call struct {
+	Method string
+	Params url.Values
+}
+
+type failure struct {
+	code        int
+	description string
+	retryAfter  int
+}
+
+type file struct {
+	uniqueID string
+	data     []byte
+}
+
+type update struct {
+	id   int
+	data map[string]interface{}
+}
+
+// Server is a fake Telegram Bot API server.
+type Server struct {
+	srv *httptest.Server
+
+	mu            sync.Mutex
+	changed       chan struct{}
+	updates       []update
+	lastUpdateID  int
+	lastMessageID int
+	lastQueryID   int
+	lastFileID    int
+	files         map[string]file
+	members       map[string]string
+	sent          []SentMessage
+	calls         []Call
+	failures      map[string][]failure
+	commands      map[string][]tbot.BotCommand
+	webhookURL    string
+	secretToken   string
+}
+
+// NewServer starts a new fake server. It must be closed after use.
+func NewServer() *Server {
+	s := &Server{
+		changed:  make(chan struct{}),
+		failures: make(map[string][]failure),
+		files:    make(map[string]file),
+		members:  make(map[string]string),
+		commands: make(map[string][]tbot.BotCommand),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// URL is the base URL of the server to use instead of the Bot API address.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Close shuts down the server. Pending long polling requests are aborted.
+func (s *Server) Close() {
+	s.srv.CloseClientConnections()
+	s.srv.Close()
+}
+
+// notify wakes up the goroutines waiting for the server state to change. It
+// must be called with the lock held.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// AddUpdate queues the update for getUpdates. The update is encoded as is,
+// so the keys follow the Bot API JSON names. It returns the update ID.
+func (s *Server) AddUpdate(data map[string]interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastUpdateID++
+	data["update_id"] = s.lastUpdateID
+	s.updates = append(s.updates, update{id: s.lastUpdateID, data: data})
+	s.notify()
+
+	return s.lastUpdateID
+}
+
+// AddMessage queues a text message from the user in the chat. It returns the
+// message ID.
+func (s *Server) AddMessage(chatID int64, userID int, text string) int {
+	s.mu.Lock()
+	s.lastMessageID++
+	messageID := s.lastMessageID
+	s.mu.Unlock()
+
+	s.AddUpdate(map[string]interface{}{
+		"message": map[string]interface{}{
+			"message_id": messageID,
+			"date":       time.Now().Unix(),
+			"from":       map[string]interface{}{"id": userID, "first_name": "User"},
+			"chat":       map[string]interface{}{"id": chatID, "type": "private"},
+			"text":       text,
+		},
+	})
+
+	return messageID
+}
+
+// AddGroupMessage queues a message from the user in the supergroup. The
+// fields, e.g. "entities" or "new_chat_members", are added to the message.
+// It returns the message ID.
+func (s *Server) AddGroupMessage(chatID int64, userID int, text string, fields map[string]interface{}) int {
+	s.mu.Lock()
+	s.lastMessageID++
+	messageID := s.lastMessageID
+	s.mu.Unlock()
+
+	m := map[string]interface{}{
+		"message_id": messageID,
+		"date":       time.Now().Unix(),
+		"from":       map[string]interface{}{"id": userID, "first_name": "User"},
+		"chat":       map[string]interface{}{"id": chatID, "type": "supergroup", "title": "Group"},
+		"text":       text,
+	}
+	for k, v := range fields {
+		m[k] = v
+	}
+	s.AddUpdate(map[string]interface{}{"message": m})
+
+	return messageID
+}
+
+// EditMessage queues the edit of the user message in the chat.
+func (s *Server) EditMessage(chatID int64, userID, messageID int, text string) {
+	now := time.Now().Unix()
+	s.AddUpdate(map[string]interface{}{
+		"edited_message": map[string]interface{}{
+			"message_id": messageID,
+			"date":       now,
+			"edit_date":  now,
+			"from":       map[string]interface{}{"id": userID, "first_name": "User"},
+			"chat":       map[string]interface{}{"id": chatID, "type": "private"},
+			"text":       text,
+		},
+	})
+}
+
+// AddMyChatMember queues the change of the bot status in the chat, e.g. from
+// "member" to "kicked" when the user blocks the bot.
+func (s *Server) AddMyChatMember(chatID int64, chatType string, userID int, oldStatus, newStatus string) int {
+	bot := map[string]interface{}{"id": 1, "is_bot": true, "first_name": "Test", "username": BotUsername}
+	return s.AddUpdate(map[string]interface{}{
+		"my_chat_member": map[string]interface{}{
+			"chat":            map[string]interface{}{"id": chatID, "type": chatType, "title": "Group"},
+			"from":            map[string]interface{}{"id": userID, "first_name": "User"},
+			"date":            time.Now().Unix(),
+			"old_chat_member": map[string]interface{}{"user": bot, "status": oldStatus},
+			"new_chat_member": map[string]interface{}{"user": bot, "status": newStatus},
+		},
+	})
+}
+
+// SetChatMember sets the status of the user in the chat returned by
+// getChatMember, e.g. "administrator". The default status is "member".
+func (s *Server) SetChatMember(chatID int64, userID int, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.members[strconv.FormatInt(chatID, 10)+":"+strconv.Itoa(userID)] = status
+}
+
+// AddFile stores the file for getFile and downloading. The files with the
+// same uniqueID are the same file uploaded again. It returns the file ID.
+func (s *Server) AddFile(uniqueID string, data []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastFileID++
+	fileID := "file-" + strconv.Itoa(s.lastFileID)
+	s.files[fileID] = file{uniqueID: uniqueID, data: data}
+
+	return fileID
+}
+
+// AddDocument queues a message from the user in the chat with the document
+// added with AddFile. It returns the message ID.
+func (s *Server) AddDocument(chatID int64, userID int, fileID, mimeType, caption string) int {
+	s.mu.Lock()
+	s.lastMessageID++
+	messageID := s.lastMessageID
+	f := s.files[fileID]
+	s.mu.Unlock()
+
+	s.AddUpdate(map[string]interface{}{
+		"message": map[string]interface{}{
+			"message_id": messageID,
+			"date":       time.Now().Unix(),
+			"from":       map[string]interface{}{"id": userID, "first_name": "User"},
+			"chat":       map[string]interface{}{"id": chatID, "type": "private"},
+			"caption":    caption,
+			"document": map[string]interface{}{
+				"file_id":        fileID,
+				"file_unique_id": f.uniqueID,
+				"file_name":      "file",
+				"mime_type":      mimeType,
+				"file_size":      len(f.data),
+			},
+		},
+	})
+
+	return messageID
+}
+
+// AddCallbackQuery queues the press of an inline keyboard button with the
+// callback data under the message in the chat. It returns the callback query
+// ID.
+func (s *Server) AddCallbackQuery(chatID int64, userID, messageID int, data string) string {
+	s.mu.Lock()
+	s.lastQueryID++
+	id := strconv.Itoa(s.lastQueryID)
+	s.mu.Unlock()
+
+	s.AddUpdate(map[string]interface{}{
+		"callback_query": map[string]interface{}{
+			"id":   id,
+			"from": map[string]interface{}{"id": userID, "first_name": "User"},
+			"message": map[string]interface{}{
+				"message_id": messageID,
+				"date":       time.Now().Unix(),
+				"chat":       map[string]interface{}{"id": chatID, "type": "private"},
+			},
+			"chat_instance": strconv.FormatInt(chatID, 10),
+			"data":          data,
+		},
+	})
+
+	return id
+}
+
+// AddInlineQuery queues the inline query typed by the user. It returns the
+// inline query ID.
+func (s *Server) AddInlineQuery(userID int, query, offset string) string {
+	s.mu.Lock()
+	s.lastQueryID++
+	id := strconv.Itoa(s.lastQueryID)
+	s.mu.Unlock()
+
+	s.AddUpdate(map[string]interface{}{
+		"inline_query": map[string]interface{}{
+			"id":     id,
+			"from":   map[string]interface{}{"id": userID, "first_name": "User"},
+			"query":  query,
+			"offset": offset,
+		},
+	})
+
+	return id
+}
+
+// WaitForCalls waits until the method is called at least n times. It returns
+// the calls or an error on timeout.
+func (s *Server) WaitForCalls(method string, n int, timeout time.Duration) ([]Call, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		s.mu.Unlock()
+
+		if calls := s.Calls(method); len(calls) >= n {
+			return calls, nil
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			calls := s.Calls(method)
+			return calls, fmt.Errorf("got %d %s calls, want %d", len(calls), method, n)
+		}
+	}
+}
+
+// FailNext makes the next call of the method fail with the given error.
+func (s *Server) FailNext(method string, code int, description string, retryAfter int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[method] = append(
+		s.failures[method],
+		failure{code: code, description: description, retryAfter: retryAfter},
+	)
+}
+
+// SentMessages returns the messages sent by the bot.
+func (s *Server) SentMessages() []SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]SentMessage(nil), s.sent...)
+}
+
+// WaitForSentMessages waits until the bot sends at least n messages. It
+// returns the sent messages or an error on timeout.
+func (s *Server) WaitForSentMessages(n int, timeout time.Duration) ([]SentMessage, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		s.mu.Lock()
+		sent := append([]SentMessage(nil), s.sent...)
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(sent) >= n {
+			return sent, nil
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return sent, fmt.Errorf("got %d sent messages, want %d", len(sent), n)
+		}
+	}
+}
+
+// Calls returns the recorded requests of the given method.
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []Call
+	for _, c := range s.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Commands returns the bot menu set with setMyCommands for the language code.
+// The empty code is the menu for all the users without a dedicated one.
+func (s *Server) Commands(languageCode string) []tbot.BotCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]tbot.BotCommand(nil), s.commands[languageCode]...)
+}
+
+// Webhook returns the registered webhook URL and secret token.
+func (s *Server) Webhook() (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.webhookURL, s.secretToken
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if filePath := strings.TrimPrefix(r.URL.Path, "/file/bot"+Token+"/"); filePath != r.URL.Path {
+		s.downloadFile(w, r, filePath)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] != "bot"+Token {
+		writeError(w, http.StatusUnauthorized, "Unauthorized", 0)
+		return
+	}
+	method := parts[1]
+
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), 0)
+		return
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: method, Params: r.Form})
+	s.notify()
+	if f := s.failures[method]; len(f) > 0 {
+		s.failures[method] = f[1:]
+		s.mu.Unlock()
+		writeError(w, f[0].code, f[0].description, f[0].retryAfter)
+		return
+	}
+	s.mu.Unlock()
+
+	switch method {
+	case "getMe":
+		writeResult(w, map[string]interface{}{
+			"id": 1, "is_bot": true, "first_name": "Test", "username": BotUsername,
+		})
+	case "getUpdates":
+		s.getUpdates(w, r)
+	case "setWebhook":
+		s.mu.Lock()
+		s.webhookURL, s.secretToken = r.Form.Get("url"), r.Form.Get("secret_token")
+		s.mu.Unlock()
+		writeResult(w, true)
+	case "deleteWebhook":
+		s.mu.Lock()
+		s.webhookURL, s.secretToken = "", ""
+		s.mu.Unlock()
+		writeResult(w, true)
+	case "setMyCommands":
+		var commands []tbot.BotCommand
+		if err := json.Unmarshal([]byte(r.Form.Get("commands")), &commands); err != nil {
+			writeError(w, http.StatusBadRequest, "Bad Request: can't parse commands", 0)
+			return
+		}
+		s.mu.Lock()
+		s.commands[r.Form.Get("language_code")] = commands
+		s.mu.Unlock()
+		writeResult(w, true)
+	case "sendChatAction", "answerCallbackQuery", "answerInlineQuery":
+		writeResult(w, true)
+	case "editMessageText":
+		messageID, _ := strconv.Atoi(r.Form.Get("message_id"))
+		chatID, _ := strconv.ParseInt(r.Form.Get("chat_id"), 10, 64)
+		writeResult(w, map[string]interface{}{
+			"message_id": messageID,
+			"date":       time.Now().Unix(),
+			"chat":       map[string]interface{}{"id": chatID},
+			"text":       r.Form.Get("text"),
+		})
+	case "sendMessage", "sendPhoto", "sendDocument", "sendVoice", "sendSticker":
+		s.sendMessage(w, r)
+	case "getFile":
+		s.getFile(w, r)
+	case "getChatMember":
+		s.mu.Lock()
+		status, ok := s.members[r.Form.Get("chat_id")+":"+r.Form.Get("user_id")]
+		s.mu.Unlock()
+		if !ok {
+			status = "member"
+		}
+		userID, _ := strconv.Atoi(r.Form.Get("user_id"))
+		writeResult(w, map[string]interface{}{
+			"user":   map[string]interface{}{"id": userID, "first_name": "User"},
+			"status": status,
+		})
+	default:
+		writeError(w, http.StatusNotFound, "Not Found", 0)
+	}
+}
+
+func (s *Server) getUpdates(w http.ResponseWriter, r *http.Request) {
+	offset, _ := strconv.Atoi(r.Form.Get("offset"))
+	timeout, _ := strconv.Atoi(r.Form.Get("timeout"))
+
+	deadline := time.NewTimer(time.Duration(timeout) * time.Second)
+	defer deadline.Stop()
+
+	for {
+		s.mu.Lock()
+		// Updates before the offset are confirmed and never returned again.
+		var pending []update
+		for _, u := range s.updates {
+			if u.id >= offset {
+				pending = append(pending, u)
+			}
+		}
+		s.updates = pending
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(pending) > 0 {
+			result := make([]map[string]interface{}, 0, len(pending))
+			for _, u := range pending {
+				result = append(result, u.data)
+			}
+			writeResult(w, result)
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			writeResult(w, []interface{}{})
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) sendMessage(w http.ResponseWriter, r *http.Request) {
+	chatID, err := strconv.ParseInt(r.Form.Get("chat_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: chat not found", 0)
+		return
+	}
+	replyTo, _ := strconv.Atoi(r.Form.Get("reply_to_message_id"))
+
+	s.mu.Lock()
+	s.lastMessageID++
+	msg := SentMessage{
+		MessageID:        s.lastMessageID,
+		ChatID:           r.Form.Get("chat_id"),
+		Text:             r.Form.Get("text") + r.Form.Get("caption"),
+		ReplyToMessageID: replyTo,
+		Params:           r.Form,
+	}
+	s.sent = append(s.sent, msg)
+	s.notify()
+	s.mu.Unlock()
+
+	writeResult(w, map[string]interface{}{
+		"message_id": msg.MessageID,
+		"date":       time.Now().Unix(),
+		"chat":       map[string]interface{}{"id": chatID},
+		"text":       msg.Text,
+	})
+}
+
+func (s *Server) getFile(w http.ResponseWriter, r *http.Request) {
+	fileID := r.Form.Get("file_id")
+
+	s.mu.Lock()
+	f, ok := s.files[fileID]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad Request: invalid file_id", 0)
+		return
+	}
+
+	writeResult(w, map[string]interface{}{
+		"file_id":        fileID,
+		"file_unique_id": f.uniqueID,
+		"file_size":      len(f.data),
+		"file_path":      "documents/" + fileID,
+	})
+}
+
+func (s *Server) downloadFile(w http.ResponseWriter, r *http.Request, filePath string) {
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: "downloadFile", Params: url.Values{"file_path": {filePath}}})
+	s.notify()
+	f, ok := s.files[strings.TrimPrefix(filePath, "documents/")]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Write(f.data)
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"ok":     true,
+		"result": result,
+	})
+}
+
+func writeError(w http.ResponseWriter, code int, description string, retryAfter int) {
+	resp := map[string]interface{}{
+		"ok":          false,
+		"error_code":  code,
+		"description": description,
+	}
+	if retryAfter > 0 {
+		resp["parameters"] = map[string]interface{}{"retry_after": retryAfter}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,60 @@
+package telegram
+
+import (
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/yanzay/tbot/v2"
+)
+
+// Update is an incoming update. It extends the tbot update with the kinds
+// the tbot package does not know.
+type Update struct {
+	tbot.Update
+	// MyChatMember is set when the status of the bot in the chat changed,
+	// e.g. the bot was added to a group or blocked by the user.
+	MyChatMember *ChatMemberUpdated `json:"my_chat_member"`
+}
+
+// Kind returns the kind of the update, e.g. metricsware.KindMessage, or "" if
+// the kind is unknown.
+func (u *Update) Kind() string {
+	switch {
+	case u.Message != nil:
+		return metricsware.KindMessage
+	case u.EditedMessage != nil:
+		return metricsware.KindEditedMessage
+	case u.CallbackQuery != nil:
+		return metricsware.KindCallbackQuery
+	case u.InlineQuery != nil:
+		return metricsware.KindInlineQuery
+	case u.MyChatMember != nil:
+		return metricsware.KindMyChatMember
+	default:
+		return ""
+	}
+}
+
+// ChatType returns the type of the chat the update came from, e.g. "private"
+// or "supergroup", or "" if the update has no chat, like the inline queries.
+func (u *Update) ChatType() string {
+	switch {
+	case u.Message != nil:
+		return u.Message.Chat.Type
+	case u.EditedMessage != nil:
+		return u.EditedMessage.Chat.Type
+	case u.CallbackQuery != nil && u.CallbackQuery.Message != nil:
+		return u.CallbackQuery.Message.Chat.Type
+	case u.MyChatMember != nil:
+		return u.MyChatMember.Chat.Type
+	default:
+		return ""
+	}
+}
+
+// ChatMemberUpdated describes the change of the status of a chat member.
+type ChatMemberUpdated struct {
+	Chat          tbot.Chat       `json:"chat"`
+	From          tbot.User       `json:"from"`
+	Date          int             `json:"date"`
+	OldChatMember tbot.ChatMember `json:"old_chat_member"`
+	NewChatMember tbot.ChatMember `json:"new_chat_member"`
+}
@@ -0,0 +1,69 @@
+package telegram_test
+
+import (
+	"testing"
+
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+)
+
+func TestUpdate_KindAndChatType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		update   *telegram.Update
+		kind     string
+		chatType string
+	}{
+		{
+			name:     "message",
+			update:   &telegram.Update{Update: tbot.Update{Message: &tbot.Message{Chat: tbot.Chat{Type: "private"}}}},
+			kind:     metricsware.KindMessage,
+			chatType: "private",
+		},
+		{
+			name: "callback query",
+			update: &telegram.Update{Update: tbot.Update{CallbackQuery: &tbot.CallbackQuery{
+				Message: &tbot.Message{Chat: tbot.Chat{Type: "group"}},
+			}}},
+			kind:     metricsware.KindCallbackQuery,
+			chatType: "group",
+		},
+		{
+			name:   "inline query",
+			update: &telegram.Update{Update: tbot.Update{InlineQuery: &tbot.InlineQuery{}}},
+			kind:   metricsware.KindInlineQuery,
+		},
+		{
+			name: "my chat member",
+			update: &telegram.Update{MyChatMember: &telegram.ChatMemberUpdated{
+				Chat: tbot.Chat{Type: "supergroup"},
+			}},
+			kind:     metricsware.KindMyChatMember,
+			chatType: "supergroup",
+		},
+		{
+			name:   "unknown",
+			update: &telegram.Update{},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				if got := tc.update.Kind(); got != tc.kind {
+					t.Errorf("kind does not match, got = %q, want = %q", got, tc.kind)
+				}
+				if got := tc.update.ChatType(); got != tc.chatType {
+					t.Errorf("chat type does not match, got = %q, want = %q", got, tc.chatType)
+				}
+			},
+		)
+	}
+}
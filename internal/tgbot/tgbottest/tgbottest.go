@@ -0,0 +1,63 @@
+// Package tgbottest runs a bot against the fake Telegram Bot API, so the
+// modules can be tested in isolation.
+package tgbottest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/serverenv"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram/telegramtest"
+)
+
+// Serve runs the bot against a fake Bot API until the test ends. The bot has
+// no database and speaks Russian by default. The configure function, if not
+// nil, changes the config before the bot is created, e.g. sets the enabled
+// Modules and their ModuleConfigs; setup, if not nil, is called before Serve.
+func Serve(t *testing.T, configure func(*tgbot.Config), setup func(*tgbot.Bot)) *telegramtest.Server {
+	t.Helper()
+
+	srv := telegramtest.NewServer()
+	t.Cleanup(srv.Close)
+
+	config := &tgbot.Config{
+		TelegramToken:   telegramtest.Token,
+		TelegramAPIURL:  srv.URL(),
+		DefaultLocale:   "ru",
+		ShutdownTimeout: time.Second,
+	}
+	if configure != nil {
+		configure(config)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	env := serverenv.New(ctx)
+	bot, err := tgbot.New(env, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setup != nil {
+		setup(bot)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- bot.Serve(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("serve: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("bot did not stop")
+		}
+	})
+
+	return srv
+}
@@ -0,0 +1,117 @@
+package tgbot
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"go.uber.org/zap"
+)
+
+const (
+	// longPollTimeout is how long Telegram holds a getUpdates request open.
+	longPollTimeout = 50 * time.Second
+
+	// pollRetryDelay is the pause after a failed getUpdates request.
+	pollRetryDelay = time.Second
+)
+
+type messageHandler struct {
+	rx *regexp.Regexp
+	h  Handler
+}
+
+// handleMessage registers the handler for messages with text matching the
+// pattern. Handlers are checked in the registration order. The handler is
+// wrapped with the bot middlewares.
+func (b *Bot) handleMessage(pattern string, h Handler) {
+	b.messageHandlers = append(
+		b.messageHandlers,
+		messageHandler{rx: regexp.MustCompile(pattern), h: Chain(h, b.middlewares()...)},
+	)
+}
+
+// handleUpdate dispatches the update to the first matching handler. The
+// messages the bot does not answer, e.g. the ones not addressed to it in a
+// group, are only saved.
+func (b *Bot) handleUpdate(ctx context.Context, u *telegram.Update) {
+	if u.MyChatMember != nil {
+		b.chatMemberHandler(ctx, u)
+		return
+	}
+	if u.CallbackQuery != nil {
+		b.callbackHandler(ctx, u)
+		return
+	}
+	if u.InlineQuery != nil {
+		b.inlineHandler(ctx, u)
+		return
+	}
+	if u.EditedMessage != nil {
+		b.editedHandler(ctx, u)
+		return
+	}
+	if u.Message == nil {
+		return
+	}
+	if isChatEvent(u.Message) {
+		b.chatEventHandler(ctx, u)
+		return
+	}
+	if !isCommand(u.Message) && !b.shouldReply(ctx, u.Message) {
+		b.listenHandler(ctx, u)
+		return
+	}
+	if messageMedia(u.Message) != nil {
+		b.mediaHandler(ctx, u)
+		return
+	}
+
+	for _, mh := range b.messageHandlers {
+		if mh.rx.MatchString(u.Message.Text) {
+			mh.h(ctx, u)
+			return
+		}
+	}
+}
+
+// pollUpdates receives updates with getUpdates until the context is done. It
+// resumes after the saved offset, see updateLog.
+func (b *Bot) pollUpdates(ctx context.Context) error {
+	log := logging.FromContext(ctx)
+
+	if err := b.api.DeleteWebhook(ctx); err != nil {
+		log.Warnw("delete webhook", zap.Error(err))
+	}
+
+	offset := 0
+	if last := b.updateLog.offset(); last > 0 {
+		offset = last + 1
+		log.Infow("resuming long polling", "offset", offset)
+	}
+	for {
+		updates, err := b.api.GetUpdates(ctx, offset, longPollTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			log.Errorw("get updates", zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(pollRetryDelay):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			if err := b.receiveUpdate(ctx, u); err != nil {
+				return nil
+			}
+			offset = u.UpdateID + 1
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package tgbot
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+	"go.uber.org/zap"
+)
+
+// userRecorder collects the profiles of the update senders and saves them in
+// batches, so the busy users cost one upsert per flush instead of one per
+// update.
+type userRecorder struct {
+	save       func(ctx context.Context, users []*model.User, unblocked []int64) error
+	setBlocked func(ctx context.Context, userID int64, blocked bool) error
+	batchSize  int
+	interval   time.Duration
+
+	// saveMu orders the saves of the batches and the blocked flags, so a
+	// batch does not clear the flag set after it was taken.
+	saveMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[int64]*pendingUser
+	// full is signaled when the batch size is reached.
+	full chan struct{}
+	// closing is closed when no more users are added.
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+// pendingUser is a user queued for the flush.
+type pendingUser struct {
+	user *model.User
+	// wrote is set when the latest update of the user is a private message,
+	// so the user did not block the bot.
+	wrote bool
+}
+
+func newUserRecorder(
+	save func(ctx context.Context, users []*model.User, unblocked []int64) error,
+	setBlocked func(ctx context.Context, userID int64, blocked bool) error,
+	batchSize int, interval time.Duration,
+) *userRecorder {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	return &userRecorder{
+		save:       save,
+		setBlocked: setBlocked,
+		batchSize:  batchSize,
+		interval:   interval,
+		pending:    make(map[int64]*pendingUser),
+		full:       make(chan struct{}, 1),
+		closing:    make(chan struct{}),
+	}
+}
+
+// add queues the profile of the user seen at seenAt. The user seen many times
+// before the flush is saved once with the latest profile. wrote reports
+// whether the user wrote to the bot in private, which clears the blocked flag
+// of the user. The other updates, e.g. the user blocking the bot, keep it.
+func (r *userRecorder) add(from *tbot.User, seenAt time.Time, wrote bool) {
+	u := &model.User{
+		ID:           int64(from.ID),
+		Username:     from.Username,
+		FirstName:    from.FirstName,
+		LastName:     from.LastName,
+		LanguageCode: from.LanguageCode,
+		IsBot:        from.IsBot,
+		FirstSeenAt:  seenAt,
+		LastSeenAt:   seenAt,
+	}
+
+	r.mu.Lock()
+	if prev, ok := r.pending[u.ID]; ok && prev.user.FirstSeenAt.Before(u.FirstSeenAt) {
+		u.FirstSeenAt = prev.user.FirstSeenAt
+	}
+	r.pending[u.ID] = &pendingUser{user: u, wrote: wrote}
+	full := len(r.pending) >= r.batchSize
+	r.mu.Unlock()
+
+	if full {
+		select {
+		case r.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run saves the queued users every interval or once the batch is full. After
+// close it saves the rest and returns.
+func (r *userRecorder) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.closing:
+			r.flush(ctx)
+			return
+		case <-ticker.C:
+		case <-r.full:
+		}
+		r.flush(ctx)
+	}
+}
+
+// close makes run save the queued users and return.
+func (r *userRecorder) close() {
+	r.closeOnce.Do(func() { close(r.closing) })
+}
+
+// flush saves the queued users. The users that failed to save are queued
+// again unless they were seen since.
+func (r *userRecorder) flush(ctx context.Context) {
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+
+	r.mu.Lock()
+	if len(r.pending) == 0 {
+		r.mu.Unlock()
+		return
+	}
+	pending := r.pending
+	r.pending = make(map[int64]*pendingUser, len(pending))
+	r.mu.Unlock()
+
+	users := make([]*model.User, 0, len(pending))
+	var unblocked []int64
+	for _, p := range pending {
+		users = append(users, p.user)
+		if p.wrote {
+			unblocked = append(unblocked, p.user.ID)
+		}
+	}
+
+	if err := r.save(ctx, users, unblocked); err != nil {
+		metricsware.NewMiddleware().RecordUserSaveFailure(ctx)
+		logging.FromContext(ctx).Errorw("failed to save users", "users", len(users), zap.Error(err))
+
+		r.mu.Lock()
+		for id, p := range pending {
+			if _, ok := r.pending[id]; !ok {
+				r.pending[id] = p
+			}
+		}
+		r.mu.Unlock()
+		return
+	}
+
+	metricsware.NewMiddleware().RecordUsersSaved(ctx, int64(len(users)))
+}
+
+// block saves whether the user blocked the bot. The queued profile of the
+// blocked user no longer clears the flag.
+func (r *userRecorder) block(ctx context.Context, userID int64, blocked bool) error {
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+
+	if blocked {
+		r.mu.Lock()
+		if p, ok := r.pending[userID]; ok {
+			p.wrote = false
+		}
+		r.mu.Unlock()
+	}
+	return r.setBlocked(ctx, userID, blocked)
+}
+
+// TrackUsers records the sender of every update in the users registry. The
+// private messages clear the blocked flag of the sender.
+func (b *Bot) TrackUsers() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *telegram.Update) {
+			if from := updateSender(u); from != nil && b.users != nil {
+				wrote := u.Message != nil && u.Message.Chat.Type == "private"
+				b.users.add(from, time.Now(), wrote)
+			}
+
+			next(ctx, u)
+		}
+	}
+}
+
+// markBlocked records that the user blocked the bot. In the private chats the
+// chat ID is the user ID, the group chat IDs are negative.
+func (b *Bot) markBlocked(ctx context.Context, chatID string) {
+	userID, err := strconv.ParseInt(chatID, 10, 64)
+	if err != nil || userID <= 0 || b.db == nil {
+		return
+	}
+
+	metricsware.NewMiddleware().RecordUserBlocked(ctx)
+	if err := b.users.block(ctx, userID, true); err != nil {
+		logging.FromContext(ctx).Errorw("failed to mark user blocked", "user_id", userID, zap.Error(err))
+	}
+}
@@ -0,0 +1,204 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/model"
+	"github.com/yanzay/tbot/v2"
+)
+
+// savedUsers collects the batches passed to the save function.
+type savedUsers struct {
+	mu      sync.Mutex
+	batches [][]*model.User
+	// blocked emulates the blocked flags in the database.
+	blocked map[int64]bool
+	err     error
+}
+
+func (s *savedUsers) save(_ context.Context, users []*model.User, unblocked []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		err := s.err
+		s.err = nil
+		return err
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	s.batches = append(s.batches, users)
+	for _, id := range unblocked {
+		delete(s.blocked, id)
+	}
+	return nil
+}
+
+func (s *savedUsers) setBlocked(_ context.Context, id int64, blocked bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.blocked == nil {
+		s.blocked = make(map[int64]bool)
+	}
+	s.blocked[id] = blocked
+	return nil
+}
+
+func (s *savedUsers) isBlocked(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blocked[id]
+}
+
+func (s *savedUsers) get() [][]*model.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batches
+}
+
+func TestUserRecorder_Dedup(t *testing.T) {
+	t.Parallel()
+
+	var saved savedUsers
+	r := newUserRecorder(saved.save, saved.setBlocked, 10, time.Hour)
+
+	first := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	r.add(&tbot.User{ID: 1, Username: "old"}, first, false)
+	r.add(&tbot.User{ID: 2, FirstName: "Bob"}, first, false)
+	r.add(&tbot.User{ID: 1, Username: "new", LanguageCode: "en"}, first.Add(time.Minute), false)
+
+	r.flush(context.Background())
+
+	batches := saved.get()
+	if len(batches) != 1 {
+		t.Fatalf("number of batches does not match, got = %d, want = 1", len(batches))
+	}
+	users := batches[0]
+	if len(users) != 2 {
+		t.Fatalf("number of users does not match, got = %d, want = 2", len(users))
+	}
+
+	got := users[0]
+	if got.Username != "new" || got.LanguageCode != "en" {
+		t.Errorf("profile is not the latest, got = %+v", got)
+	}
+	if !got.FirstSeenAt.Equal(first) {
+		t.Errorf("first seen does not match, got = %v, want = %v", got.FirstSeenAt, first)
+	}
+	if want := first.Add(time.Minute); !got.LastSeenAt.Equal(want) {
+		t.Errorf("last seen does not match, got = %v, want = %v", got.LastSeenAt, want)
+	}
+
+	// Nothing is saved without new users.
+	r.flush(context.Background())
+	if got := len(saved.get()); got != 1 {
+		t.Errorf("number of batches does not match, got = %d, want = 1", got)
+	}
+}
+
+func TestUserRecorder_Blocked(t *testing.T) {
+	t.Parallel()
+
+	// The user blocked the bot: the flag is set right away and the sender of
+	// the my_chat_member update is queued.
+	saved := savedUsers{blocked: map[int64]bool{1: true}}
+	r := newUserRecorder(saved.save, saved.setBlocked, 10, time.Hour)
+
+	r.add(&tbot.User{ID: 1}, time.Now(), false)
+	r.flush(context.Background())
+	if !saved.isBlocked(1) {
+		t.Fatal("user is unblocked by the flush")
+	}
+
+	// The private message clears the flag.
+	r.add(&tbot.User{ID: 1}, time.Now(), true)
+	r.flush(context.Background())
+	if saved.isBlocked(1) {
+		t.Error("user is still blocked after writing to the bot")
+	}
+}
+
+func TestUserRecorder_BlockedWhilePending(t *testing.T) {
+	t.Parallel()
+
+	var saved savedUsers
+	r := newUserRecorder(saved.save, saved.setBlocked, 10, time.Hour)
+
+	// The user writes to the bot and blocks it before the flush: the
+	// message to the user fails with 403.
+	r.add(&tbot.User{ID: 1}, time.Now(), true)
+	if err := r.block(context.Background(), 1, true); err != nil {
+		t.Fatal(err)
+	}
+	r.flush(context.Background())
+
+	if !saved.isBlocked(1) {
+		t.Error("user is unblocked by the pending profile")
+	}
+	if batches := saved.get(); len(batches) != 1 || len(batches[0]) != 1 {
+		t.Errorf("profile is not saved, got = %v", batches)
+	}
+}
+
+func TestUserRecorder_Retry(t *testing.T) {
+	t.Parallel()
+
+	saved := savedUsers{err: errors.New("database is down")}
+	r := newUserRecorder(saved.save, saved.setBlocked, 10, time.Hour)
+
+	r.add(&tbot.User{ID: 1}, time.Now(), false)
+	r.flush(context.Background())
+	if got := len(saved.get()); got != 0 {
+		t.Fatalf("number of batches does not match, got = %d, want = 0", got)
+	}
+
+	r.flush(context.Background())
+	batches := saved.get()
+	if len(batches) != 1 || len(batches[0]) != 1 {
+		t.Fatalf("failed users are not saved again, got = %v", batches)
+	}
+}
+
+func TestUserRecorder_Run(t *testing.T) {
+	t.Parallel()
+
+	var saved savedUsers
+	r := newUserRecorder(saved.save, saved.setBlocked, 2, time.Hour)
+
+	done := make(chan struct{})
+	go func() {
+		r.run(context.Background())
+		close(done)
+	}()
+
+	// The full batch is saved without waiting for the interval.
+	r.add(&tbot.User{ID: 1}, time.Now(), false)
+	r.add(&tbot.User{ID: 2}, time.Now(), false)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(saved.get()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("full batch is not saved")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The rest is saved on close.
+	r.add(&tbot.User{ID: 3}, time.Now(), false)
+	r.close()
+	<-done
+
+	var ids []int64
+	for _, batch := range saved.get() {
+		for _, u := range batch {
+			ids = append(ids, u.ID)
+		}
+	}
+	if len(ids) != 3 {
+		t.Errorf("saved users do not match, got = %v, want 3 users", ids)
+	}
+}
@@ -0,0 +1,196 @@
+package tgbot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/logging"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"go.uber.org/zap"
+)
+
+const (
+	// secretTokenHeader carries the secret token set with setWebhook.
+	secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+	// webhookShutdownTimeout limits the time spent on the webhook
+	// deregistration and the HTTP server shutdown.
+	webhookShutdownTimeout = 10 * time.Second
+
+	// webhookSecretSize is the number of random bytes of the generated
+	// secret token.
+	webhookSecretSize = 32
+)
+
+// webhookEnabled reports whether the config allows receiving updates with a
+// webhook. Long polling is used otherwise.
+func (c *Config) webhookEnabled() bool {
+	return c.WebhookURL != "" && c.WebhookPort != ""
+}
+
+// webhookURL is the public address Telegram sends the updates to.
+func (c *Config) webhookURL() string {
+	return strings.TrimSuffix(c.WebhookURL, "/") + c.WebhookPath
+}
+
+// webhookSecret returns the configured secret token or, if there is none, a
+// random one. The webhook path is easy to guess, so the requests are never
+// accepted without a secret: a forged update could act as an owner.
+func (c *Config) webhookSecret() (string, error) {
+	if c.WebhookSecretToken != "" {
+		return c.WebhookSecretToken, nil
+	}
+
+	b := make([]byte, webhookSecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate webhook secret token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// newWebhookHandler returns the handler for requests from Telegram. Requests
+// without the matching secret token are rejected, all of them if the secret
+// token is empty. The response is delayed
+// until handle returns, so a saturated bot makes Telegram slow down.
+func newWebhookHandler(ctx context.Context, secretToken string, handle func(u *telegram.Update)) http.Handler {
+	log := logging.FromContext(ctx)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		got := r.Header.Get(secretTokenHeader)
+		if secretToken == "" || subtle.ConstantTimeCompare([]byte(got), []byte(secretToken)) != 1 {
+			log.Warnw("webhook request with invalid secret token", "remote_addr", r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var u telegram.Update
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			log.Errorw("decode webhook update", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		handle(&u)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// webhookServer serves the webhooks of the bots listening on one port. The
+// bots add their handlers by path, so they share the one port the platform
+// exposes.
+type webhookServer struct {
+	port string
+	mux  *http.ServeMux
+	srv  *http.Server
+}
+
+func newWebhookServer(port string) *webhookServer {
+	mux := http.NewServeMux()
+	return &webhookServer{
+		port: port,
+		mux:  mux,
+		srv: &http.Server{
+			Addr:    ":" + port,
+			Handler: mux,
+		},
+	}
+}
+
+// handle serves the webhook requests to the path with h.
+func (s *webhookServer) handle(path string, h http.Handler) {
+	s.mux.Handle(path, h)
+}
+
+// serve listens until the context is done and shuts the server down then.
+func (s *webhookServer) serve(ctx context.Context) error {
+	log := logging.FromContext(ctx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Infow("webhook listening", "port", s.port)
+		errCh <- s.srv.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("serve webhook: %w", err)
+		}
+		return nil
+	}
+
+	// The parent context is already done, so the shutdown needs its own.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+	defer cancel()
+
+	if err := s.srv.Shutdown(shutdownCtx); err != nil {
+		log.Errorw("shutdown webhook server", zap.Error(err))
+	}
+	return nil
+}
+
+// serveWebhook registers the webhook and serves the updates over HTTP until
+// the context is done. The bot listens on its own server unless it shares
+// one set up by ServeBots. The webhook is deleted on exit.
+func (b *Bot) serveWebhook(ctx context.Context) error {
+	log := logging.FromContext(ctx)
+
+	srv := b.webhookServer
+	serveErr := make(chan error, 1)
+	if srv == nil {
+		srv = newWebhookServer(b.config.WebhookPort)
+
+		serveCtx, stopServe := context.WithCancel(ctx)
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			serveErr <- srv.serve(serveCtx)
+		}()
+		defer func() {
+			stopServe()
+			<-stopped
+		}()
+	}
+
+	srv.handle(b.config.WebhookPath, newWebhookHandler(ctx, b.webhookSecret, func(u *telegram.Update) {
+		if err := b.receiveUpdate(ctx, u); err != nil {
+			log.Warnw("update dropped", "update_id", u.UpdateID, zap.Error(err))
+		}
+	}))
+	log.Infow("webhook handler added", "port", srv.port, "path", b.config.WebhookPath)
+
+	if err := b.api.SetWebhook(ctx, b.config.webhookURL(), b.webhookSecret); err != nil {
+		return fmt.Errorf("set webhook: %w", err)
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
+	}
+
+	// The parent context is already done, so the cleanup needs its own.
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+	defer cancel()
+
+	log.Info("deleting the webhook")
+	if err := b.api.DeleteWebhook(cleanupCtx); err != nil {
+		log.Errorw("delete webhook", zap.Error(err))
+	}
+
+	return err
+}
@@ -0,0 +1,223 @@
+package tgbot
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/serverenv"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram/telegramtest"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	t.Parallel()
+
+	const body = `{"update_id": 7, "message": {"message_id": 1, "chat": {"id": 42}, "text": "hi"}}`
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		body       string
+		wantStatus int
+		wantUpdate bool
+	}{
+		{
+			name:       "valid",
+			method:     http.MethodPost,
+			token:      "secret",
+			body:       body,
+			wantStatus: http.StatusOK,
+			wantUpdate: true,
+		},
+		{
+			name:       "wrong token",
+			method:     http.MethodPost,
+			token:      "guess",
+			body:       body,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "missing token",
+			method:     http.MethodPost,
+			body:       body,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			token:      "secret",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "malformed body",
+			method:     http.MethodPost,
+			token:      "secret",
+			body:       "{",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				updates := make(chan *telegram.Update, 1)
+				h := newWebhookHandler(context.Background(), "secret", func(u *telegram.Update) {
+					updates <- u
+				})
+
+				r := httptest.NewRequest(tc.method, "/webhook", strings.NewReader(tc.body))
+				if tc.token != "" {
+					r.Header.Set(secretTokenHeader, tc.token)
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+
+				if w.Code != tc.wantStatus {
+					t.Errorf("status does not match, got = %d, want = %d", w.Code, tc.wantStatus)
+				}
+
+				if !tc.wantUpdate {
+					return
+				}
+				select {
+				case u := <-updates:
+					if u.UpdateID != 7 || u.Message.Chat.ID != "42" {
+						t.Errorf("unexpected update: %+v", u)
+					}
+				case <-time.After(time.Second):
+					t.Error("update was not handled")
+				}
+			},
+		)
+	}
+}
+
+func TestWebhookHandler_NoSecret(t *testing.T) {
+	t.Parallel()
+
+	b, err := New(serverenv.New(context.Background()), &Config{
+		TelegramToken: "TESTING_TOKEN",
+		DefaultLocale: "ru",
+		WebhookURL:    "https://example.com",
+		WebhookPort:   "8443",
+		WebhookPath:   "/webhook",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.webhookSecret == "" {
+		t.Fatal("no secret token is generated")
+	}
+
+	for _, secret := range []string{b.webhookSecret, ""} {
+		h := newWebhookHandler(context.Background(), secret, func(u *telegram.Update) {
+			t.Errorf("unauthenticated update is handled: %+v", u)
+		})
+
+		r := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"update_id": 7}`))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("status does not match, got = %d, want = %d", w.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestServeBots_SharedWebhook(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"main", "support"}
+	apis := make(map[string]*telegramtest.Server, len(names))
+	configs := make([]*Config, 0, len(names))
+	for _, name := range names {
+		api := telegramtest.NewServer()
+		t.Cleanup(api.Close)
+		apis[name] = api
+
+		configs = append(configs, &Config{
+			BotName:            name,
+			TelegramToken:      telegramtest.Token,
+			TelegramAPIURL:     api.URL(),
+			DefaultLocale:      "ru",
+			ShutdownTimeout:    time.Second,
+			WebhookURL:         "https://example.com",
+			WebhookPort:        port,
+			WebhookPath:        "/webhook/" + name,
+			WebhookSecretToken: "secret",
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeBots(ctx, serverenv.New(ctx), configs)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("serve bots: %v", err)
+		}
+	}()
+
+	for _, name := range names {
+		api := apis[name]
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if url, _ := api.Webhook(); url == "https://example.com/webhook/"+name {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("webhook of %s is not set", name)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		body := `{"update_id": 1, "message": {"message_id": 1, "chat": {"id": 42, "type": "private"}, ` +
+			`"from": {"id": 7, "first_name": "User"}, "text": "` + name + `"}}`
+		req, err := http.NewRequest(
+			http.MethodPost, "http://127.0.0.1:"+port+"/webhook/"+name, strings.NewReader(body),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(secretTokenHeader, "secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status of %s does not match, got = %d, want = %d", name, resp.StatusCode, http.StatusOK)
+		}
+
+		sent, err := api.WaitForSentMessages(1, 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sent[0].Text; got != name {
+			t.Errorf("reply of %s does not match, got = %q, want = %q", name, got, name)
+		}
+	}
+}
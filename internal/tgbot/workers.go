@@ -0,0 +1,144 @@
+package tgbot
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/metrics/metricsware"
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+)
+
+// job is an update waiting for a worker.
+type job struct {
+	u          *telegram.Update
+	enqueuedAt time.Time
+}
+
+// workerPool handles the updates with a fixed number of workers. Updates
+// from one chat always go to the same worker, so they are handled in the
+// order they were received.
+type workerPool struct {
+	// ctx is passed to the handlers. The queued updates are dropped once it
+	// is done.
+	ctx    context.Context
+	handle Handler
+	queues []chan job
+	wg     sync.WaitGroup
+
+	// queued is the number of updates waiting in all the queues.
+	queued int64
+	// active is the number of updates being handled.
+	active int64
+}
+
+// newWorkerPool starts the workers. Each worker has a queue of queueSize
+// updates. The updates are handled with ctx.
+func newWorkerPool(ctx context.Context, workers, queueSize int, handle Handler) *workerPool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &workerPool{
+		ctx:    ctx,
+		handle: handle,
+		queues: make([]chan job, workers),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan job, queueSize)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+
+	return p
+}
+
+// submit queues the update for handling. It blocks while the queue of the
+// chat is full, which slows down the update receiver. It returns an error if
+// the context is done before the update is queued.
+func (p *workerPool) submit(ctx context.Context, u *telegram.Update) error {
+	q := p.queues[p.index(updateChatID(u))]
+	j := job{u: u, enqueuedAt: time.Now()}
+
+	metricsware.NewMiddleware().RecordUpdateQueueLength(ctx, atomic.AddInt64(&p.queued, 1))
+	select {
+	case q <- j:
+		return nil
+	case <-ctx.Done():
+		metricsware.NewMiddleware().RecordUpdateQueueLength(ctx, atomic.AddInt64(&p.queued, -1))
+		return ctx.Err()
+	}
+}
+
+// stop waits for the queued updates to be handled and stops the workers.
+// submit must not be called after stop.
+func (p *workerPool) stop() {
+	for _, q := range p.queues {
+		close(q)
+	}
+	p.wg.Wait()
+}
+
+// pending returns the number of the queued and the being handled updates.
+func (p *workerPool) pending() int64 {
+	return atomic.LoadInt64(&p.queued) + atomic.LoadInt64(&p.active)
+}
+
+func (p *workerPool) work(q <-chan job) {
+	defer p.wg.Done()
+
+	metricsMW := metricsware.NewMiddleware()
+	for j := range q {
+		metricsMW.RecordUpdateQueueLength(p.ctx, atomic.AddInt64(&p.queued, -1))
+		if p.ctx.Err() != nil {
+			continue
+		}
+
+		atomic.AddInt64(&p.active, 1)
+		p.handle(p.ctx, j.u)
+		atomic.AddInt64(&p.active, -1)
+		metricsMW.RecordUpdateLatency(p.ctx, time.Since(j.enqueuedAt))
+	}
+}
+
+// index returns the worker for the chat.
+func (p *workerPool) index(chatID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(chatID))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// updateChatID returns the ID of the chat the update belongs to or an empty
+// string. The inline queries belong to no chat and are ordered per user.
+func updateChatID(u *telegram.Update) string {
+	if m := updateMessage(u); m != nil {
+		return m.Chat.ID
+	}
+	if cm := u.MyChatMember; cm != nil {
+		return cm.Chat.ID
+	}
+	if q := u.InlineQuery; q != nil {
+		return "user:" + strconv.Itoa(q.From.ID)
+	}
+	return ""
+}
+
+// updateMessage returns the message of the update: the received or edited
+// one, or the one with the pressed button. It returns nil if the update has
+// no message.
+func updateMessage(u *telegram.Update) *tbot.Message {
+	switch {
+	case u.Message != nil:
+		return u.Message
+	case u.EditedMessage != nil:
+		return u.EditedMessage
+	case u.CallbackQuery != nil:
+		return u.CallbackQuery.Message
+	default:
+		return nil
+	}
+}
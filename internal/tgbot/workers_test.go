@@ -0,0 +1,85 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alienvspredator/simple-tgbot/internal/tgbot/telegram"
+	"github.com/yanzay/tbot/v2"
+)
+
+func chatUpdate(id int, chatID string) *telegram.Update {
+	return &telegram.Update{
+		Update: tbot.Update{
+			UpdateID: id,
+			Message:  &tbot.Message{Chat: tbot.Chat{ID: chatID}},
+		},
+	}
+}
+
+func TestWorkerPool_ChatOrder(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	got := make(map[string][]int)
+
+	p := newWorkerPool(context.Background(), 4, 10, func(ctx context.Context, u *telegram.Update) {
+		// Uneven handling time would reorder the updates without the
+		// chat affinity.
+		time.Sleep(time.Duration(u.UpdateID%3) * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		got[u.Message.Chat.ID] = append(got[u.Message.Chat.ID], u.UpdateID)
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 60; i++ {
+		if err := p.submit(ctx, chatUpdate(i, strconv.Itoa(i%5))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.stop()
+
+	for chatID, ids := range got {
+		if len(ids) != 12 {
+			t.Errorf("chat %s: number of handled updates does not match, got = %d, want = 12", chatID, len(ids))
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] < ids[i-1] {
+				t.Errorf("chat %s: updates handled out of order: %v", chatID, ids)
+				break
+			}
+		}
+	}
+}
+
+func TestWorkerPool_Backpressure(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	p := newWorkerPool(context.Background(), 1, 1, func(ctx context.Context, u *telegram.Update) {
+		<-release
+	})
+
+	ctx := context.Background()
+	// The first update is being handled, the second one fills the queue.
+	for i := 0; i < 2; i++ {
+		if err := p.submit(ctx, chatUpdate(i, "1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := p.submit(timeoutCtx, chatUpdate(2, "1")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected submit to block until deadline, got = %v", err)
+	}
+
+	close(release)
+	p.stop()
+}
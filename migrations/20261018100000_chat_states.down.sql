@@ -0,0 +1,3 @@
+BEGIN;
+DROP TABLE chat_states;
+END;
\ No newline at end of file
@@ -0,0 +1,8 @@
+BEGIN;
+CREATE TABLE chat_states (
+	chat_id    text        NOT NULL PRIMARY KEY,
+	state      text        NOT NULL,
+	state_data jsonb       NOT NULL DEFAULT '{}',
+	expires_at timestamptz NOT NULL
+);
+END;
\ No newline at end of file
@@ -0,0 +1,3 @@
+BEGIN;
+DROP TABLE user_settings;
+END;
\ No newline at end of file
@@ -0,0 +1,6 @@
+BEGIN;
+CREATE TABLE user_settings (
+	user_id int8 NOT NULL PRIMARY KEY,
+	locale  text NOT NULL
+);
+END;
\ No newline at end of file
@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE outbox_dead_letters;
+DROP TABLE outbox;
+END;
\ No newline at end of file
@@ -0,0 +1,24 @@
+BEGIN;
+CREATE TABLE outbox (
+	id                  int8        NOT NULL PRIMARY KEY DEFAULT unique_rowid(),
+	chat_id             text        NOT NULL,
+	message_text        text        NOT NULL,
+	reply_to_message_id int8        NOT NULL DEFAULT 0,
+	attempts            int4        NOT NULL DEFAULT 0,
+	last_error          text        NOT NULL DEFAULT '',
+	next_attempt_at     timestamptz NOT NULL DEFAULT now(),
+	created_at          timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX outbox_next_attempt_at_idx ON outbox (next_attempt_at);
+
+CREATE TABLE outbox_dead_letters (
+	id                  int8        NOT NULL PRIMARY KEY,
+	chat_id             text        NOT NULL,
+	message_text        text        NOT NULL,
+	reply_to_message_id int8        NOT NULL DEFAULT 0,
+	attempts            int4        NOT NULL,
+	last_error          text        NOT NULL,
+	created_at          timestamptz NOT NULL,
+	failed_at           timestamptz NOT NULL DEFAULT now()
+);
+END;
\ No newline at end of file
@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE outbox_dead_letters DROP COLUMN reply_markup;
+ALTER TABLE outbox DROP COLUMN reply_markup;
+END;
\ No newline at end of file
@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE outbox ADD COLUMN reply_markup text NOT NULL DEFAULT '';
+ALTER TABLE outbox_dead_letters ADD COLUMN reply_markup text NOT NULL DEFAULT '';
+END;
\ No newline at end of file
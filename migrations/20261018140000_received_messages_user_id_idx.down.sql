@@ -0,0 +1,3 @@
+BEGIN;
+DROP INDEX received_messages@received_messages_user_id_idx;
+END;
\ No newline at end of file
@@ -0,0 +1,3 @@
+BEGIN;
+CREATE INDEX received_messages_user_id_idx ON received_messages (user_id, telegram_message_id DESC);
+END;
\ No newline at end of file
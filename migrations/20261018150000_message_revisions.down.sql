@@ -0,0 +1,6 @@
+BEGIN;
+DROP TABLE message_revisions;
+ALTER TABLE received_messages DROP COLUMN edited_at;
+ALTER TABLE received_messages DROP COLUMN revision;
+DROP INDEX received_messages@received_messages_chat_message_idx CASCADE;
+END;
\ No newline at end of file
@@ -0,0 +1,18 @@
+BEGIN;
+DELETE FROM received_messages
+WHERE rowid NOT IN (
+	SELECT min(rowid) FROM received_messages GROUP BY chat_id, telegram_message_id
+);
+CREATE UNIQUE INDEX received_messages_chat_message_idx ON received_messages (chat_id, telegram_message_id);
+ALTER TABLE received_messages ADD COLUMN revision int4 NOT NULL DEFAULT 0;
+ALTER TABLE received_messages ADD COLUMN edited_at timestamptz;
+
+CREATE TABLE message_revisions (
+	chat_id             text        NOT NULL,
+	telegram_message_id int8        NOT NULL,
+	revision            int4        NOT NULL,
+	message_text        text        NOT NULL,
+	replaced_at         timestamptz NOT NULL,
+	PRIMARY KEY (chat_id, telegram_message_id, revision)
+);
+END;
\ No newline at end of file
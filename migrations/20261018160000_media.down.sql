@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE media_blobs;
+DROP TABLE received_media;
+END;
\ No newline at end of file
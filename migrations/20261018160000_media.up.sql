@@ -0,0 +1,24 @@
+BEGIN;
+CREATE TABLE received_media (
+	chat_id             text        NOT NULL,
+	telegram_message_id int8        NOT NULL,
+	user_id             int8        NOT NULL,
+	kind                text        NOT NULL,
+	file_id             text        NOT NULL,
+	file_unique_id      text        NOT NULL,
+	mime_type           text        NOT NULL DEFAULT '',
+	file_size           int8        NOT NULL DEFAULT 0,
+	caption             text        NOT NULL DEFAULT '',
+	content_hash        text        NOT NULL DEFAULT '',
+	created_at          timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (chat_id, telegram_message_id)
+);
+CREATE INDEX received_media_file_unique_id_idx ON received_media (file_unique_id);
+
+CREATE TABLE media_blobs (
+	content_hash text        NOT NULL PRIMARY KEY,
+	size         int8        NOT NULL,
+	mime_type    text        NOT NULL DEFAULT '',
+	created_at   timestamptz NOT NULL DEFAULT now()
+);
+END;
\ No newline at end of file
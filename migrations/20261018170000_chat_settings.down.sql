@@ -0,0 +1,3 @@
+BEGIN;
+DROP TABLE chat_settings;
+END;
\ No newline at end of file
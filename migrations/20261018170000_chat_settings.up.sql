@@ -0,0 +1,6 @@
+BEGIN;
+CREATE TABLE chat_settings (
+	chat_id text NOT NULL PRIMARY KEY,
+	silent  bool NOT NULL DEFAULT false
+);
+END;
\ No newline at end of file
@@ -0,0 +1,3 @@
+BEGIN;
+DROP TABLE users;
+END;
\ No newline at end of file
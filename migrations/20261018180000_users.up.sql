@@ -0,0 +1,14 @@
+BEGIN;
+CREATE TABLE users (
+	user_id       int8        NOT NULL PRIMARY KEY,
+	username      text        NOT NULL DEFAULT '',
+	first_name    text        NOT NULL DEFAULT '',
+	last_name     text        NOT NULL DEFAULT '',
+	language_code text        NOT NULL DEFAULT '',
+	is_bot        bool        NOT NULL DEFAULT false,
+	blocked       bool        NOT NULL DEFAULT false,
+	first_seen_at timestamptz NOT NULL DEFAULT now(),
+	last_seen_at  timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX users_username_idx ON users (username);
+END;
\ No newline at end of file
@@ -0,0 +1,9 @@
+BEGIN;
+CREATE TABLE chat_settings (
+	chat_id text NOT NULL PRIMARY KEY,
+	silent  bool NOT NULL DEFAULT false
+);
+INSERT INTO chat_settings (chat_id, silent)
+SELECT chat_id, COALESCE((settings->>'silent')::bool, false) FROM chats;
+DROP TABLE chats;
+END;
\ No newline at end of file
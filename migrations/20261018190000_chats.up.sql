@@ -0,0 +1,16 @@
+BEGIN;
+CREATE TABLE chats (
+	chat_id       text        NOT NULL PRIMARY KEY,
+	type          text        NOT NULL DEFAULT '',
+	title         text        NOT NULL DEFAULT '',
+	username      text        NOT NULL DEFAULT '',
+	member_status text        NOT NULL DEFAULT '',
+	settings      jsonb       NOT NULL DEFAULT '{}',
+	created_at    timestamptz NOT NULL DEFAULT now(),
+	updated_at    timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX chats_type_member_status_idx ON chats (type, member_status);
+INSERT INTO chats (chat_id, settings)
+SELECT chat_id, json_build_object('silent', silent) FROM chat_settings;
+DROP TABLE chat_settings;
+END;
\ No newline at end of file
@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE features;
+DROP TABLE user_roles;
+END;
\ No newline at end of file
@@ -0,0 +1,15 @@
+BEGIN;
+CREATE TABLE user_roles (
+	user_id    int8        NOT NULL PRIMARY KEY,
+	role       text        NOT NULL,
+	granted_by int8        NOT NULL DEFAULT 0,
+	updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE features (
+	name       text        NOT NULL PRIMARY KEY,
+	enabled    bool        NOT NULL,
+	updated_by int8        NOT NULL DEFAULT 0,
+	updated_at timestamptz NOT NULL DEFAULT now()
+);
+END;
\ No newline at end of file
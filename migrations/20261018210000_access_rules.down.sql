@@ -0,0 +1,3 @@
+BEGIN;
+DROP TABLE access_rules;
+END;
\ No newline at end of file
@@ -0,0 +1,12 @@
+BEGIN;
+CREATE TABLE access_rules (
+	id         int8        NOT NULL PRIMARY KEY DEFAULT unique_rowid(),
+	action     text        NOT NULL,
+	kind       text        NOT NULL,
+	value      text        NOT NULL,
+	note       text        NOT NULL DEFAULT '',
+	created_by int8        NOT NULL DEFAULT 0,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	UNIQUE (action, kind, value)
+);
+END;
\ No newline at end of file
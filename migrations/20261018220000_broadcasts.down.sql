@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE broadcast_recipients;
+DROP TABLE broadcasts;
+END;
\ No newline at end of file
@@ -0,0 +1,29 @@
+BEGIN;
+CREATE TABLE broadcasts (
+	id             int8        NOT NULL PRIMARY KEY DEFAULT unique_rowid(),
+	message_text   text        NOT NULL DEFAULT '',
+	media_kind     text        NOT NULL DEFAULT '',
+	media_file_id  text        NOT NULL DEFAULT '',
+	reply_markup   text        NOT NULL DEFAULT '',
+	language_code  text        NOT NULL DEFAULT '',
+	seen_since     timestamptz NULL,
+	include_groups bool        NOT NULL DEFAULT false,
+	status         text        NOT NULL DEFAULT 'running',
+	created_by     int8        NOT NULL DEFAULT 0,
+	created_at     timestamptz NOT NULL DEFAULT now(),
+	finished_at    timestamptz NULL
+);
+CREATE INDEX broadcasts_status_idx ON broadcasts (status);
+
+CREATE TABLE broadcast_recipients (
+	broadcast_id  int8        NOT NULL REFERENCES broadcasts (id) ON DELETE CASCADE,
+	chat_id       text        NOT NULL,
+	status        text        NOT NULL DEFAULT 'pending',
+	attempts      int4        NOT NULL DEFAULT 0,
+	last_error    text        NOT NULL DEFAULT '',
+	claimed_until timestamptz NOT NULL DEFAULT now(),
+	sent_at       timestamptz NULL,
+	PRIMARY KEY (broadcast_id, chat_id)
+);
+CREATE INDEX broadcast_recipients_status_idx ON broadcast_recipients (broadcast_id, status, claimed_until);
+END;
\ No newline at end of file
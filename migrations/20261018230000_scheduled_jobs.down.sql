@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE user_settings DROP COLUMN time_zone;
+ALTER TABLE user_settings ALTER COLUMN locale DROP DEFAULT;
+DROP TABLE scheduled_jobs;
+END;
\ No newline at end of file
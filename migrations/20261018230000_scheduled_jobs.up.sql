@@ -0,0 +1,21 @@
+BEGIN;
+CREATE TABLE scheduled_jobs (
+	id            int8        NOT NULL PRIMARY KEY DEFAULT unique_rowid(),
+	kind          text        NOT NULL,
+	chat_id       text        NOT NULL,
+	created_by    int8        NOT NULL,
+	message_text  text        NOT NULL,
+	schedule      text        NOT NULL DEFAULT '',
+	time_zone     text        NOT NULL DEFAULT 'UTC',
+	next_run_at   timestamptz NOT NULL,
+	claimed_until timestamptz NOT NULL DEFAULT now(),
+	runs          int4        NOT NULL DEFAULT 0,
+	last_run_at   timestamptz NULL,
+	created_at    timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX scheduled_jobs_next_run_at_idx ON scheduled_jobs (next_run_at, claimed_until);
+CREATE INDEX scheduled_jobs_created_by_idx ON scheduled_jobs (created_by);
+
+ALTER TABLE user_settings ALTER COLUMN locale SET DEFAULT '';
+ALTER TABLE user_settings ADD COLUMN time_zone text NOT NULL DEFAULT '';
+END;
\ No newline at end of file
@@ -0,0 +1,3 @@
+BEGIN;
+DROP TABLE flood_counters;
+END;
\ No newline at end of file
@@ -0,0 +1,9 @@
+BEGIN;
+CREATE TABLE flood_counters (
+	key         text        NOT NULL PRIMARY KEY,
+	day         date        NOT NULL,
+	daily_count int8        NOT NULL DEFAULT 0,
+	muted_until timestamptz NULL,
+	updated_at  timestamptz NOT NULL DEFAULT now()
+);
+END;
\ No newline at end of file
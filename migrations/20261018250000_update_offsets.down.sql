@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE processed_updates;
+DROP TABLE update_offsets;
+END;
\ No newline at end of file
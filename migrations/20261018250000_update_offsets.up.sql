@@ -0,0 +1,15 @@
+BEGIN;
+CREATE TABLE update_offsets (
+	bot_id         int8        NOT NULL PRIMARY KEY,
+	last_update_id int8        NOT NULL,
+	updated_at     timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE processed_updates (
+	bot_id       int8        NOT NULL,
+	update_id    int8        NOT NULL,
+	processed_at timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (bot_id, update_id)
+);
+CREATE INDEX processed_updates_processed_at_idx ON processed_updates (processed_at);
+END;
\ No newline at end of file
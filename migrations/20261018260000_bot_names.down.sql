@@ -0,0 +1,17 @@
+BEGIN;
+ALTER TABLE scheduled_jobs DROP COLUMN bot_name;
+ALTER TABLE broadcasts DROP COLUMN bot_name;
+ALTER TABLE outbox_dead_letters DROP COLUMN bot_name;
+ALTER TABLE outbox DROP COLUMN bot_name;
+ALTER TABLE flood_counters DROP COLUMN bot_name;
+ALTER TABLE access_rules DROP COLUMN bot_name;
+ALTER TABLE features DROP COLUMN bot_name;
+ALTER TABLE user_roles DROP COLUMN bot_name;
+ALTER TABLE users DROP COLUMN bot_name;
+ALTER TABLE chats DROP COLUMN bot_name;
+ALTER TABLE user_settings DROP COLUMN bot_name;
+ALTER TABLE chat_states DROP COLUMN bot_name;
+ALTER TABLE received_media DROP COLUMN bot_name;
+ALTER TABLE message_revisions DROP COLUMN bot_name;
+ALTER TABLE received_messages DROP COLUMN bot_name;
+END;
\ No newline at end of file
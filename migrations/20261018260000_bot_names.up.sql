@@ -0,0 +1,17 @@
+BEGIN;
+ALTER TABLE received_messages ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE message_revisions ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE received_media ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE chat_states ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE user_settings ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE chats ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE users ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE user_roles ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE features ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE access_rules ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE flood_counters ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE outbox ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE outbox_dead_letters ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE broadcasts ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+ALTER TABLE scheduled_jobs ADD COLUMN bot_name text NOT NULL DEFAULT 'default';
+END;
\ No newline at end of file
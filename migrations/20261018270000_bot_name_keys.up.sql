@@ -0,0 +1,28 @@
+BEGIN;
+ALTER TABLE message_revisions DROP CONSTRAINT "primary", ADD CONSTRAINT "primary" PRIMARY KEY (bot_name, chat_id, telegram_message_id, revision);
+ALTER TABLE received_media DROP CONSTRAINT "primary", ADD CONSTRAINT "primary" PRIMARY KEY (bot_name, chat_id, telegram_message_id);
+ALTER TABLE chat_states DROP CONSTRAINT "primary", ADD CONSTRAINT "primary" PRIMARY KEY (bot_name, chat_id);
+ALTER TABLE user_settings DROP CONSTRAINT "primary", ADD CONSTRAINT "primary" PRIMARY KEY (bot_name, user_id);
+ALTER TABLE chats DROP CONSTRAINT "primary", ADD CONSTRAINT "primary" PRIMARY KEY (bot_name, chat_id);
+ALTER TABLE users DROP CONSTRAINT "primary", ADD CONSTRAINT "primary" PRIMARY KEY (bot_name, user_id);
+ALTER TABLE user_roles DROP CONSTRAINT "primary", ADD CONSTRAINT "primary" PRIMARY KEY (bot_name, user_id);
+ALTER TABLE features DROP CONSTRAINT "primary", ADD CONSTRAINT "primary" PRIMARY KEY (bot_name, name);
+ALTER TABLE flood_counters DROP CONSTRAINT "primary", ADD CONSTRAINT "primary" PRIMARY KEY (bot_name, key);
+
+DROP INDEX received_messages@received_messages_chat_message_idx CASCADE;
+CREATE UNIQUE INDEX received_messages_chat_message_idx ON received_messages (bot_name, chat_id, telegram_message_id);
+DROP INDEX received_messages@received_messages_user_id_idx;
+CREATE INDEX received_messages_user_id_idx ON received_messages (bot_name, user_id, telegram_message_id DESC);
+DROP INDEX access_rules@access_rules_action_kind_value_key CASCADE;
+CREATE UNIQUE INDEX access_rules_action_kind_value_key ON access_rules (bot_name, action, kind, value);
+DROP INDEX chats@chats_type_member_status_idx;
+CREATE INDEX chats_type_member_status_idx ON chats (bot_name, type, member_status);
+DROP INDEX outbox@outbox_next_attempt_at_idx;
+CREATE INDEX outbox_next_attempt_at_idx ON outbox (bot_name, next_attempt_at);
+DROP INDEX broadcasts@broadcasts_status_idx;
+CREATE INDEX broadcasts_status_idx ON broadcasts (bot_name, status);
+DROP INDEX scheduled_jobs@scheduled_jobs_next_run_at_idx;
+CREATE INDEX scheduled_jobs_next_run_at_idx ON scheduled_jobs (bot_name, next_run_at, claimed_until);
+DROP INDEX scheduled_jobs@scheduled_jobs_created_by_idx;
+CREATE INDEX scheduled_jobs_created_by_idx ON scheduled_jobs (bot_name, created_by);
+END;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+DROP INDEX received_messages@received_messages_user_id_idx;
+CREATE INDEX received_messages_user_id_idx ON received_messages (bot_name, user_id, telegram_message_id DESC);
+ALTER TABLE received_messages DROP COLUMN received_at;
+END;
\ No newline at end of file
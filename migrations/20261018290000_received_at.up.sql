@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE received_messages ADD COLUMN received_at timestamptz NOT NULL DEFAULT now();
+DROP INDEX received_messages@received_messages_user_id_idx;
+CREATE INDEX received_messages_user_id_idx ON received_messages (bot_name, user_id, received_at DESC, telegram_message_id DESC);
+END;
\ No newline at end of file